@@ -9,9 +9,12 @@ import (
 )
 
 func main() {
-	err := promptbuilder.RunCLI(os.Args[1:], os.Stdout)
+	err := promptbuilder.RunCLI(os.Args[1:], os.Stdin, os.Stdout)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if !promptbuilder.ErrorReported(err) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,96 @@
+package promptbuilder
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	maxBatchLineSize = 16 * 1024 * 1024 // 16MB per JSON Lines request
+)
+
+// ErrBatchFailures is returned when one or more batch lines could not be built.
+var ErrBatchFailures = errors.New("batch contained failed requests")
+
+// BuildBatch builds one prompt per line of JSON Lines input, where each line is
+// a BuildRequest object, and writes the results to output as NDJSON. Every
+// output line carries the 1-based input line number. Lines that fail to parse
+// or build produce an error object instead of aborting the batch; if any line
+// failed, ErrBatchFailures is returned after the whole input is processed.
+func (b *Builder) BuildBatch(input io.Reader, output io.Writer) error {
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxBatchLineSize)
+
+	lineNumber := 0
+	failures := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields, err := b.buildBatchLine(line)
+		if err != nil {
+			failures++
+			fields = map[string]any{"error": err.Error()}
+		}
+
+		fields["line"] = lineNumber
+
+		err = writeJSONLine(output, fields)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return fmt.Errorf("failed to read batch input: %w", err)
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%w: %d of %d lines failed", ErrBatchFailures, failures, lineNumber)
+	}
+
+	return nil
+}
+
+// buildBatchLine parses a single BuildRequest and returns the JSON fields of
+// the resulting prompt.
+func (b *Builder) buildBatchLine(line string) (map[string]any, error) {
+	var req BuildRequest
+
+	err := json.Unmarshal([]byte(line), &req)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	result, err := b.BuildPrompt(&req)
+	if err != nil {
+		return nil, err
+	}
+
+	return promptJSONFields(result.Prompt), nil
+}
+
+// writeJSONLine writes value as a compact JSON object followed by a newline.
+func writeJSONLine(output io.Writer, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal NDJSON line: %w", err)
+	}
+
+	_, err = output.Write(append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write NDJSON output: %w", err)
+	}
+
+	return nil
+}
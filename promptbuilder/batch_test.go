@@ -0,0 +1,66 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestRunCLI_Batch(t *testing.T) {
+	t.Parallel()
+
+	batchFile := filepath.Join(t.TempDir(), "requests.jsonl")
+	lines := []string{
+		`{"prompt": "first prompt", "task": "coding"}`,
+		`{"prompt": "broken"`,
+		`{"prompt": "third prompt", "guidelines": "Be brief"}`,
+	}
+
+	err := os.WriteFile(batchFile, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write batch file: %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	err = promptbuilder.RunCLI([]string{"--batch", batchFile}, nil, &buf)
+	if !errors.Is(err, promptbuilder.ErrBatchFailures) {
+		t.Errorf("Expected ErrBatchFailures, got %v", err)
+	}
+
+	outputLines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(outputLines) != len(lines) {
+		t.Fatalf("Expected %d output lines, got %d: %q", len(lines), len(outputLines), buf.String())
+	}
+
+	results := make([]map[string]any, 0, len(outputLines))
+
+	for index, line := range outputLines {
+		var decoded map[string]any
+
+		err := json.Unmarshal([]byte(line), &decoded)
+		if err != nil {
+			t.Fatalf("Output line %d is not valid JSON: %v", index+1, err)
+		}
+
+		if decoded["line"] != float64(index+1) {
+			t.Errorf("Expected line number %d, got %v", index+1, decoded["line"])
+		}
+
+		results = append(results, decoded)
+	}
+
+	if results[0]["user_prompt"] != "first prompt" || results[2]["user_prompt"] != "third prompt" {
+		t.Errorf("Expected valid lines to be built, got %v and %v", results[0], results[2])
+	}
+
+	if _, ok := results[1]["error"]; !ok {
+		t.Errorf("Expected an error for line 2, got %v", results[1])
+	}
+}
@@ -3,23 +3,70 @@
 package promptbuilder
 
 import (
-	"encoding/base64"
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"os"
+	"path"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode"
 )
 
 // ErrPresetNameEmpty is returned when trying to add a system preset with an empty name.
 var (
 	ErrPresetNameEmpty = errors.New("preset name cannot be empty")
+	ErrUnknownPreset   = errors.New("unknown system preset")
+	ErrFormatterName   = errors.New("formatter name cannot be empty")
+	ErrFormatterNil    = errors.New("formatter function cannot be nil")
+	ErrImageTooLarge   = errors.New("image is too large")
+	ErrBuildTimeout    = errors.New("prompt build timed out")
 )
 
 // Builder is the main engine for constructing prompts. It is responsible for
 // orchestrating the prompt building process, including file processing and system
-// preset management.
+// preset management. Presets may be added while other goroutines build prompts;
+// other configuration should be finished before the builder is shared.
 type Builder struct {
+	// StrictPresets makes BuildPrompt fail with ErrUnknownPreset when the
+	// requested task does not name a registered preset.
+	StrictPresets bool
+
+	// WildcardPresets lets a task that names no registered preset select one
+	// by glob pattern, such as "coding-*", or fall back to a base preset by
+	// dropping "-suffix" parts, so "coding-rust" selects "coding". An exact
+	// match always wins, and a pattern matching several presets selects the
+	// first by name.
+	WildcardPresets bool
+
+	// MaxImageBytes limits the decoded size of attached images, including
+	// image files given as files or found in directories and globs. A value
+	// of zero or less disables the limit.
+	MaxImageBytes int
+
+	// ValidateImages makes BuildPrompt fail with ErrInvalidImageData when an
+	// attached image is not a recognized image format.
+	ValidateImages bool
+
 	fileProcessor *FileProcessor
-	systemPresets map[string]string
+	templates     *template.Template
+	tokenizer     Tokenizer
+
+	// mu guards systemPresets and formatters so presets and formatters can
+	// be added while other goroutines build prompts. The formatters map is
+	// replaced rather than changed, so results can keep rendering with the
+	// map they were built with.
+	mu            *sync.RWMutex
+	systemPresets map[string]SystemPreset
+	formatters    map[string]Formatter
 }
 
 // New creates a new prompt builder with a given file processor. This function is
@@ -27,65 +74,596 @@ type Builder struct {
 // initialized with a file processor.
 func New(fp *FileProcessor) *Builder {
 	return &Builder{
-		fileProcessor: fp,
-		systemPresets: make(map[string]string),
+		StrictPresets:   false,
+		WildcardPresets: false,
+		MaxImageBytes:   0,
+		ValidateImages:  false,
+		fileProcessor:   fp,
+		templates:       nil,
+		tokenizer:       HeuristicTokenizer{},
+		mu:              &sync.RWMutex{},
+		systemPresets:   make(map[string]SystemPreset),
+		formatters:      make(map[string]Formatter),
 	}
 }
 
+// Clone returns a copy of the builder whose presets, formatters, and file
+// processor can be changed without affecting b, so goroutines can each derive
+// their own builder from a configured base. See FileProcessor.Clone for what
+// the processors still share. Loaded templates are shared because builds only
+// read them.
+func (b *Builder) Clone() *Builder {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	clone := *b
+	clone.mu = &sync.RWMutex{}
+	clone.systemPresets = maps.Clone(b.systemPresets)
+	clone.fileProcessor = b.fileProcessor.Clone()
+
+	return &clone
+}
+
 // AddSystemPreset adds a named system message preset to the builder. This allows
 // for reusable system messages that can be referenced by name when building a
 // prompt.
 func (b *Builder) AddSystemPreset(name, message string) error {
+	return b.AddSystemPresetWithGuidelines(name, message, "")
+}
+
+// AddSystemPresetWithGuidelines adds a named system message preset that also
+// carries default guidelines. BuildPrompt uses the guidelines when a request
+// selecting the preset as its task does not give its own.
+func (b *Builder) AddSystemPresetWithGuidelines(name, message, guidelines string) error {
 	if strings.TrimSpace(name) == "" {
 		return ErrPresetNameEmpty // Use the static error
 	}
 
-	b.systemPresets[name] = message
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.systemPresets[name] = SystemPreset{Name: name, Message: message, Guidelines: guidelines}
+
+	return nil
+}
+
+// Reset clears every registered system preset, output formatter, and loaded
+// template so the builder can be reused for an unrelated job. The file
+// processor and exported settings are left unchanged.
+func (b *Builder) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.systemPresets = make(map[string]SystemPreset)
+	b.formatters = make(map[string]Formatter)
+	b.templates = nil
+}
+
+// RegisterFormatter adds a named output formatter to the builder. Registered
+// formatters are consulted before the built-in formats, so they can add new
+// formats or replace existing ones.
+func (b *Builder) RegisterFormatter(name string, fn Formatter) error {
+	if strings.TrimSpace(name) == "" {
+		return ErrFormatterName
+	}
+
+	if fn == nil {
+		return ErrFormatterNil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	formatters := maps.Clone(b.formatters)
+	formatters[name] = fn
+	b.formatters = formatters
 
 	return nil
 }
 
+// currentFormatters returns the registered formatters. The map must not be
+// changed; RegisterFormatter replaces it instead.
+func (b *Builder) currentFormatters() map[string]Formatter {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.formatters
+}
+
+// currentTemplates returns the templates loaded with LoadTemplateDir, or nil.
+func (b *Builder) currentTemplates() *template.Template {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.templates
+}
+
+// SetTokenizer sets the tokenizer used by the token counts of results built
+// from now on, such as BuildResult.TokenEstimate. A nil tokenizer restores the
+// default HeuristicTokenizer.
+func (b *Builder) SetTokenizer(tokenizer Tokenizer) {
+	if tokenizer == nil {
+		tokenizer = HeuristicTokenizer{}
+	}
+
+	b.tokenizer = tokenizer
+}
+
+// Render formats the prompt using a registered formatter or, if none matches,
+// one of the built-in formats.
+func (b *Builder) Render(prompt *Prompt, format string) ([]byte, error) {
+	return renderWith(b.currentFormatters(), prompt, format)
+}
+
+// systemPreset looks up the preset registered under name, resolving patterns
+// and base presets when WildcardPresets is set.
+func (b *Builder) systemPreset(name string) (SystemPreset, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	preset, ok := b.systemPresets[name]
+	if ok || !b.WildcardPresets || name == "" {
+		return preset, ok
+	}
+
+	if strings.ContainsAny(name, "*?[") {
+		for _, candidate := range slices.Sorted(maps.Keys(b.systemPresets)) {
+			if matched, _ := path.Match(name, candidate); matched {
+				return b.systemPresets[candidate], true
+			}
+		}
+	}
+
+	for base := name; strings.Contains(base, "-"); {
+		base = base[:strings.LastIndex(base, "-")]
+
+		if preset, ok := b.systemPresets[base]; ok {
+			return preset, true
+		}
+	}
+
+	return SystemPreset{}, false
+}
+
+// ListSystemPresets returns the registered system presets sorted by name. The
+// ordering is deterministic so callers can snapshot the output.
+func (b *Builder) ListSystemPresets() []SystemPreset {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	presets := make([]SystemPreset, 0, len(b.systemPresets))
+
+	for _, preset := range b.systemPresets {
+		presets = append(presets, preset)
+	}
+
+	sort.Slice(presets, func(i, j int) bool {
+		return presets[i].Name < presets[j].Name
+	})
+
+	return presets
+}
+
 // BuildPrompt constructs a prompt from a BuildRequest. This is the main entry
 // point for the prompt builder and is responsible for orchestrating the entire
 // prompt building process.
 func (b *Builder) BuildPrompt(req *BuildRequest) (*BuildResult, error) {
+	return b.BuildPromptContext(context.Background(), req)
+}
+
+// BuildPromptContext is like BuildPrompt but stops file processing, including
+// directory walks and remote fetches, when ctx is done. If ctx's deadline
+// passes, the returned error wraps ErrBuildTimeout.
+func (b *Builder) BuildPromptContext(ctx context.Context, req *BuildRequest) (*BuildResult, error) {
+	result, err := b.buildPrompt(ctx, req)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w: %w", ErrBuildTimeout, err)
+	}
+
+	return result, err
+}
+
+// buildPrompt implements BuildPromptContext.
+func (b *Builder) buildPrompt(ctx context.Context, req *BuildRequest) (*BuildResult, error) {
 	err := req.Validate()
 	if err != nil {
 		return nil, fmt.Errorf("invalid build request: %w", err)
 	}
 
+	// Read the templates once so a concurrent LoadTemplateDir or Reset
+	// cannot change them partway through the build.
+	templates := b.currentTemplates()
+
+	if req.promptOnly() && templates == nil {
+		return b.buildPromptOnly(req), nil
+	}
+
+	return b.buildGeneral(ctx, req, templates)
+}
+
+// buildGeneral builds a validated request without the prompt-only fast path,
+// rendering it with templates, the templates loaded when the build started.
+func (b *Builder) buildGeneral(ctx context.Context, req *BuildRequest, templates *template.Template) (*BuildResult, error) {
+	var err error
+
+	if len(req.GuidelineList) > 0 {
+		combined := *req
+		if req.NumberedGuidelines {
+			combined.Guidelines = numberedList(req.AllGuidelines())
+		} else {
+			combined.Guidelines = bulletList(req.AllGuidelines())
+		}
+
+		combined.GuidelineList = nil
+		req = &combined
+	}
+
+	if req.TemplateData != nil || templates != nil {
+		req, err = renderRequestTemplates(req, templates)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Normalize {
+		normalized := *req
+		normalized.Prompt = NormalizeText(req.Prompt)
+		normalized.Guidelines = NormalizeText(req.Guidelines)
+		req = &normalized
+	}
+
 	prompt := &Prompt{
-		UserPrompt:    req.Prompt,
+		UserPrompt:    wrapUserPrompt(req),
 		Guidelines:    req.Guidelines,
+		SystemContext: "", // Initialize SystemContext
 		SystemMessage: "", // Initialize SystemMessage
 		FileContent:   "", // Initialize FileContent
+		ImagePath:     "", // Initialize ImagePath
+		Manifest:      "", // Initialize Manifest
+		LabelSystem:   req.LabelSystem,
+		Separator:     req.Separator,
+	}
+
+	if req.WithContext {
+		prompt.SystemContext = systemContext()
+	}
+
+	result := &BuildResult{
+		Prompt:        prompt,
+		Error:         nil,
+		Task:          req.Task,
+		SystemSource:  SystemSourceNone,
+		Files:         nil,
+		ImageSize:     len(req.Image),
+		TotalLines:    0,
+		FileLines:     nil,
+		FileRole:      req.FileRole,
+		ImageDetail:   req.ImageDetail,
+		JSONFiles:     req.JSONFiles,
+		Warnings:      nil,
+		formatters:    b.currentFormatters(),
+		tokenizer:     b.tokenizer,
+		fenceLanguage: b.fileProcessor.FenceLanguage,
+	}
+
+	preset, hasPreset := b.systemPreset(req.Task)
+
+	if hasPreset && (req.TemplateData != nil || templates != nil) {
+		preset, err = renderPresetTemplates(preset, req.TemplateData, templates)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Handle the system message logic
 	if req.SystemMessage != "" {
 		prompt.SystemMessage = req.SystemMessage
+		result.SystemSource = SystemSourceCustom
 	} else if req.Task != "" {
-		if preset, ok := b.systemPresets[req.Task]; ok {
-			prompt.SystemMessage = preset
+		if hasPreset {
+			prompt.SystemMessage = preset.Message
+			result.SystemSource = SystemSourcePreset
+		} else if b.StrictPresets {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPreset, req.Task)
 		}
 	}
 
+	// Fall back to the task's default guidelines
+	if hasPreset && prompt.Guidelines == "" {
+		prompt.Guidelines = preset.Guidelines
+	}
+
+	if !req.KeepWhitespace {
+		prompt.SystemMessage = strings.TrimRightFunc(prompt.SystemMessage, unicode.IsSpace)
+		prompt.Guidelines = strings.TrimRightFunc(prompt.Guidelines, unicode.IsSpace)
+		prompt.UserPrompt = strings.TrimRightFunc(prompt.UserPrompt, unicode.IsSpace)
+	}
+
 	// Handle the file content
-	if req.File != "" {
-		fileContent, err := b.fileProcessor.ProcessFile(req.File)
+	if paths := req.FilePaths(); len(paths) > 0 || len(req.Functions) > 0 {
+		selection := fileSelection{sortBy: req.SortFilesBy, reverse: req.SortReverse, limit: req.MaxIncluded}
+
+		fileContents, omitted, warnings, err := b.fileProcessor.processFiles(ctx, paths, selection, b.MaxImageBytes)
 		if err != nil {
 			return nil, fmt.Errorf("failed to process file: %w", err)
 		}
 
-		prompt.FileContent = b.fileProcessor.FenceContent(fileContent.Content, fileContent.Path)
+		result.Warnings = append(result.Warnings, warnings...)
+
+		functions, err := b.fileProcessor.processFunctionRefs(req.Functions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to process functions: %w", err)
+		}
+
+		fileContents = append(fileContents, functions...)
+
+		err = SortFiles(fileContents, req.SortFilesBy, req.SortReverse)
+		if err != nil {
+			return nil, err
+		}
+
+		// Functions are read after the files, so the cap is applied again to
+		// the combined list.
+		if req.MaxIncluded > 0 && len(fileContents) > req.MaxIncluded {
+			for _, fileContent := range fileContents[req.MaxIncluded:] {
+				omitted = append(omitted, b.fileProcessor.DisplayPath(fileContent.Path))
+			}
+
+			fileContents = fileContents[:req.MaxIncluded]
+		}
+
+		if len(omitted) > 0 {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("included %d of %d files, omitted %d: %s",
+				len(fileContents), len(fileContents)+len(omitted), len(omitted), strings.Join(omitted, ", ")))
+		}
+
+		result.Files = fileContents
+		result.FileLines = make(map[string]int, len(fileContents))
+
+		fenced := make([]string, 0, len(fileContents))
+		for _, fileContent := range fileContents {
+			lines := countLines(fileContent.Content)
+			result.FileLines[fileContent.Path] = lines
+			result.TotalLines += lines
+
+			if note := req.fileNote(fileContent.Path); note != "" {
+				fileContent.Note = note
+			}
+
+			fenced = append(fenced, b.fileProcessor.FenceFile(fileContent))
+		}
+
+		prompt.FileContent = strings.Join(fenced, "\n\n")
+
+		if req.TableOfContents && len(fileContents) > 1 {
+			prompt.TableOfContents = tableOfContents(fileContents, b.fileProcessor.DisplayPath)
+		}
+
+		if req.Manifest {
+			var manifest Manifest
+			for _, fileContent := range fileContents {
+				manifest.addFile(b.fileProcessor.DisplayPath(fileContent.Path), fileContent)
+			}
+
+			prompt.Manifest = manifest.String()
+		}
+	} else if req.ImagePath != "" {
+		err = b.fileProcessor.validateImagePath(req.ImagePath)
+		if err != nil {
+			return nil, err
+		}
+
+		prompt.ImagePath = req.ImagePath
 	} else if len(req.Image) > 0 {
-		// Assuming image is PNG for now, as per png-to-text-service context
-		encodedImage := base64.StdEncoding.EncodeToString(req.Image)
-		prompt.FileContent = b.fileProcessor.FenceContent([]byte("data:image/png;base64,"+encodedImage), "image.png")
+		err = checkImageSize("image", int64(len(req.Image)), b.MaxImageBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		if b.ValidateImages {
+			err = ValidateImageData(req.Image)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		mimeType := DetectImageMIMEType(req.Image)
+		prompt.ImageURL = imageDataURI(req.Image)
+		prompt.imageFence = b.fileProcessor.FenceContent([]byte(prompt.ImageURL), imageFilename(mimeType))
+		prompt.FileContent = prompt.imageFence
 	}
 
+	if len(req.Commands) > 0 {
+		fenced, warnings, err := fenceCommandOutputs(ctx, req.Commands, req.AllowedCommands, req.AllowShell)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Warnings = append(result.Warnings, warnings...)
+
+		if prompt.FileContent != "" {
+			fenced = append([]string{prompt.FileContent}, fenced...)
+		}
+
+		prompt.FileContent = strings.Join(fenced, "\n\n")
+	}
+
+	return result, nil
+}
+
+// AttachFile processes and fences the file at path and appends it to the
+// prompt's file content, separated from existing content by a blank line. It
+// lets callers grow a prompt incrementally instead of rebuilding it.
+func (b *Builder) AttachFile(prompt *Prompt, path string) error {
+	fileContent, err := b.fileProcessor.ProcessFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to attach file: %w", err)
+	}
+
+	fenced := b.fileProcessor.FenceFile(fileContent)
+
+	if prompt.FileContent == "" {
+		prompt.FileContent = fenced
+	} else {
+		prompt.FileContent += "\n\n" + fenced
+	}
+
+	return nil
+}
+
+// renderRequestTemplates returns a copy of the request with the prompt and
+// guidelines rendered against the request's template data and templates, the
+// set loaded with LoadTemplateDir, if any.
+func renderRequestTemplates(req *BuildRequest, templates *template.Template) (*BuildRequest, error) {
+	rendered := *req
+
+	prompt, err := renderTemplate("prompt", req.Prompt, req.TemplateData, templates)
+	if err != nil {
+		return nil, err
+	}
+
+	guidelines, err := renderTemplate("guidelines", req.Guidelines, req.TemplateData, templates)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered.Prompt = prompt
+	rendered.Guidelines = guidelines
+
+	return &rendered, nil
+}
+
+// renderPresetTemplates returns a copy of preset with its message and
+// guidelines rendered against data and templates, the set loaded with
+// LoadTemplateDir, if any, so presets can be parameterized, e.g. with
+// {{.Language}}.
+func renderPresetTemplates(preset SystemPreset, data map[string]any, templates *template.Template) (SystemPreset, error) {
+	message, err := renderTemplate("preset", preset.Message, data, templates)
+	if err != nil {
+		return SystemPreset{}, err
+	}
+
+	guidelines, err := renderTemplate("preset guidelines", preset.Guidelines, data, templates)
+	if err != nil {
+		return SystemPreset{}, err
+	}
+
+	preset.Message = message
+	preset.Guidelines = guidelines
+
+	return preset, nil
+}
+
+// bulletList renders several items as a Markdown bullet list, indenting the
+// continuation lines of multi-line items. A single item is returned as-is.
+func bulletList(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+
+	bullets := make([]string, 0, len(items))
+	for _, item := range items {
+		bullets = append(bullets, "- "+strings.ReplaceAll(item, "\n", "\n  "))
+	}
+
+	return strings.Join(bullets, "\n")
+}
+
+// numberedList renders several items as a Markdown numbered list, indenting
+// the continuation lines of multi-line items. A single item is returned as-is.
+func numberedList(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+
+	steps := make([]string, 0, len(items))
+	for index, item := range items {
+		marker := strconv.Itoa(index+1) + ". "
+		steps = append(steps, marker+strings.ReplaceAll(item, "\n", "\n"+strings.Repeat(" ", len(marker))))
+	}
+
+	return strings.Join(steps, "\n")
+}
+
+// buildPromptOnly is the fast path for requests that set nothing but Prompt.
+// It must produce the same result as the general path in buildPrompt.
+func (b *Builder) buildPromptOnly(req *BuildRequest) *BuildResult {
 	return &BuildResult{
-		Prompt: prompt,
-		Error:  nil,
-	}, nil
+		Prompt: &Prompt{
+			UserPrompt:    strings.TrimRightFunc(req.Prompt, unicode.IsSpace),
+			Guidelines:    "",
+			SystemContext: "",
+			SystemMessage: "",
+			FileContent:   "",
+			ImagePath:     "",
+			Manifest:      "",
+			LabelSystem:   false,
+			Separator:     "",
+		},
+		Error:         nil,
+		Task:          "",
+		SystemSource:  SystemSourceNone,
+		Files:         nil,
+		ImageSize:     0,
+		TotalLines:    0,
+		FileLines:     nil,
+		FileRole:      "",
+		ImageDetail:   "",
+		JSONFiles:     false,
+		Warnings:      nil,
+		formatters:    b.currentFormatters(),
+		tokenizer:     b.tokenizer,
+		fenceLanguage: b.fileProcessor.FenceLanguage,
+	}
+}
+
+// wrapUserPrompt sandwiches the request prompt between the optional prefix and
+// suffix, separated by blank lines.
+func wrapUserPrompt(req *BuildRequest) string {
+	parts := make([]string, 0, 3)
+
+	if req.PromptPrefix != "" {
+		parts = append(parts, req.PromptPrefix)
+	}
+
+	parts = append(parts, req.Prompt)
+
+	if req.PromptSuffix != "" {
+		parts = append(parts, req.PromptSuffix)
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// countLines returns the number of lines in content. A final line without a
+// trailing newline still counts.
+func countLines(content []byte) int {
+	if len(content) == 0 {
+		return 0
+	}
+
+	lines := bytes.Count(content, []byte("\n"))
+	if content[len(content)-1] != '\n' {
+		lines++
+	}
+
+	return lines
+}
+
+// systemContext describes the environment the prompt was built in. It is
+// included on request so that prompts can be reproduced later.
+func systemContext() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "unknown"
+	}
+
+	lines := []string{
+		"OS: " + runtime.GOOS + "/" + runtime.GOARCH,
+		"Go version: " + runtime.Version(),
+		"Working directory: " + cwd,
+		"Date: " + time.Now().Format(time.RFC3339),
+	}
+
+	return strings.Join(lines, "\n")
 }
@@ -0,0 +1,897 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+// newTestBuilder returns a builder with a small file processor suitable for tests.
+func newTestBuilder() *promptbuilder.Builder {
+	return promptbuilder.New(promptbuilder.NewFileProcessor(1024*1024, []string{".go", ".txt"}))
+}
+
+func TestBuilder_ListSystemPresetsSorted(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+
+	for _, name := range []string{"documentation", "analysis", "coding"} {
+		err := builder.AddSystemPreset(name, "message for "+name)
+		if err != nil {
+			t.Fatalf("AddSystemPreset(%s) unexpected error = %v", name, err)
+		}
+	}
+
+	presets := builder.ListSystemPresets()
+
+	want := []string{"analysis", "coding", "documentation"}
+	if len(presets) != len(want) {
+		t.Fatalf("Expected %d presets, got %d", len(want), len(presets))
+	}
+
+	for index, name := range want {
+		if presets[index].Name != name {
+			t.Errorf("Expected preset %d to be %s, got %s", index, name, presets[index].Name)
+		}
+
+		if presets[index].Message != "message for "+name {
+			t.Errorf("Expected message for %s, got %s", name, presets[index].Message)
+		}
+	}
+}
+
+func TestBuilder_BuildPromptPrefixAndSuffix(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+
+	result, err := builder.BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:        "Review this function.",
+		SystemMessage: "You are a reviewer.",
+		PromptPrefix:  "Answer concisely.",
+		PromptSuffix:  "Cite line numbers.",
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	want := "Answer concisely.\n\nReview this function.\n\nCite line numbers."
+	if result.Prompt.UserPrompt != want {
+		t.Errorf("Expected user prompt %q, got %q", want, result.Prompt.UserPrompt)
+	}
+
+	if result.Prompt.SystemMessage != "You are a reviewer." {
+		t.Errorf("Expected system message to be unwrapped, got %q", result.Prompt.SystemMessage)
+	}
+}
+
+func TestBuilder_BuildPromptPrefixOnly(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+
+	result, err := builder.BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:       "Review this function.",
+		PromptPrefix: "Answer concisely.",
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	want := "Answer concisely.\n\nReview this function."
+	if result.Prompt.UserPrompt != want {
+		t.Errorf("Expected user prompt %q, got %q", want, result.Prompt.UserPrompt)
+	}
+}
+
+func TestBuilder_BuildPromptDeduplicatesFiles(t *testing.T) {
+	t.Parallel()
+
+	tmpFileName, cwd, cleanup := setupFileProcessorTest(t)
+	t.Cleanup(cleanup)
+
+	relativeName, err := filepath.Rel(cwd, tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to compute relative path: %v", err)
+	}
+
+	builder := newTestBuilder()
+
+	result, err := builder.BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt: "Explain this code",
+		File:   tmpFileName,
+		Files:  []string{relativeName, tmpFileName},
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if count := strings.Count(result.Prompt.FileContent, "BEGIN "); count != 1 {
+		t.Errorf("Expected file to appear once, found %d fences in %q", count, result.Prompt.FileContent)
+	}
+}
+
+func TestBuilder_BuildPromptStrictPresets(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+	builder.StrictPresets = true
+
+	_, err := builder.BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Write a function", Task: "nonexistent"})
+	if !errors.Is(err, promptbuilder.ErrUnknownPreset) {
+		t.Errorf("Expected ErrUnknownPreset, got %v", err)
+	}
+}
+
+func TestBuilder_BuildPromptLenientPresets(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+
+	result, err := builder.BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Write a function", Task: "nonexistent"})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if result.Prompt.SystemMessage != "" {
+		t.Errorf("Expected empty system message, got %q", result.Prompt.SystemMessage)
+	}
+}
+
+func TestBuilder_BuildPromptPresetGuidelines(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+
+	err := builder.AddSystemPresetWithGuidelines("review", "You are a reviewer.", "- Cite line numbers")
+	if err != nil {
+		t.Fatalf("AddSystemPresetWithGuidelines() unexpected error = %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		req            promptbuilder.BuildRequest
+		wantGuidelines string
+	}{
+		{
+			name:           "task supplies defaults",
+			req:            promptbuilder.BuildRequest{Prompt: "Review", Task: "review"},
+			wantGuidelines: "- Cite line numbers",
+		},
+		{
+			name:           "request guidelines win",
+			req:            promptbuilder.BuildRequest{Prompt: "Review", Task: "review", Guidelines: "Be brief"},
+			wantGuidelines: "Be brief",
+		},
+		{
+			name: "custom system message keeps task defaults",
+			req: promptbuilder.BuildRequest{
+				Prompt: "Review", Task: "review", SystemMessage: "Custom",
+			},
+			wantGuidelines: "- Cite line numbers",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := builder.BuildPrompt(&test.req)
+			if err != nil {
+				t.Fatalf("BuildPrompt() unexpected error = %v", err)
+			}
+
+			if result.Prompt.Guidelines != test.wantGuidelines {
+				t.Errorf("Guidelines = %q, want %q", result.Prompt.Guidelines, test.wantGuidelines)
+			}
+		})
+	}
+}
+
+func TestBuilder_BuildPromptMaxImageBytes(t *testing.T) {
+	t.Parallel()
+
+	image := bytes.Repeat([]byte{0x89}, 64)
+
+	tests := []struct {
+		name     string
+		maxBytes int
+		wantErr  bool
+	}{
+		{name: "under the limit", maxBytes: 128, wantErr: false},
+		{name: "exactly the limit", maxBytes: 64, wantErr: false},
+		{name: "over the limit", maxBytes: 32, wantErr: true},
+		{name: "no limit", maxBytes: 0, wantErr: false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			builder := newTestBuilder()
+			builder.MaxImageBytes = testCase.maxBytes
+
+			_, err := builder.BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Describe", Image: image})
+			if testCase.wantErr && !errors.Is(err, promptbuilder.ErrImageTooLarge) {
+				t.Errorf("Expected ErrImageTooLarge, got %v", err)
+			}
+
+			if !testCase.wantErr && err != nil {
+				t.Errorf("BuildPrompt() unexpected error = %v", err)
+			}
+		})
+	}
+}
+
+func TestBuilder_BuildPromptMaxImageBytesExpandedImages(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 56)...)
+
+	err := os.WriteFile(filepath.Join(dir, "chart.png"), png, 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "notes.txt"), bytes.Repeat([]byte("x"), 64), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write notes: %v", err)
+	}
+
+	processor := promptbuilder.NewFileProcessor(1024*1024, []string{".png", ".txt"})
+	processor.ImagesAsBase64 = true
+
+	builder := promptbuilder.New(processor)
+	builder.MaxImageBytes = 32
+
+	_, err = builder.BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Describe", Files: []string{dir}})
+	if !errors.Is(err, promptbuilder.ErrImageTooLarge) || !strings.Contains(err.Error(), "chart.png") {
+		t.Errorf("Expected ErrImageTooLarge naming chart.png, got %v", err)
+	}
+
+	builder.MaxImageBytes = 64
+
+	result, err := builder.BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Describe", Files: []string{dir}})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if len(result.Files) != 2 {
+		t.Errorf("Expected the image and the text file, got %d files", len(result.Files))
+	}
+}
+
+func TestBuilder_BuildPromptSortsFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"b_medium.txt": "medium content",
+		"c_small.txt":  "tiny",
+		"a_large.txt":  "the largest content of all three",
+	}
+
+	given := []string{"b_medium.txt", "c_small.txt", "a_large.txt"}
+	paths := make([]string, 0, len(given))
+
+	for _, name := range given {
+		path := filepath.Join(dir, name)
+
+		err := os.WriteFile(path, []byte(files[name]), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	tests := []struct {
+		name    string
+		sortBy  string
+		reverse bool
+		want    []string
+	}{
+		{name: "input order", sortBy: "", reverse: false, want: given},
+		{name: "name", sortBy: "name", reverse: false, want: []string{"a_large.txt", "b_medium.txt", "c_small.txt"}},
+		{name: "size", sortBy: "size", reverse: false, want: []string{"c_small.txt", "b_medium.txt", "a_large.txt"}},
+		{name: "size reversed", sortBy: "size", reverse: true, want: []string{"a_large.txt", "b_medium.txt", "c_small.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{
+				Prompt:      "Review",
+				Files:       paths,
+				SortFilesBy: tt.sortBy,
+				SortReverse: tt.reverse,
+			})
+			if err != nil {
+				t.Fatalf("BuildPrompt() unexpected error = %v", err)
+			}
+
+			for index, file := range result.Files {
+				if filepath.Base(file.Path) != tt.want[index] {
+					t.Errorf("Expected file %d to be %s, got %s", index, tt.want[index], filepath.Base(file.Path))
+				}
+			}
+		})
+	}
+}
+
+func TestSortFiles_UnknownKey(t *testing.T) {
+	t.Parallel()
+
+	err := promptbuilder.SortFiles(nil, "color", false)
+	if !errors.Is(err, promptbuilder.ErrUnknownSortKey) {
+		t.Errorf("Expected ErrUnknownSortKey, got %v", err)
+	}
+}
+
+func TestBuilder_Reset(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+
+	err := builder.AddSystemPreset("coding", "You write code.")
+	if err != nil {
+		t.Fatalf("AddSystemPreset() unexpected error = %v", err)
+	}
+
+	err = builder.RegisterFormatter("upper", func(prompt *promptbuilder.Prompt) ([]byte, error) {
+		return []byte(strings.ToUpper(prompt.UserPrompt)), nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterFormatter() unexpected error = %v", err)
+	}
+
+	builder.Reset()
+
+	if presets := builder.ListSystemPresets(); len(presets) != 0 {
+		t.Errorf("Expected no presets after Reset, got %v", presets)
+	}
+
+	_, err = builder.Render(&promptbuilder.Prompt{UserPrompt: "hi"}, "upper")
+	if !errors.Is(err, promptbuilder.ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat after Reset, got %v", err)
+	}
+}
+
+func TestBuilder_BuildPromptLineCounts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+
+	for path, content := range map[string]string{first: "one\ntwo\nthree\n", second: "alpha\nbeta"} {
+		err := os.WriteFile(path, []byte(content), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	result, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt: "Review",
+		Files:  []string{first, second},
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if result.TotalLines != 5 {
+		t.Errorf("Expected 5 total lines, got %d", result.TotalLines)
+	}
+
+	if result.FileLines[first] != 3 || result.FileLines[second] != 2 {
+		t.Errorf("Unexpected per-file line counts %v", result.FileLines)
+	}
+}
+
+func TestBuilder_BuildPromptTrimsTrailingWhitespace(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+
+	err := builder.AddSystemPreset("coding", "You write code.  \n\n")
+	if err != nil {
+		t.Fatalf("AddSystemPreset() unexpected error = %v", err)
+	}
+
+	req := &promptbuilder.BuildRequest{
+		Prompt:     "  Review this\t\n",
+		Task:       "coding",
+		Guidelines: "Be brief \n",
+	}
+
+	result, err := builder.BuildPrompt(req)
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	prompt := result.Prompt
+	if prompt.SystemMessage != "You write code." || prompt.Guidelines != "Be brief" || prompt.UserPrompt != "  Review this" {
+		t.Errorf("Expected trailing whitespace trimmed, got %q, %q, %q",
+			prompt.SystemMessage, prompt.Guidelines, prompt.UserPrompt)
+	}
+
+	req.KeepWhitespace = true
+
+	result, err = builder.BuildPrompt(req)
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if result.Prompt.Guidelines != "Be brief \n" {
+		t.Errorf("Expected whitespace kept, got %q", result.Prompt.Guidelines)
+	}
+}
+
+func TestBuilder_AttachFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+
+	for path, content := range map[string]string{first: "first content", second: "second content"} {
+		err := os.WriteFile(path, []byte(content), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	builder := newTestBuilder()
+	prompt := &promptbuilder.Prompt{UserPrompt: "Review"}
+
+	for _, path := range []string{first, second} {
+		err := builder.AttachFile(prompt, path)
+		if err != nil {
+			t.Fatalf("AttachFile(%s) unexpected error = %v", path, err)
+		}
+	}
+
+	want := "BEGIN " + first + "\nfirst content\nEND " + first + "\n\nBEGIN " + second + "\nsecond content\nEND " + second
+	if prompt.FileContent != want {
+		t.Errorf("Expected %q, got %q", want, prompt.FileContent)
+	}
+
+	err := builder.AttachFile(prompt, filepath.Join(dir, "missing.txt"))
+	if err == nil {
+		t.Error("Expected an error attaching a missing file")
+	}
+}
+
+func TestBuildPrompt_PromptOnlyMatchesGeneralPath(t *testing.T) {
+	t.Parallel()
+
+	prompts := []string{"Explain this code", "Trailing whitespace \n\t", "Multi\nline\n\nprompt"}
+
+	for _, text := range prompts {
+		builder := newTestBuilder()
+
+		fast, err := builder.BuildPrompt(&promptbuilder.BuildRequest{Prompt: text})
+		if err != nil {
+			t.Fatalf("fast path: unexpected error: %v", err)
+		}
+
+		general, err := builder.BuildPromptGeneral(&promptbuilder.BuildRequest{Prompt: text})
+		if err != nil {
+			t.Fatalf("general path: unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(fast.Prompt, general.Prompt) {
+			t.Errorf("prompt mismatch for %q:\nfast:    %#v\ngeneral: %#v", text, fast.Prompt, general.Prompt)
+		}
+
+		for _, format := range promptbuilder.SupportedFormats() {
+			var fastOut, generalOut bytes.Buffer
+
+			_, err = fast.WriteFormat(&fastOut, format)
+			if err != nil {
+				t.Fatalf("fast path %s: unexpected error: %v", format, err)
+			}
+
+			_, err = general.WriteFormat(&generalOut, format)
+			if err != nil {
+				t.Fatalf("general path %s: unexpected error: %v", format, err)
+			}
+
+			if fastOut.String() != generalOut.String() {
+				t.Errorf("%s output mismatch for %q:\nfast:    %q\ngeneral: %q",
+					format, text, fastOut.String(), generalOut.String())
+			}
+		}
+	}
+}
+
+func BenchmarkBuildPrompt_PromptOnly(b *testing.B) {
+	builder := newTestBuilder()
+	req := &promptbuilder.BuildRequest{Prompt: "Explain this code"}
+
+	for b.Loop() {
+		_, err := builder.BuildPrompt(req)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBuildPrompt_GeneralPath(b *testing.B) {
+	builder := newTestBuilder()
+	req := &promptbuilder.BuildRequest{Prompt: "Explain this code"}
+
+	for b.Loop() {
+		_, err := builder.BuildPromptGeneral(req)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestBuilder_BuildPromptWarnsAboutOversizedFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.txt")
+	large := filepath.Join(dir, "large.txt")
+
+	err := os.WriteFile(small, []byte("short"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write small file: %v", err)
+	}
+
+	err = os.WriteFile(large, []byte(strings.Repeat("x", 64)), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write large file: %v", err)
+	}
+
+	builder := promptbuilder.New(promptbuilder.NewFileProcessor(32, []string{".txt"}))
+
+	result, err := builder.BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Review", Files: []string{small, large}})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if len(result.Files) != 1 || result.Files[0].Path != small {
+		t.Errorf("Expected only the small file to be included, got %+v", result.Files)
+	}
+
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "large.txt") {
+		t.Errorf("Expected one warning naming large.txt, got %q", result.Warnings)
+	}
+
+	_, err = builder.BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Review", File: large})
+	if !errors.Is(err, promptbuilder.ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge for a single file, got %v", err)
+	}
+}
+
+func TestBuilder_CloneIsIndependent(t *testing.T) {
+	t.Parallel()
+
+	base := newTestBuilder()
+
+	err := base.AddSystemPreset("review", "You are a reviewer.")
+	if err != nil {
+		t.Fatalf("AddSystemPreset() unexpected error = %v", err)
+	}
+
+	clone := base.Clone()
+
+	err = clone.AddSystemPreset("review", "You are a harsh reviewer.")
+	if err != nil {
+		t.Fatalf("AddSystemPreset() unexpected error = %v", err)
+	}
+
+	err = clone.AddSystemPreset("extra", "Only on the clone.")
+	if err != nil {
+		t.Fatalf("AddSystemPreset() unexpected error = %v", err)
+	}
+
+	clone.Reset()
+
+	presets := base.ListSystemPresets()
+	if len(presets) != 1 || presets[0].Message != "You are a reviewer." {
+		t.Errorf("Expected the original presets to be unaffected, got %+v", presets)
+	}
+
+	result, err := base.BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Review", Task: "review"})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if result.Prompt.SystemMessage != "You are a reviewer." {
+		t.Errorf("Expected the original preset, got %q", result.Prompt.SystemMessage)
+	}
+}
+
+func TestBuilder_ConcurrentPresetsAndBuilds(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+
+	const workers = 8
+
+	var wg sync.WaitGroup
+
+	for worker := range workers {
+		wg.Go(func() {
+			name := fmt.Sprintf("preset-%d", worker)
+
+			err := builder.AddSystemPreset(name, "Message "+name)
+			if err != nil {
+				t.Errorf("AddSystemPreset() unexpected error = %v", err)
+			}
+		})
+
+		wg.Go(func() {
+			_, err := builder.BuildPrompt(&promptbuilder.BuildRequest{
+				Prompt: "Review",
+				Task:   fmt.Sprintf("preset-%d", worker),
+			})
+			if err != nil {
+				t.Errorf("BuildPrompt() unexpected error = %v", err)
+			}
+
+			_ = builder.ListSystemPresets()
+		})
+	}
+
+	wg.Wait()
+
+	if got := len(builder.ListSystemPresets()); got != workers {
+		t.Errorf("Expected %d presets, got %d", workers, got)
+	}
+}
+
+func TestBuilder_ConcurrentFormattersAndClones(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+	upper := func(prompt *promptbuilder.Prompt) ([]byte, error) {
+		return []byte(strings.ToUpper(prompt.UserPrompt)), nil
+	}
+
+	const workers = 8
+
+	var wg sync.WaitGroup
+
+	for worker := range workers {
+		wg.Go(func() {
+			err := builder.RegisterFormatter(fmt.Sprintf("upper-%d", worker), upper)
+			if err != nil {
+				t.Errorf("RegisterFormatter() unexpected error = %v", err)
+			}
+		})
+
+		wg.Go(func() {
+			clone := builder.Clone()
+
+			err := clone.RegisterFormatter("clone-only", upper)
+			if err != nil {
+				t.Errorf("RegisterFormatter() unexpected error = %v", err)
+			}
+
+			result, err := builder.BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Review"})
+			if err != nil {
+				t.Errorf("BuildPrompt() unexpected error = %v", err)
+
+				return
+			}
+
+			_, err = result.WriteFormat(io.Discard, promptbuilder.FormatMarkdown)
+			if err != nil {
+				t.Errorf("WriteFormat() unexpected error = %v", err)
+			}
+		})
+	}
+
+	wg.Wait()
+
+	_, err := builder.Render(&promptbuilder.Prompt{UserPrompt: "Review"}, "clone-only")
+	if !errors.Is(err, promptbuilder.ErrUnsupportedFormat) {
+		t.Errorf("Expected formatters registered on clones to stay off the base, got %v", err)
+	}
+
+	for worker := range workers {
+		_, err = builder.Render(&promptbuilder.Prompt{UserPrompt: "Review"}, fmt.Sprintf("upper-%d", worker))
+		if err != nil {
+			t.Errorf("Expected formatter upper-%d to be registered, got %v", worker, err)
+		}
+	}
+}
+
+func TestBuilder_BuildPromptWildcardPresets(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+	builder.WildcardPresets = true
+
+	for name, message := range map[string]string{"coding": "Base coder.", "coding-go": "Go coder."} {
+		err := builder.AddSystemPreset(name, message)
+		if err != nil {
+			t.Fatalf("AddSystemPreset() unexpected error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		task string
+		want string
+	}{
+		{task: "coding-go", want: "Go coder."},
+		{task: "coding", want: "Base coder."},
+		{task: "coding-*", want: "Go coder."},
+		{task: "coding-rust", want: "Base coder."},
+		{task: "coding-rust-async", want: "Base coder."},
+		{task: "writing", want: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.task, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := builder.BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Help", Task: test.task})
+			if err != nil {
+				t.Fatalf("BuildPrompt() unexpected error = %v", err)
+			}
+
+			if result.Prompt.SystemMessage != test.want {
+				t.Errorf("Task %q selected %q, want %q", test.task, result.Prompt.SystemMessage, test.want)
+			}
+		})
+	}
+
+	exact := builder.Clone()
+	exact.WildcardPresets = false
+
+	result, err := exact.BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Help", Task: "coding-rust"})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if result.Prompt.SystemMessage != "" {
+		t.Errorf("Expected no fallback without WildcardPresets, got %q", result.Prompt.SystemMessage)
+	}
+}
+
+func TestBuilder_BuildPromptNumberedGuidelines(t *testing.T) {
+	t.Parallel()
+
+	result, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:             "Release",
+		Guidelines:         "Tag the commit",
+		GuidelineList:      []string{"Build the binaries\nfor every platform", "Publish the notes"},
+		NumberedGuidelines: true,
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	want := "Guidelines:\n\n1. Tag the commit\n2. Build the binaries\n   for every platform\n3. Publish the notes\n\nRelease"
+	if got := result.Prompt.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestBuilder_BuildPromptMaxIncluded(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	names := []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"}
+
+	for _, name := range names {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	result, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:      "Review",
+		Files:       []string{filepath.Join(dir, "*.txt")},
+		SortFilesBy: promptbuilder.SortByName,
+		MaxIncluded: 2,
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if len(result.Files) != 2 ||
+		filepath.Base(result.Files[0].Path) != "a.txt" || filepath.Base(result.Files[1].Path) != "b.txt" {
+		t.Errorf("Expected a.txt and b.txt to be included, got %+v", result.Files)
+	}
+
+	if len(result.Warnings) != 1 {
+		t.Fatalf("Expected one warning, got %q", result.Warnings)
+	}
+
+	for _, name := range names[2:] {
+		if !strings.Contains(result.Warnings[0], name) {
+			t.Errorf("Expected the warning to name omitted %s, got %q", name, result.Warnings[0])
+		}
+	}
+
+	if strings.Contains(result.Prompt.FileContent, "c.txt") {
+		t.Errorf("Expected omitted files to be left out of the prompt, got %q", result.Prompt.FileContent)
+	}
+}
+
+func TestBuilder_BuildPromptMaxIncludedSkipsReading(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.go": "package a\n",
+		"b.go": "package b\n",
+		"z.go": "package z\nfunc broken( {\n",
+	}
+
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	processor := promptbuilder.NewFileProcessor(1024*1024, []string{".go"})
+	processor.ValidateSyntax = true
+
+	req := &promptbuilder.BuildRequest{
+		Prompt:      "Review",
+		Files:       []string{filepath.Join(dir, "*.go")},
+		SortFilesBy: promptbuilder.SortByName,
+		MaxIncluded: 2,
+	}
+
+	// MaxFiles bounds the expansion even when fewer files are included.
+	processor.MaxFiles = 2
+
+	_, err := promptbuilder.New(processor).BuildPrompt(req)
+	if !errors.Is(err, promptbuilder.ErrTooManyFiles) {
+		t.Fatalf("Expected ErrTooManyFiles with MaxIncluded set, got %v", err)
+	}
+
+	// z.go would fail syntax validation, but it sorts last and is never read.
+	processor.MaxFiles = 3
+
+	result, err := promptbuilder.New(processor).BuildPrompt(req)
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if len(result.Files) != 2 {
+		t.Errorf("Expected two files to be included, got %+v", result.Files)
+	}
+
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "included 2 of 3 files") ||
+		!strings.Contains(result.Warnings[0], "z.go") {
+		t.Errorf("Expected a warning naming omitted z.go, got %q", result.Warnings)
+	}
+}
+
+func TestBuilder_BuildPromptRejectsNegativeMaxIncluded(t *testing.T) {
+	t.Parallel()
+
+	_, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Review", MaxIncluded: -1})
+	if !errors.Is(err, promptbuilder.ErrNegativeMaxIncluded) {
+		t.Errorf("Expected ErrNegativeMaxIncluded, got %v", err)
+	}
+
+	_, err = promptbuilder.ParseFlags([]string{"-p", "Review", "--max-included", "-1"})
+	if !errors.Is(err, promptbuilder.ErrNegativeMaxIncluded) {
+		t.Errorf("Expected ParseFlags to return ErrNegativeMaxIncluded, got %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package promptbuilder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Chat message roles. FileRole values use the same names.
+const (
+	RoleSystem = "system"
+	RoleUser   = "user"
+)
+
+// Built-in chat templates accepted by ApplyChatTemplate.
+const (
+	ChatTemplateChatML = "chatml"
+	ChatTemplateLlama2 = "llama2"
+	ChatTemplateAlpaca = "alpaca"
+)
+
+// Image detail levels accepted by OpenAI vision models.
+const (
+	ImageDetailLow  = "low"
+	ImageDetailHigh = "high"
+	ImageDetailAuto = "auto"
+)
+
+var (
+	// ErrUnknownImageDetail is returned for an image detail other than low,
+	// high, or auto.
+	ErrUnknownImageDetail = errors.New("unknown image detail")
+	// ErrUnknownFileRole is returned when a request routes files to an unknown role.
+	ErrUnknownFileRole = errors.New("unknown file role")
+	// ErrUnknownChatTemplate is returned for a chat template name that is not built in.
+	ErrUnknownChatTemplate = errors.New("unknown chat template")
+)
+
+// chatTemplates maps each built-in chat template to the function that renders
+// system and user content with its special tokens. System content may be empty.
+var chatTemplates = map[string]func(system, user string) string{
+	ChatTemplateChatML: func(system, user string) string {
+		var builder strings.Builder
+
+		if system != "" {
+			builder.WriteString("<|im_start|>system\n" + system + "<|im_end|>\n")
+		}
+
+		builder.WriteString("<|im_start|>user\n" + user + "<|im_end|>\n")
+		builder.WriteString("<|im_start|>assistant\n")
+
+		return builder.String()
+	},
+	ChatTemplateLlama2: func(system, user string) string {
+		if system != "" {
+			user = "<<SYS>>\n" + system + "\n<</SYS>>\n\n" + user
+		}
+
+		return "<s>[INST] " + user + " [/INST]"
+	},
+	ChatTemplateAlpaca: func(system, user string) string {
+		text := "### Instruction:\n" + user + "\n\n### Response:\n"
+		if system != "" {
+			text = system + "\n\n" + text
+		}
+
+		return text
+	},
+}
+
+// ChatTemplates returns the names of the built-in chat templates, sorted.
+func ChatTemplates() []string {
+	names := make([]string, 0, len(chatTemplates))
+	for name := range chatTemplates {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// ApplyChatTemplate renders messages as a single string using the special
+// tokens of the named chat template, ending where the assistant's reply
+// would begin. Messages of the same role are joined with a blank line.
+func ApplyChatTemplate(name string, messages []ChatMessage) (string, error) {
+	render, ok := chatTemplates[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s (valid: %s)", ErrUnknownChatTemplate, name, strings.Join(ChatTemplates(), ", "))
+	}
+
+	var systemParts, userParts []string
+
+	for _, message := range messages {
+		if message.Role == RoleSystem {
+			systemParts = append(systemParts, message.Content)
+		} else {
+			userParts = append(userParts, message.Content)
+		}
+	}
+
+	return render(strings.Join(systemParts, "\n\n"), strings.Join(userParts, "\n\n")), nil
+}
+
+// ChatMessage is a single message in the OpenAI chat completions format.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+
+	// Images are sent after Content as image_url content parts. A message
+	// with images marshals its content as an array of parts.
+	Images []ChatImageURL `json:"-"`
+
+	// imageText is the fenced copy of an attached image in Content. It keeps
+	// the image visible to chat templates and other readers of Content, and
+	// is left out when marshaling since Images carries the image.
+	imageText string
+}
+
+// textContent returns Content without imageText and the blank line that
+// separated it from the rest of the content.
+func (m ChatMessage) textContent() string {
+	if m.imageText == "" {
+		return m.Content
+	}
+
+	for _, text := range []string{"\n\n" + m.imageText, m.imageText + "\n\n", m.imageText} {
+		if strings.Contains(m.Content, text) {
+			return strings.Replace(m.Content, text, "", 1)
+		}
+	}
+
+	return m.Content
+}
+
+// ChatImageURL is the image_url of an OpenAI image content part.
+type ChatImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// chatContentPart is one element of an OpenAI content parts array.
+type chatContentPart struct {
+	Type     string        `json:"type"`
+	Text     string        `json:"text,omitempty"`
+	ImageURL *ChatImageURL `json:"image_url,omitempty"`
+}
+
+// MarshalJSON encodes the message with string content, or with an array of
+// text and image_url content parts when it has images. A fenced copy of an
+// image in Content is left out.
+func (m ChatMessage) MarshalJSON() ([]byte, error) {
+	type plainMessage ChatMessage
+
+	var (
+		data []byte
+		err  error
+	)
+
+	text := m.textContent()
+
+	if len(m.Images) == 0 {
+		m.Content = text
+		data, err = json.Marshal(plainMessage(m))
+	} else {
+		parts := make([]chatContentPart, 0, len(m.Images)+1)
+		if text != "" {
+			parts = append(parts, chatContentPart{Type: "text", Text: text, ImageURL: nil})
+		}
+
+		for index := range m.Images {
+			parts = append(parts, chatContentPart{Type: "image_url", Text: "", ImageURL: &m.Images[index]})
+		}
+
+		data, err = json.Marshal(struct {
+			Role    string            `json:"role"`
+			Content []chatContentPart `json:"content"`
+		}{Role: m.Role, Content: parts})
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat message: %w", err)
+	}
+
+	return data, nil
+}
+
+// ValidateImageDetail checks that detail is empty or one of the image detail
+// levels.
+func ValidateImageDetail(detail string) error {
+	switch detail {
+	case "", ImageDetailLow, ImageDetailHigh, ImageDetailAuto:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s (valid: %s, %s, %s)",
+			ErrUnknownImageDetail, detail, ImageDetailLow, ImageDetailHigh, ImageDetailAuto)
+	}
+}
+
+// ToChatMessages splits the prompt into chat messages. The system context and
+// system message form the system message; guidelines and the user prompt form
+// the user message. File content is placed in the message named by fileRole,
+// which defaults to the user message when empty. The system message is omitted
+// when it would be empty. An attached image stays fenced in Content, for chat
+// templates, and is also added to the user message's Images; marshaling the
+// messages sends it only as an image_url part.
+func (p *Prompt) ToChatMessages(fileRole string) ([]ChatMessage, error) {
+	if fileRole == "" {
+		fileRole = RoleUser
+	}
+
+	if fileRole != RoleSystem && fileRole != RoleUser {
+		return nil, fmt.Errorf("%w: %s (valid: %s, %s)", ErrUnknownFileRole, fileRole, RoleSystem, RoleUser)
+	}
+
+	var systemParts, userParts []string
+
+	imageText := ""
+
+	for _, section := range p.Sections() {
+		parts := []string{section.Content}
+		if section.Label != "" {
+			parts = []string{section.Label, section.Content}
+		}
+
+		if section.Name == SectionFiles && p.ImageURL != "" && p.imageFence != "" {
+			imageText = p.imageFence
+			// Drop the label too when the image is the only file.
+			if strings.TrimSpace(strings.Replace(section.Content, p.imageFence, "", 1)) == "" {
+				imageText = strings.Join(parts, "\n\n")
+			}
+		}
+
+		switch {
+		case section.Name == SectionContext, section.Name == SectionSystem,
+			section.Name == SectionFiles && fileRole == RoleSystem:
+			systemParts = append(systemParts, parts...)
+		default:
+			userParts = append(userParts, parts...)
+		}
+	}
+
+	messages := make([]ChatMessage, 0, 2)
+
+	if len(systemParts) > 0 {
+		system := ChatMessage{Role: RoleSystem, Content: strings.Join(systemParts, "\n\n"), Images: nil, imageText: ""}
+		if fileRole == RoleSystem {
+			system.imageText = imageText
+		}
+
+		messages = append(messages, system)
+	}
+
+	user := ChatMessage{Role: RoleUser, Content: strings.Join(userParts, "\n\n"), Images: nil, imageText: ""}
+	if fileRole == RoleUser {
+		user.imageText = imageText
+	}
+
+	if p.ImageURL != "" {
+		user.Images = []ChatImageURL{{URL: p.ImageURL, Detail: ""}}
+	}
+
+	return append(messages, user), nil
+}
+
+// ToChatMessages splits the built prompt into chat messages, placing file
+// content in the role requested by BuildRequest.FileRole and giving images
+// the requested BuildRequest.ImageDetail.
+func (r *BuildResult) ToChatMessages() ([]ChatMessage, error) {
+	messages, err := r.Prompt.ToChatMessages(r.FileRole)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, message := range messages {
+		for index := range message.Images {
+			message.Images[index].Detail = r.ImageDetail
+		}
+	}
+
+	return messages, nil
+}
+
+// renderChatMessages renders messages as the openai output format: a JSON
+// object with a "messages" array ready for the chat completions API. A
+// message left empty once its fenced image is removed is omitted.
+func renderChatMessages(messages []ChatMessage) ([]byte, error) {
+	messages = slices.DeleteFunc(slices.Clone(messages), func(message ChatMessage) bool {
+		return message.textContent() == "" && len(message.Images) == 0
+	})
+
+	data, err := json.MarshalIndent(map[string][]ChatMessage{"messages": messages}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat messages: %w", err)
+	}
+
+	return append(data, '\n'), nil
+}
+
+// ChatTemplate renders the built prompt's chat messages with the named
+// chat template.
+func (r *BuildResult) ChatTemplate(name string) (string, error) {
+	messages, err := r.ToChatMessages()
+	if err != nil {
+		return "", err
+	}
+
+	return ApplyChatTemplate(name, messages)
+}
@@ -0,0 +1,205 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestBuildResult_ToChatMessagesSystemFileRole(t *testing.T) {
+	t.Parallel()
+
+	tmpFileName, _, cleanup := setupFileProcessorTest(t)
+	t.Cleanup(cleanup)
+
+	result, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:        "Explain this code",
+		SystemMessage: "You are a reviewer.",
+		File:          tmpFileName,
+		FileRole:      promptbuilder.RoleSystem,
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	messages, err := result.ToChatMessages()
+	if err != nil {
+		t.Fatalf("ToChatMessages() unexpected error = %v", err)
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+
+	if messages[0].Role != "system" || !strings.Contains(messages[0].Content, tmpFileName) {
+		t.Errorf("Expected file content in system message, got %+v", messages[0])
+	}
+
+	if messages[1].Role != "user" || strings.Contains(messages[1].Content, tmpFileName) {
+		t.Errorf("Expected user message without file content, got %+v", messages[1])
+	}
+
+	encoded, err := json.Marshal(messages)
+	if err != nil {
+		t.Fatalf("json.Marshal() unexpected error = %v", err)
+	}
+
+	if !strings.HasPrefix(string(encoded), `[{"role":"system","content":"You are a reviewer.`) {
+		t.Errorf("Unexpected OpenAI message array: %s", encoded)
+	}
+}
+
+func TestPrompt_ToChatMessagesDefaultsToUser(t *testing.T) {
+	t.Parallel()
+
+	prompt := &promptbuilder.Prompt{UserPrompt: "Review", FileContent: "package main"}
+
+	messages, err := prompt.ToChatMessages("")
+	if err != nil {
+		t.Fatalf("ToChatMessages() unexpected error = %v", err)
+	}
+
+	if len(messages) != 1 || messages[0].Role != "user" {
+		t.Fatalf("Expected a single user message, got %+v", messages)
+	}
+
+	if messages[0].Content != "File content:\n\npackage main\n\nReview" {
+		t.Errorf("Unexpected user content %q", messages[0].Content)
+	}
+
+	_, err = prompt.ToChatMessages("assistant")
+	if !errors.Is(err, promptbuilder.ErrUnknownFileRole) {
+		t.Errorf("Expected ErrUnknownFileRole, got %v", err)
+	}
+}
+
+func TestApplyChatTemplate_ChatML(t *testing.T) {
+	t.Parallel()
+
+	result, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:        "Explain this code",
+		SystemMessage: "You are a reviewer.",
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	text, err := result.ChatTemplate(promptbuilder.ChatTemplateChatML)
+	if err != nil {
+		t.Fatalf("ChatTemplate() unexpected error = %v", err)
+	}
+
+	system := regexp.MustCompile(`(?s)<\|im_start\|>system\n(.*?)<\|im_end\|>`).FindStringSubmatch(text)
+	if system == nil || !strings.Contains(system[1], "You are a reviewer.") {
+		t.Errorf("Expected system message inside im_start/im_end tags, got %q", text)
+	}
+
+	user := regexp.MustCompile(`(?s)<\|im_start\|>user\n(.*?)<\|im_end\|>`).FindStringSubmatch(text)
+	if user == nil || !strings.Contains(user[1], "Explain this code") || strings.Contains(user[1], "You are a reviewer.") {
+		t.Errorf("Expected only the user prompt inside the user tags, got %q", text)
+	}
+
+	if !strings.HasSuffix(text, "<|im_start|>assistant\n") {
+		t.Errorf("Expected an open assistant turn at the end, got %q", text)
+	}
+}
+
+func TestApplyChatTemplate_UnknownTemplate(t *testing.T) {
+	t.Parallel()
+
+	_, err := promptbuilder.ApplyChatTemplate("vicuna", []promptbuilder.ChatMessage{{Role: "user", Content: "Hi"}})
+	if !errors.Is(err, promptbuilder.ErrUnknownChatTemplate) {
+		t.Errorf("Expected ErrUnknownChatTemplate, got %v", err)
+	}
+}
+
+func TestRunCLI_OpenAIImageDetail(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	args := []string{
+		"-p", "Describe this chart", "-img", sampleImageB64Part1 + sampleImageB64Part2,
+		"--image-detail", promptbuilder.ImageDetailHigh, "-o", promptbuilder.FormatOpenAI,
+	}
+
+	err := promptbuilder.RunCLI(args, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	var output struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content []struct {
+				Type     string `json:"type"`
+				Text     string `json:"text"`
+				ImageURL struct {
+					URL    string `json:"url"`
+					Detail string `json:"detail"`
+				} `json:"image_url"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+
+	err = json.Unmarshal(buf.Bytes(), &output)
+	if err != nil {
+		t.Fatalf("Failed to decode openai output %q: %v", buf.String(), err)
+	}
+
+	if len(output.Messages) != 1 || len(output.Messages[0].Content) != 2 {
+		t.Fatalf("Expected one user message with text and image parts, got %s", buf.String())
+	}
+
+	text, image := output.Messages[0].Content[0], output.Messages[0].Content[1]
+
+	if text.Type != "text" || strings.Contains(text.Text, "data:image") {
+		t.Errorf("Expected a text part without the inlined image, got %+v", text)
+	}
+
+	if image.Type != "image_url" || !strings.HasPrefix(image.ImageURL.URL, "data:image/png;base64,") {
+		t.Errorf("Expected an image_url part with a PNG data URI, got %+v", image)
+	}
+
+	if image.ImageURL.Detail != promptbuilder.ImageDetailHigh {
+		t.Errorf("Detail = %q, want %q", image.ImageURL.Detail, promptbuilder.ImageDetailHigh)
+	}
+
+	if !strings.Contains(buf.String(), `"detail": "high"`) {
+		t.Errorf("Expected detail \"high\" in the output, got %s", buf.String())
+	}
+}
+
+func TestRunCLI_ChatTemplateKeepsImage(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	args := []string{
+		"-p", "Describe this chart", "-img", sampleImageB64Part1 + sampleImageB64Part2,
+		"--chat-template", promptbuilder.ChatTemplateChatML,
+	}
+
+	err := promptbuilder.RunCLI(args, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "data:image/png;base64,") {
+		t.Errorf("Expected the chat template output to keep the image, got %s", buf.String())
+	}
+}
+
+func TestParseFlags_UnknownImageDetail(t *testing.T) {
+	t.Parallel()
+
+	_, err := promptbuilder.ParseFlags([]string{"-p", "Describe", "--image-detail", "medium"})
+	if !errors.Is(err, promptbuilder.ErrUnknownImageDetail) {
+		t.Errorf("Expected ErrUnknownImageDetail, got %v", err)
+	}
+}
@@ -1,53 +1,848 @@
 package promptbuilder
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
 	defaultMaxFileSize = 1024 * 1024 // 1MB default max file size
 )
 
+// Version is the prompt-builder version reported by --head-comment. Release
+// builds set it at link time with
+// -ldflags "-X github.com/book-expert/prompt-builder/promptbuilder.Version=v1.2.3".
+var Version = "dev"
+
+// defaultAllowedExtensions lists the file extensions the CLI accepts by
+// default. Others are accepted only when named with --ext or in a project
+// file.
+var defaultAllowedExtensions = []string{".png"}
+
 // ParseFlags parses command line arguments into a CLIFlags struct. This function
 // is responsible for defining and parsing all the command line flags that the
 // application accepts.
 func ParseFlags(args []string) (*CLIFlags, error) {
-	flagSet := flag.NewFlagSet("prompt-builder", flag.ExitOnError)
+	flags, err := parseFlags(args, "")
+	if err != nil {
+		return nil, err
+	}
 
+	return flags, nil
+}
+
+// parseFlags implements ParseFlags, taking defaults from the project file at
+// projectPath when it is not empty. When only validation fails, it returns
+// the parsed flags along with the error.
+func parseFlags(args []string, projectPath string) (*CLIFlags, error) {
 	var flags CLIFlags
 
+	flagSet := newFlagSet(&flags, flag.ExitOnError)
+
+	// The project file and then environment variables act as defaults that
+	// the arguments override
+	if projectPath != "" {
+		err := applyProjectFile(flagSet, &flags, projectPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := applyEnvFlags(flagSet)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the flags
+	err = flagSet.Parse(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	// Validate the flags
+	err = flags.Validate()
+	if err != nil {
+		return &flags, fmt.Errorf("invalid flags: %w", err)
+	}
+
+	return &flags, nil
+}
+
+// newFlagSet defines every command line flag on a new flag set that parses
+// into flags.
+func newFlagSet(flags *CLIFlags, errorHandling flag.ErrorHandling) *flag.FlagSet {
+	flagSet := flag.NewFlagSet("prompt-builder", errorHandling)
+
 	flagSet.StringVar(&flags.Prompt, "p", "", "User prompt text (required)")
 	flagSet.StringVar(&flags.Prompt, "prompt", "", "User prompt text (required)")
-	flagSet.StringVar(&flags.File, "f", "", "Optional file to include in context")
-	flagSet.StringVar(&flags.File, "file", "", "Optional file to include in context")
+	flagSet.Var(fileFlag{flags: flags}, "f", "File to include in context (repeatable)")
+	flagSet.Var(fileFlag{flags: flags}, "file", "File to include in context (repeatable)")
+	flagSet.Var(extFlag{flags: flags}, "ext", "Also accept files with this extension, e.g. .go (repeatable)")
+	flagSet.Var(excludeGlobFlag{flags: flags}, "exclude-glob",
+		"Skip files matching this pattern when expanding directories and globs (repeatable)")
+	flagSet.StringVar(&flags.OnUnreadable, "on-unreadable", "",
+		"How to handle unreadable files found in directories and globs: fail, skip, or skip-with-warning (default)")
+	flagSet.StringVar(&flags.FilesFrom, "files-from", "", "File listing paths to include, one per line")
+	flagSet.Var(funcFlag{flags: flags}, "func", "Include only this function of a Go file, as file.go:Name (repeatable)")
+	flagSet.Var(commandFlag{flags: flags}, "cmd", "Run a command and include its output as context (repeatable)")
+	flagSet.Var(allowCommandFlag{flags: flags}, "allow-cmd",
+		"Allow --cmd to run this program, or with --allow-shell this command line (repeatable)")
+	flagSet.BoolVar(&flags.AllowShell, "allow-shell", false,
+		"Run --cmd and --post-process commands with sh -c, allowing pipes and other shell syntax")
+	flagSet.StringVar(&flags.PostProcess, "post-process", "",
+		"Pipe the rendered output through this command and write its stdout instead")
+	flagSet.StringVar(&flags.SortFilesBy, "sort", "", "Order attached files by name, size, or mtime")
+	flagSet.BoolVar(&flags.SortReverse, "reverse", false, "Reverse the --sort order")
+	flagSet.IntVar(&flags.MaxIncluded, "max-included", 0,
+		"Include only the first N files in --sort order and warn about the rest")
 	flagSet.StringVar(&flags.Task, "t", "", "Task preset for system message")
 	flagSet.StringVar(&flags.Task, "task", "", "Task preset for system message")
+	flagSet.BoolVar(&flags.WildcardTask, "wildcard-tasks", false,
+		"Let -t match presets by pattern (coding-*) or fall back to a base preset (coding-rust -> coding)")
 	flagSet.StringVar(&flags.SystemMessage, "sys", "", "Custom system message")
 	flagSet.StringVar(&flags.SystemMessage, "system", "", "Custom system message")
-	flagSet.StringVar(&flags.Guidelines, "g", "", "Guidelines to follow")
-	flagSet.StringVar(&flags.Guidelines, "guidelines", "", "Guidelines to follow")
-	flagSet.StringVar(&flags.OutputFormat, "o", "", "Output format (json, text, markdown)")
-	flagSet.StringVar(&flags.OutputFormat, "output", "", "Output format (json, text, markdown)")
-	flagSet.StringVar(&flags.Image, "img", "", "Base64 encoded image data")
-	flagSet.StringVar(&flags.Image, "image", "", "Base64 encoded image data")
+	flagSet.Var(guidelineFlag{flags: flags}, "g", "Guideline to follow (repeatable; several render as a bullet list)")
+	flagSet.Var(guidelineFlag{flags: flags}, "guidelines", "Guideline to follow (repeatable; several render as a bullet list)")
+	flagSet.StringVar(&flags.OutputFormat, "o", "", "Output format (json, ndjson, text, markdown, csv, shell, yaml, heredoc, openai)")
+	flagSet.StringVar(&flags.OutputFormat, "output", "", "Output format (json, ndjson, text, markdown, csv, shell, yaml, heredoc, openai)")
+	flagSet.StringVar(&flags.OutputFile, "output-file", "",
+		"Write the output to this file; the format follows its extension unless -o is given")
+	flagSet.BoolVar(&flags.Edit, "edit", false,
+		"Compose the prompt in $VISUAL or $EDITOR, starting from the -p text if given")
+	flagSet.BoolVar(&flags.Clipboard, "clipboard", false, "Also copy the output to the system clipboard")
+	flagSet.StringVar(&flags.Encoding, "output-encoding", EncodingUTF8, "Output character encoding (utf-8, latin1)")
+	flagSet.StringVar(&flags.Image, "img", "", "Base64 encoded image data, or - to read raw bytes from stdin")
+	flagSet.StringVar(&flags.Image, "image", "", "Base64 encoded image data, or - to read raw bytes from stdin")
+	flagSet.StringVar(&flags.ImageHex, "image-hex", "", "Hex encoded image data (cannot be combined with -img)")
+	flagSet.StringVar(&flags.ImageDetail, "image-detail", "",
+		"Vision detail of the image in openai output (low, high, auto)")
+	flagSet.StringVar(&flags.ImageByRef, "image-by-ref", "", "Reference an image by path instead of inlining it as base64")
+	flagSet.StringVar(&flags.ChatTemplate, "chat-template", "",
+		"Wrap the prompt in a model chat template (chatml, llama2, alpaca)")
+	flagSet.StringVar(&flags.SplitOn, "split-on", "",
+		"Read several prompts from stdin separated by this delimiter (escapes like \\n are interpreted)")
+	flagSet.StringVar(&flags.Batch, "batch", "", "JSON Lines file of build requests; results are written as NDJSON")
+	flagSet.StringVar(&flags.DataFile, "data", "", "JSON file with variables for prompt, guideline, and preset templates")
+	flagSet.Var(varFlag{flags: flags}, "var", "Template variable as key=value (repeatable)")
+	flagSet.Var(noteFlag{flags: flags}, "note", "Note shown in a file's fence header as path=text (repeatable)")
+	flagSet.StringVar(&flags.TemplatesDir, "templates-dir", "", "Directory of .tmpl partials usable with {{template \"name\" .}}")
+	flagSet.StringVar(&flags.TemplateFile, "template-file", "", "File holding the prompt as a text/template")
+	flagSet.StringVar(&flags.PromptPrefix, "prompt-prefix", "", "Text placed before the user prompt")
+	flagSet.StringVar(&flags.PromptSuffix, "prompt-suffix", "", "Text placed after the user prompt")
+	flagSet.IntVar(&flags.Wrap, "wrap", 0, "Hard-wrap prompt text at N columns, leaving code fences intact")
+	flagSet.StringVar(&flags.Only, "only", "", "Comma separated sections to keep (context, system, guidelines, files, user)")
+	flagSet.StringVar(&flags.Exclude, "exclude", "", "Comma separated sections to drop")
+	flagSet.BoolVar(&flags.FileSections, "per-file-sections", false,
+		"In markdown output, give each file its own ## heading and code block")
+	flagSet.BoolVar(&flags.JSONFiles, "json-files", false,
+		"In json output, give each file's content in the files array instead of file_content")
+	flagSet.StringVar(&flags.JSONKeys, "json-keys", "",
+		"Key naming style of json and ndjson output: snake (default) or camel")
+	flagSet.BoolVar(&flags.HeadComment, "head-comment", false,
+		"Start the output with a comment naming the tool version and build time")
+	flagSet.StringVar(&flags.SignKeyFile, "sign-key-file", "",
+		"Sign the output with HMAC-SHA256 under the key in this file (a signature field in JSON, a final line otherwise)")
+	flagSet.StringVar(&flags.SignKeyEnv, "sign-key-env", "",
+		"Like --sign-key-file, with the key read from this environment variable")
+	flagSet.StringVar(&flags.SplitOutput, "split-output", "", "Write each section to its own file in this directory")
+	flagSet.StringVar(&flags.Publish, "publish", "", "Publish the prompt as JSON to this NATS subject instead of writing it")
+	flagSet.StringVar(&flags.NATSURL, "nats-url", defaultNATSURL, "NATS server URL used by --publish")
+	flagSet.StringVar(&flags.NATSCreds, "nats-creds", "", "NATS credentials file used by --publish")
+	flagSet.DurationVar(&flags.Timeout, "timeout", 0, "Abort the build and any --post-process command after this long, e.g. 30s (0 disables)")
+	flagSet.BoolVar(&flags.NumberedGuidelines, "numbered-guidelines", false,
+		"Render several -g guidelines as numbered steps instead of bullets")
+	flagSet.BoolVar(&flags.LabelSystem, "label-system", false, "Label the system message \"System:\" like the other sections")
+	flagSet.StringVar(&flags.Separator, "separator", "", "Text placed between sections instead of a blank line; escapes like \\n are interpreted")
+	flagSet.BoolVar(&flags.ListLanguages, "list-languages", false, "Print the extension to fence language table and exit")
+	flagSet.BoolVar(&flags.Breakdown, "token-breakdown", false,
+		"Print the estimated tokens of each section instead of the prompt")
+	flagSet.BoolVar(&flags.Explain, "explain", false, "Print how the prompt was assembled instead of the prompt")
+	flagSet.Var(trimFlag{flags: flags}, "trim", "Trim trailing whitespace from the system message, guidelines, and prompt")
+	flagSet.BoolVar(&flags.Normalize, "normalize", false, "Apply NFC normalization and strip zero-width characters")
+	flagSet.BoolVar(&flags.Signatures, "signatures-only", false,
+		"Include only package-level declarations of .go files, without function bodies")
+	flagSet.Var(langFlag{flags: flags}, "lang", "Force a fence language as pattern=language, e.g. Dockerfile=dockerfile (repeatable)")
+	flagSet.BoolVar(&flags.TOC, "toc", false, "List the attached files with their line counts before them, when there are several")
+	flagSet.BoolVar(&flags.Manifest, "manifest", false, "Append a manifest with the SHA-256 digest and size of each file")
+	flagSet.BoolVar(&flags.StripFrontMatter, "strip-frontmatter", false,
+		"Remove front matter from .md files; json output lists it per file")
+	flagSet.BoolVar(&flags.ImagesAsBase64, "include-binary-as-base64", false,
+		"Include image files found in directories as base64 data URIs instead of raw bytes")
+	flagSet.BoolVar(&flags.ValidateSyntax, "validate-syntax", false, "Fail when an attached .go or .json file does not parse")
+	flagSet.BoolVar(&flags.CollapseBlanks, "collapse-blanks", false, "Collapse runs of blank lines in attached files into one")
+	flagSet.BoolVar(&flags.LineNumbers, "line-numbers", false, "Prefix each line of attached files with its line number")
+	flagSet.BoolVar(&flags.AllowURLs, "allow-urls", false, "Allow -f to include remote http(s) files")
+	flagSet.BoolVar(&flags.WithGit, "with-git", false, "Show the last commit touching each attached file")
+	flagSet.BoolVar(&flags.WithContext, "with-context", false, "Prepend OS, Go version, cwd, and date context")
+	flagSet.BoolVar(&flags.JSONErrors, "json-errors", false,
+		"Write errors to stderr as JSON objects with a stable code field")
+	flagSet.BoolVar(&flags.NoProject, "no-project", false,
+		"Ignore "+ProjectFileName+" files in the working directory and its parents")
+	flagSet.BoolVar(&flags.ShowConfig, "show-config", false,
+		"Print the resolved flags and build request as JSON instead of building")
 
-	// Parse the flags
-	err := flagSet.Parse(args)
+	return flagSet
+}
+
+// envFlagPrefix starts the environment variable that sets a flag's default:
+// --output is read from PROMPT_BUILDER_OUTPUT.
+const envFlagPrefix = "PROMPT_BUILDER_"
+
+// envFlags lists the flags whose defaults may come from the environment, by
+// their long names. Flags that run commands, reach the network, read or write
+// files, or carry secrets are deliberately absent so that they are only ever
+// turned on from the command line.
+var envFlags = []string{
+	"task", "output", "output-encoding", "sort", "reverse", "wrap", "timeout",
+	"json-keys", "json-errors", "chat-template", "image-detail", "numbered-guidelines",
+	"label-system", "trim", "normalize", "line-numbers", "collapse-blanks",
+	"strip-frontmatter", "validate-syntax", "toc", "manifest", "on-unreadable",
+	"no-project",
+}
+
+// envFlagName returns the environment variable read for the named flag.
+func envFlagName(name string) string {
+	return envFlagPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnvFlags sets each of the envFlags that has a matching environment
+// variable. It runs before the arguments are parsed, so arguments take
+// precedence.
+func applyEnvFlags(flagSet *flag.FlagSet) error {
+	for _, name := range envFlags {
+		value, ok := os.LookupEnv(envFlagName(name))
+		if !ok {
+			continue
+		}
+
+		err := flagSet.Set(name, value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", envFlagName(name), err)
+		}
+	}
+
+	return nil
+}
+
+// probeFlags parses args and the environment leniently, for the few flags
+// that must be known before the full parse: invalid values are ignored and
+// parsing stops at the first invalid argument.
+func probeFlags(args []string) *CLIFlags {
+	var flags CLIFlags
+
+	flagSet := newFlagSet(&flags, flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+
+	_ = applyEnvFlags(flagSet)
+	_ = flagSet.Parse(args)
+
+	return &flags
+}
+
+// discoverProjectFile returns the nearest project file above the working
+// directory, or "" when there is none or args give --no-project.
+func discoverProjectFile(args []string) (string, error) {
+	if probeFlags(args).NoProject {
+		return "", nil
+	}
+
+	cwd, err := os.Getwd()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse flags: %w", err)
+		return "", fmt.Errorf("failed to get current working directory: %w", err)
 	}
 
-	// Validate the flags
-	err = flags.Validate()
+	path, _ := FindProjectFile(cwd)
+
+	return path, nil
+}
+
+// applyProjectFile loads the project file at path, setting the -t and -o
+// defaults and the allowed extensions and presets it declares.
+func applyProjectFile(flagSet *flag.FlagSet, flags *CLIFlags, path string) error {
+	config, err := LoadProjectConfig(path)
 	if err != nil {
-		return nil, fmt.Errorf("invalid flags: %w", err)
+		return err
 	}
 
-	return &flags, nil
+	flags.ProjectFile = path
+	flags.Extensions = config.Extensions
+	flags.Presets = config.Presets
+
+	for name, value := range map[string]string{"task": config.Task, "output": config.Output} {
+		if value == "" {
+			continue
+		}
+
+		err = flagSet.Set(name, value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid %s: %w", path, name, err)
+		}
+	}
+
+	return nil
+}
+
+// fileFlag collects repeated -f/--file values. The first file populates
+// CLIFlags.File and any further files are appended to CLIFlags.Files.
+type fileFlag struct {
+	flags *CLIFlags
+}
+
+// String returns the files collected so far.
+func (v fileFlag) String() string {
+	if v.flags == nil {
+		return ""
+	}
+
+	return strings.Join(append([]string{v.flags.File}, v.flags.Files...), ",")
+}
+
+// Set records one file path.
+func (v fileFlag) Set(value string) error {
+	if v.flags.File == "" {
+		v.flags.File = value
+
+		return nil
+	}
+
+	v.flags.Files = append(v.flags.Files, value)
+
+	return nil
+}
+
+// guidelineFlag collects repeated -g/--guidelines values. The first guideline
+// populates CLIFlags.Guidelines and any further ones are appended to
+// CLIFlags.GuidelineList.
+type guidelineFlag struct {
+	flags *CLIFlags
+}
+
+// String returns the guidelines collected so far.
+func (v guidelineFlag) String() string {
+	if v.flags == nil {
+		return ""
+	}
+
+	return strings.Join(append([]string{v.flags.Guidelines}, v.flags.GuidelineList...), ",")
+}
+
+// Set records one guideline.
+func (v guidelineFlag) Set(value string) error {
+	if v.flags.Guidelines == "" {
+		v.flags.Guidelines = value
+
+		return nil
+	}
+
+	v.flags.GuidelineList = append(v.flags.GuidelineList, value)
+
+	return nil
+}
+
+// extFlag collects repeated --ext extensions.
+type extFlag struct {
+	flags *CLIFlags
+}
+
+// String returns the extensions collected so far.
+func (v extFlag) String() string {
+	if v.flags == nil {
+		return ""
+	}
+
+	return strings.Join(v.flags.ExtraExts, ",")
+}
+
+// Set records one extension, adding the leading dot when it is missing.
+func (v extFlag) Set(value string) error {
+	value = strings.TrimSpace(value)
+	if strings.Trim(value, ".") == "" {
+		return fmt.Errorf("%w: empty extension", ErrFileExtensionRequired)
+	}
+
+	if !strings.HasPrefix(value, ".") {
+		value = "." + value
+	}
+
+	v.flags.ExtraExts = append(v.flags.ExtraExts, value)
+
+	return nil
+}
+
+// trimFlag sets --trim, which is on by default, as the inverse of
+// KeepWhitespace so that the zero CLIFlags trims like the command line.
+type trimFlag struct {
+	flags *CLIFlags
+}
+
+// String reports whether whitespace is trimmed.
+func (v trimFlag) String() string {
+	// The flag package compares the default against the zero value, so it
+	// must differ for the flag defaults to show "(default true)"
+	if v.flags == nil {
+		return ""
+	}
+
+	return strconv.FormatBool(!v.flags.KeepWhitespace)
+}
+
+// Set records whether to trim whitespace.
+func (v trimFlag) Set(value string) error {
+	trim, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid --trim value %q: %w", value, err)
+	}
+
+	v.flags.KeepWhitespace = !trim
+
+	return nil
+}
+
+// IsBoolFlag lets --trim be given without a value.
+func (v trimFlag) IsBoolFlag() bool { return true }
+
+// excludeGlobFlag collects repeated --exclude-glob patterns.
+type excludeGlobFlag struct {
+	flags *CLIFlags
+}
+
+// String returns the patterns collected so far.
+func (v excludeGlobFlag) String() string {
+	if v.flags == nil {
+		return ""
+	}
+
+	return strings.Join(v.flags.ExcludeGlobs, ",")
+}
+
+// Set records one pattern after checking that it is well formed.
+func (v excludeGlobFlag) Set(value string) error {
+	_, err := filepath.Match(value, "")
+	if err != nil {
+		return fmt.Errorf("invalid exclude pattern %q: %w", value, err)
+	}
+
+	v.flags.ExcludeGlobs = append(v.flags.ExcludeGlobs, value)
+
+	return nil
+}
+
+// commandFlag collects repeated --cmd commands.
+type commandFlag struct {
+	flags *CLIFlags
+}
+
+// String returns the commands collected so far.
+func (c commandFlag) String() string {
+	if c.flags == nil {
+		return ""
+	}
+
+	return strings.Join(c.flags.Commands, ",")
+}
+
+// Set records one command.
+func (c commandFlag) Set(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return ErrEmptyCommand
+	}
+
+	c.flags.Commands = append(c.flags.Commands, value)
+
+	return nil
+}
+
+// allowCommandFlag collects repeated --allow-cmd entries.
+type allowCommandFlag struct {
+	flags *CLIFlags
+}
+
+// String returns the allowed commands collected so far.
+func (c allowCommandFlag) String() string {
+	if c.flags == nil {
+		return ""
+	}
+
+	return strings.Join(c.flags.AllowedCommands, ",")
+}
+
+// Set records one allowed command.
+func (c allowCommandFlag) Set(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ErrEmptyCommand
+	}
+
+	c.flags.AllowedCommands = append(c.flags.AllowedCommands, value)
+
+	return nil
+}
+
+// funcFlag collects repeated --func path.go:Name references.
+type funcFlag struct {
+	flags *CLIFlags
+}
+
+// String returns the references collected so far.
+func (v funcFlag) String() string {
+	if v.flags == nil {
+		return ""
+	}
+
+	return strings.Join(v.flags.Functions, ",")
+}
+
+// Set records one reference after checking its form.
+func (v funcFlag) Set(value string) error {
+	_, _, err := splitFunctionRef(value)
+	if err != nil {
+		return err
+	}
+
+	v.flags.Functions = append(v.flags.Functions, value)
+
+	return nil
+}
+
+// varFlag collects repeated --var key=value template variables.
+type varFlag struct {
+	flags *CLIFlags
+}
+
+// String returns the variables collected so far.
+func (v varFlag) String() string {
+	if v.flags == nil {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(v.flags.Vars))
+	for key, value := range v.flags.Vars {
+		pairs = append(pairs, key+"="+value)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// Set records one key=value pair.
+func (v varFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidVar, value)
+	}
+
+	if v.flags.Vars == nil {
+		v.flags.Vars = make(map[string]string)
+	}
+
+	v.flags.Vars[strings.TrimSpace(key)] = val
+
+	return nil
+}
+
+// noteFlag collects repeated --note path=text file notes.
+type noteFlag struct {
+	flags *CLIFlags
+}
+
+// String returns the notes collected so far.
+func (n noteFlag) String() string {
+	if n.flags == nil {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(n.flags.Notes))
+	for path, note := range n.flags.Notes {
+		pairs = append(pairs, path+"="+note)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// Set records one path=text note.
+func (n noteFlag) Set(value string) error {
+	path, note, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(path) == "" || strings.TrimSpace(note) == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidNote, value)
+	}
+
+	if n.flags.Notes == nil {
+		n.flags.Notes = make(map[string]string)
+	}
+
+	n.flags.Notes[strings.TrimSpace(path)] = note
+
+	return nil
+}
+
+// langFlag collects repeated --lang pattern=language overrides.
+type langFlag struct {
+	flags *CLIFlags
+}
+
+// String returns the overrides collected so far.
+func (v langFlag) String() string {
+	if v.flags == nil {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(v.flags.Languages))
+	for pattern, language := range v.flags.Languages {
+		pairs = append(pairs, pattern+"="+language)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// Set records one pattern=language pair.
+func (v langFlag) Set(value string) error {
+	pattern, language, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(pattern) == "" || strings.TrimSpace(language) == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidLanguage, value)
+	}
+
+	if v.flags.Languages == nil {
+		v.flags.Languages = make(map[string]string)
+	}
+
+	v.flags.Languages[strings.TrimSpace(pattern)] = strings.TrimSpace(language)
+
+	return nil
+}
+
+// newCLIBuilder creates a prompt builder configured from the CLI flags with the
+// default system presets registered.
+func newCLIBuilder(flags *CLIFlags) (*Builder, error) {
+	// Create file processor with reasonable defaults
+	allowedExtensions := defaultAllowedExtensions
+	if len(flags.Extensions) > 0 {
+		allowedExtensions = flags.Extensions
+	}
+
+	allowedExtensions = slices.Concat(allowedExtensions, flags.ExtraExts)
+
+	fileProcessor := NewFileProcessor(defaultMaxFileSize, allowedExtensions)
+	fileProcessor.IncludeGitInfo = flags.WithGit
+	fileProcessor.AllowURLs = flags.AllowURLs
+	fileProcessor.SignaturesOnly = flags.Signatures
+	fileProcessor.LineNumbers = flags.LineNumbers
+	fileProcessor.CollapseBlanks = flags.CollapseBlanks
+	fileProcessor.StripFrontMatter = flags.StripFrontMatter
+	fileProcessor.ExcludeGlobs = flags.ExcludeGlobs
+	fileProcessor.ValidateSyntax = flags.ValidateSyntax
+	fileProcessor.ImagesAsBase64 = flags.ImagesAsBase64
+
+	unreadable, err := ParseUnreadablePolicy(flags.OnUnreadable)
+	if err != nil {
+		return nil, err
+	}
+
+	fileProcessor.UnreadableFiles = unreadable
+
+	for pattern, language := range flags.Languages {
+		err := fileProcessor.SetLanguageForPath(pattern, language)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Create prompt builder
+	builder := New(fileProcessor)
+	builder.ValidateImages = true
+	builder.WildcardPresets = flags.WildcardTask
+
+	if flags.TemplatesDir != "" {
+		err := builder.LoadTemplateDir(flags.TemplatesDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Add some default system presets
+	codingPreset := "You are an expert software developer. Write clean, efficient, and well-documented code."
+
+	err = builder.AddSystemPreset("coding", codingPreset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add coding preset: %w", err)
+	}
+
+	analysisPreset := "You are an expert code analyst. Provide detailed analysis and insights."
+
+	err = builder.AddSystemPreset("analysis", analysisPreset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add analysis preset: %w", err)
+	}
+
+	documentationPreset := "You are an expert technical writer. Create clear and comprehensive documentation."
+
+	err = builder.AddSystemPreset("documentation", documentationPreset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add documentation preset: %w", err)
+	}
+
+	// Presets from the project file replace built-in ones of the same name
+	for name, message := range flags.Presets {
+		err = builder.AddSystemPreset(name, message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add project preset %s: %w", name, err)
+		}
+	}
+
+	return builder, nil
+}
+
+// runBatch builds every request in a JSON Lines file and writes NDJSON results.
+func runBatch(builder *Builder, path string, output io.Writer) error {
+	// #nosec G304 -- The batch file is explicitly supplied by the user; files
+	// referenced by its requests are validated by the file processor.
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open batch file: %w", err)
+	}
+
+	err = builder.BuildBatch(file, output)
+
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+
+	if closeErr != nil {
+		return fmt.Errorf("failed to close batch file: %w", closeErr)
+	}
+
+	return nil
+}
+
+// splitList splits a comma separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+
+	for item := range strings.SplitSeq(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// readFileList reads newline separated paths from a list file. Blank lines and
+// lines starting with # are ignored.
+func readFileList(path string) ([]string, error) {
+	// #nosec G304 -- The list only names paths; each listed file is validated
+	// by the file processor before it is read.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var paths []string
+
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		paths = append(paths, line)
+	}
+
+	return paths, nil
+}
+
+// writeLanguageTable writes one "pattern -> language" line per entry, sorted
+// by pattern.
+func writeLanguageTable(output io.Writer, table map[string]string) error {
+	var builder strings.Builder
+
+	for _, pattern := range slices.Sorted(maps.Keys(table)) {
+		fmt.Fprintf(&builder, "%s -> %s\n", pattern, table[pattern])
+	}
+
+	_, err := io.WriteString(output, builder.String())
+	if err != nil {
+		return fmt.Errorf("failed to write language table: %w", err)
+	}
+
+	return nil
+}
+
+// writeTokenBreakdown writes the estimated tokens of each section in render
+// order, followed by the total.
+func writeTokenBreakdown(output io.Writer, result *BuildResult) error {
+	breakdown := result.TokenBreakdown()
+
+	var builder strings.Builder
+
+	builder.WriteString("Estimated tokens by section:\n")
+
+	for _, section := range result.Prompt.Sections() {
+		fmt.Fprintf(&builder, "- %s: %d\n", section.Name, breakdown[section.Name])
+	}
+
+	fmt.Fprintf(&builder, "- total: %d\n", result.TokenEstimate())
+
+	_, err := io.WriteString(output, builder.String())
+	if err != nil {
+		return fmt.Errorf("failed to write token breakdown: %w", err)
+	}
+
+	return nil
+}
+
+// writeConfig writes the resolved flags and the build request they produce
+// as indented JSON. Standard input is not read, so a stdin image is reported
+// as an error.
+func writeConfig(output io.Writer, flags *CLIFlags) error {
+	req, err := flags.ToBuildRequest()
+	if err != nil {
+		return fmt.Errorf("failed to convert flags to build request: %w", err)
+	}
+
+	data, err := json.MarshalIndent(struct {
+		Flags   *CLIFlags     `json:"flags"`
+		Request *BuildRequest `json:"request"`
+	}{Flags: flags, Request: req}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	_, err = output.Write(append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	return nil
+}
+
+// writeChatTemplate writes the result wrapped in the special tokens of the
+// named chat template, followed by a newline.
+func writeChatTemplate(output io.Writer, result *BuildResult, name string) error {
+	text, err := result.ChatTemplate(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(output, text+"\n")
+	if err != nil {
+		return fmt.Errorf("failed to write chat template: %w", err)
+	}
+
+	return nil
 }
 
 // PrintUsage prints the usage information for the CLI. This function is called
@@ -58,126 +853,509 @@ func PrintUsage() {
 Build prompts from various components including files, system messages, and guidelines.
 
 OPTIONS:
-  -p, --prompt TEXT          User prompt text (required)
-  -f, --file PATH           Optional file to include in context
+  -p, --prompt TEXT          User prompt text (required unless --batch or --template-file is used)
+  -f, --file PATH           File to include in context (repeatable)
+  --ext EXT                 Also accept files with this extension, e.g. .go (repeatable)
+  --exclude-glob PATTERN    Skip files matching this pattern when expanding directories and globs (repeatable)
+  --on-unreadable POLICY    How to handle unreadable files found in directories and globs: fail, skip,
+                            or skip-with-warning (default)
+  --files-from PATH         File listing paths to include, one per line
+  --func FILE:NAME          Include only the named function or Type.Method of a Go file (repeatable)
+  --cmd COMMAND             Run COMMAND and include its stdout as a fenced text block (repeatable)
+  --allow-cmd NAME          Allow --cmd to run program NAME, or with --allow-shell the command line NAME;
+                            --cmd refuses anything not allowed (repeatable)
+  --allow-shell             Run --cmd and --post-process commands with sh -c; otherwise shell
+                            metacharacters are rejected
+  --post-process COMMAND    Pipe the rendered output through COMMAND and write its stdout instead
+  --sort KEY                Order attached files by name, size, or mtime
+  --reverse                 Reverse the --sort order
+  --max-included N          Include only the first N files in --sort order and warn about the rest
   -t, --task TASK           Task preset for system message
+  --wildcard-tasks          Let -t match presets by pattern (coding-*) or fall back to a base preset (coding-rust -> coding)
   -sys, --system TEXT       Custom system message
-  -g, --guidelines TEXT     Guidelines to follow
-  -o, --output FORMAT       Output format (json, text, markdown)
-  -img, --image BASE64      Base64 encoded image data
+  -g, --guidelines TEXT     Guideline to follow (repeatable; several render as a bullet list)
+  --numbered-guidelines     Render several guidelines as numbered steps instead of bullets
+  -o, --output FORMAT       Output format (json, ndjson, text, markdown, csv, shell, yaml, heredoc, openai)
+  --output-file PATH        Write the output to this file; the format follows its extension unless -o is given
+  --edit                    Compose the prompt in $VISUAL or $EDITOR, starting from the -p text if given
+  --clipboard               Also copy the output to the system clipboard
+  --output-encoding NAME    Output character encoding (utf-8, latin1)
+  -img, --image BASE64      Base64 encoded image data, or - to read raw bytes from stdin
+  --image-hex HEX           Hex encoded image data (cannot be combined with -img)
+  --image-detail LEVEL      Vision detail of the image in openai output (low, high, auto)
+  --image-by-ref PATH       Reference an image by path instead of inlining it as base64 (no files, -img, or --image-hex)
+  --chat-template NAME      Wrap the prompt in a chat template's special tokens (chatml, llama2, alpaca)
+  --split-on DELIMITER      Read several prompts from stdin separated by DELIMITER, e.g. '\n---\n'
+  --batch PATH              JSON Lines file of build requests; results are written as NDJSON
+  --data PATH               JSON file with variables for prompt, guideline, and preset templates
+  --var KEY=VALUE           Template variable (repeatable, overrides --data)
+  --note PATH=TEXT          Note for the model shown in the file's fence header (repeatable)
+  --template-file PATH      File holding the prompt as a text/template
+  --templates-dir DIR       Directory of .tmpl partials usable with {{template "name" .}}
+  --prompt-prefix TEXT      Text placed before the user prompt
+  --prompt-suffix TEXT      Text placed after the user prompt
+  --wrap N                  Hard-wrap prompt text at N columns, leaving code fences intact
+  --only SECTIONS           Comma separated sections to keep (context, system, guidelines, files, user)
+  --exclude SECTIONS        Comma separated sections to drop
+  --per-file-sections       In markdown output, give each file its own ## heading and code block
+  --json-files              In json output, give each file's content in the files array instead of file_content
+  --json-keys STYLE         Key naming style of json and ndjson output: snake (default) or camel
+  --head-comment            Start the output with a comment naming the tool version and build time
+                            (not in text, csv, or shell output)
+  --sign-key-file PATH      Sign the output with HMAC-SHA256 under the key in PATH (a signature field in JSON,
+                            a final line otherwise)
+  --sign-key-env NAME       Like --sign-key-file, with the key read from environment variable NAME
+  --split-output DIR        Write each section to its own file in this directory
+  --publish SUBJECT         Publish the prompt as JSON to this NATS subject instead of writing it
+  --nats-url URL            NATS server URL used by --publish (default nats://127.0.0.1:4222)
+  --nats-creds PATH         NATS credentials file used by --publish
+  --timeout DURATION        Abort the build and any --post-process command after this long, e.g. 30s
+                            (0 disables)
+  --label-system            Label the system message "System:" like the other sections
+  --separator TEXT          Text placed between sections instead of a blank line; escapes like \n are interpreted
+  --explain                 Print how the prompt was assembled instead of the prompt
+  --token-breakdown         Print the estimated tokens of each section instead of the prompt
+  --trim                    Trim trailing whitespace from the system message, guidelines, and prompt
+                            (default true; use --trim=false to keep it)
+  --normalize               Apply NFC normalization and strip zero-width characters
+  --signatures-only         Include only package-level declarations of .go files, without function bodies
+  --lang PATTERN=LANGUAGE   Force a fence language, e.g. Dockerfile=dockerfile or tmpl=gotemplate (repeatable)
+  --list-languages          Print the extension to fence language table, including --lang entries, and exit
+  --toc                     List the attached files with their line counts before them, when there are several
+  --manifest                Append a manifest with the SHA-256 digest and size of each file
+  --strip-frontmatter       Remove front matter from .md files; json output lists it per file
+  --include-binary-as-base64
+                            Include image files found in directories as base64 data URIs
+  --validate-syntax         Fail when an attached .go or .json file does not parse
+  --collapse-blanks         Collapse runs of blank lines in attached files into one
+  --line-numbers            Prefix each line of attached files with its line number
+  --allow-urls              Allow -f to include remote http(s) files
+  --with-git                Show the last commit touching each attached file
+  --with-context            Prepend OS, Go version, cwd, and date context
+  --json-errors             Write errors to stderr as JSON, e.g. {"code":"PROMPT_REQUIRED","message":"..."}
+  --no-project              Ignore .promptbuilder.yaml files in the working directory and its parents
+  --show-config             Print the resolved flags and build request as JSON instead of building
   -h, --help                Show this help message
 
+PROJECT FILE:
+  Unless --no-project is given, the nearest .promptbuilder.yaml in the working
+  directory or a parent sets the allowed extensions, extra presets, and the -t
+  and -o defaults:
+    extensions: [.go, .md]
+    task: review
+    output: json
+    presets:
+      review: You are a careful code reviewer.
+
+ENVIRONMENT:
+  These flags can be given a default with PROMPT_BUILDER_<NAME>, where NAME is the
+  flag name in upper case with dashes as underscores, e.g. PROMPT_BUILDER_TASK=coding:
+    task, output, output-encoding, sort, reverse, wrap, timeout, json-keys,
+    json-errors, chat-template, image-detail, numbered-guidelines, label-system,
+    trim, normalize, line-numbers, collapse-blanks, strip-frontmatter,
+    validate-syntax, toc, manifest, on-unreadable, no-project
+  They override the project file, and flags on the command line take precedence.
+  Flags that run commands, reach the network, or read or write files other than
+  the attached ones cannot be set from the environment.
+
 EXAMPLES:
-  prompt-builder -p "Explain this code" -f main.go
-  prompt-builder -p "Refactor this" -f app.py -t coding -g "Follow PEP 8"
-  prompt-builder -p "Analyze this code" -f app.js -o json
+  prompt-builder -p "Explain this code" --ext .go -f main.go
+  prompt-builder -p "Refactor this" --ext .py -f app.py -t coding -g "Follow PEP 8"
+  prompt-builder -p "Analyze this code" --ext .js -f app.js -o json
 `)
 }
 
-// RunCLI runs the CLI application with the given arguments and writes the output
-// to the provided writer. This is the main entry point for the CLI application.
-func RunCLI(args []string, output io.Writer) error {
+// RunCLI runs the CLI application with the given arguments, reading piped data
+// such as "-img -" images from input and writing the output to the provided
+// writer. This is the main entry point for the CLI application. With
+// --json-errors, a failure is also written to stderr as an ErrorReport and the
+// returned error satisfies ErrorReported.
+func RunCLI(args []string, input io.Reader, output io.Writer) error {
+	return RunCLIWithOptions(args, input, output, Options{Clipboard: nil, Editor: nil, Stderr: nil})
+}
+
+// Options replaces the system dependencies of RunCLIWithOptions. Nil fields
+// use the system: SystemClipboard, SystemEditor, and os.Stderr.
+type Options struct {
+	// Clipboard receives the output when --clipboard is given.
+	Clipboard Clipboard
+	// Editor composes the prompt when --edit is given.
+	Editor EditorLauncher
+	// Stderr receives warnings and, with --json-errors, error reports.
+	Stderr io.Writer
+}
+
+// withDefaults returns the options with nil fields set to the system
+// dependencies.
+func (o Options) withDefaults() Options {
+	if o.Clipboard == nil {
+		o.Clipboard = SystemClipboard{}
+	}
+
+	if o.Editor == nil {
+		o.Editor = SystemEditor{}
+	}
+
+	if o.Stderr == nil {
+		o.Stderr = os.Stderr
+	}
+
+	return o
+}
+
+// RunCLIWithOptions is like RunCLI but uses the clipboard, editor, and
+// stderr writer in options.
+func RunCLIWithOptions(args []string, input io.Reader, output io.Writer, options Options) error {
+	options = options.withDefaults()
+
+	flags, err := runCLI(args, input, output, options)
+	if err == nil {
+		return nil
+	}
+
+	// Without parsed flags, for example when the project file is invalid,
+	// fall back to parsing just the arguments and environment.
+	jsonErrors := flags != nil && flags.JSONErrors
+	if flags == nil {
+		jsonErrors = jsonErrorsRequested(args)
+	}
+
+	if !jsonErrors {
+		return err
+	}
+
+	writeErr := WriteErrorJSON(options.Stderr, err)
+	if writeErr != nil {
+		return errors.Join(err, writeErr)
+	}
+
+	return reportedError{err: err}
+}
+
+// runCLI implements RunCLIWithOptions. It returns the parsed flags, which
+// are nil when parsing failed before the arguments were read.
+func runCLI(args []string, input io.Reader, output io.Writer, options Options) (*CLIFlags, error) {
 	// Check for help flag
 	for _, arg := range args {
 		if arg == "-h" || arg == "--help" {
 			PrintUsage()
 
-			return nil
+			return nil, nil
 		}
 	}
 
+	projectPath, err := discoverProjectFile(args)
+	if err != nil {
+		return nil, err
+	}
+
 	// Parse flags
-	flags, err := ParseFlags(args)
+	flags, err := parseFlags(args, projectPath)
 	if err != nil {
-		return fmt.Errorf("failed to parse flags: %w", err)
+		return flags, fmt.Errorf("failed to parse flags: %w", err)
 	}
 
-	// Create file processor with reasonable defaults
-	allowedExtensions := []string{".png"}
+	return flags, runParsed(flags, input, output, options)
+}
 
-	fileProcessor := NewFileProcessor(defaultMaxFileSize, allowedExtensions)
+// runParsed runs the CLI with parsed flags.
+func runParsed(flags *CLIFlags, input io.Reader, output io.Writer, options Options) error {
+	var err error
 
-	// Create prompt builder
-	builder := New(fileProcessor)
+	if flags.Edit {
+		flags.Prompt, err = editPrompt(options.Editor, flags.Prompt)
+		if err != nil {
+			return err
+		}
+	}
 
-	// Add some default system presets
-	codingPreset := "You are an expert software developer. Write clean, efficient, and well-documented code."
+	if !flags.Clipboard {
+		if flags.OutputFile != "" {
+			return runToFile(flags, input, options.Stderr)
+		}
 
-	err = builder.AddSystemPreset("coding", codingPreset)
+		return run(flags, input, output, options.Stderr)
+	}
+
+	var copied bytes.Buffer
+
+	if flags.OutputFile != "" {
+		err = runToFile(flags, input, options.Stderr)
+		if err == nil {
+			err = readOutputFile(flags.OutputFile, &copied)
+		}
+	} else {
+		err = run(flags, input, io.MultiWriter(output, &copied), options.Stderr)
+	}
+
+	if err != nil || copied.Len() == 0 {
+		return err
+	}
+
+	return options.Clipboard.Copy(copied.String())
+}
+
+// readOutputFile reads back the file written by --output-file.
+func readOutputFile(path string, buf *bytes.Buffer) error {
+	// #nosec G304 -- The file was just written at the user's request.
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to add coding preset: %w", err)
+		return fmt.Errorf("failed to read output file: %w", err)
 	}
 
-	analysisPreset := "You are an expert code analyst. Provide detailed analysis and insights."
+	buf.Write(data)
 
-	err = builder.AddSystemPreset("analysis", analysisPreset)
+	return nil
+}
+
+// runToFile runs the CLI with output going to flags.OutputFile. Unless a
+// format was given explicitly, it is inferred from the file's extension.
+func runToFile(flags *CLIFlags, input io.Reader, stderr io.Writer) error {
+	if flags.OutputFormat == "" {
+		flags.OutputFormat = FormatForPath(flags.OutputFile)
+	}
+
+	file, err := os.Create(flags.OutputFile)
 	if err != nil {
-		return fmt.Errorf("failed to add analysis preset: %w", err)
+		return fmt.Errorf("failed to create output file: %w", err)
 	}
 
-	documentationPreset := "You are an expert technical writer. Create clear and comprehensive documentation."
+	err = run(flags, input, file, stderr)
+
+	closeErr := file.Close()
+	if err == nil && closeErr != nil {
+		return fmt.Errorf("failed to close output file: %w", closeErr)
+	}
+
+	return err
+}
+
+// run builds and writes the prompt described by flags, piping it through the
+// --post-process command when one is given. Warnings go to stderr. The
+// --timeout limit covers both the build and the post-process command.
+func run(flags *CLIFlags, input io.Reader, output, stderr io.Writer) error {
+	output, err := NewEncodingWriter(output, flags.Encoding)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if flags.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, flags.Timeout)
+		defer cancel()
+	}
+
+	if flags.PostProcess == "" {
+		return buildAndWrite(ctx, flags, input, output, stderr)
+	}
+
+	var rendered bytes.Buffer
+
+	err = buildAndWrite(ctx, flags, input, &rendered, stderr)
+	if err != nil {
+		return err
+	}
+
+	processed, err := postProcess(ctx, flags.PostProcess, flags.AllowShell, rendered.Bytes())
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrBuildTimeout, err)
+	}
 
-	err = builder.AddSystemPreset("documentation", documentationPreset)
 	if err != nil {
-		return fmt.Errorf("failed to add documentation preset: %w", err)
+		return err
+	}
+
+	_, err = output.Write(processed)
+	if err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return nil
+}
+
+// buildAndWrite builds the prompt described by flags and writes it to output,
+// with warnings going to stderr.
+func buildAndWrite(ctx context.Context, flags *CLIFlags, input io.Reader, output, stderr io.Writer) error {
+	builder, err := newCLIBuilder(flags)
+	if err != nil {
+		return err
+	}
+
+	if flags.ListLanguages {
+		return writeLanguageTable(output, builder.fileProcessor.LanguageTable())
+	}
+
+	if flags.ShowConfig {
+		return writeConfig(output, flags)
+	}
+
+	if flags.Batch != "" {
+		return runBatch(builder, flags.Batch, output)
+	}
+
+	if flags.SplitOn != "" {
+		return runSplit(ctx, builder, flags, input, output, stderr)
 	}
 
 	// Convert flags to build request
-	req, err := flags.ToBuildRequest()
+	req, err := flags.ToBuildRequestWithInput(input)
 	if err != nil {
 		return fmt.Errorf("failed to convert flags to build request: %w", err)
 	}
 
-	// Build the prompt
-	result, err := builder.BuildPrompt(req)
+	result, err := buildCLIPrompt(ctx, builder, req, stderr)
 	if err != nil {
-		return fmt.Errorf("failed to build prompt: %w", err)
+		return err
 	}
 
-	return formatAndWriteOutput(output, flags.OutputFormat, result.Prompt)
+	return writeResult(builder, flags, result, output)
 }
 
-// formatAndWriteOutput formats the prompt according to the specified format and
-// writes it to the output writer. This function is responsible for all the output
-// formatting logic.
-func formatAndWriteOutput(output io.Writer, format string, prompt *Prompt) error {
-	var err error // Declare err here
+// runSplit builds one prompt for each chunk of input between SplitOn
+// delimiters and writes the results separated by the same delimiter. Blank
+// chunks are skipped.
+func runSplit(ctx context.Context, builder *Builder, flags *CLIFlags, input io.Reader, output, stderr io.Writer) error {
+	if input == nil {
+		return ErrNoPromptInput
+	}
+
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return fmt.Errorf("failed to read prompts from input: %w", err)
+	}
+
+	delimiter := unescapeSeparator(flags.SplitOn)
+	rendered := make([]string, 0)
 
-	switch format {
-	case "json":
-		jsonData := map[string]any{
-			"system_message": prompt.SystemMessage,
-			"user_prompt":    prompt.UserPrompt,
-			"file_content":   prompt.FileContent,
-			"guidelines":     prompt.Guidelines,
+	for chunk := range strings.SplitSeq(string(data), delimiter) {
+		if strings.TrimSpace(chunk) == "" {
+			continue
 		}
 
-		jsonBytes, err := json.MarshalIndent(jsonData, "", "  ")
+		chunkFlags := *flags
+		chunkFlags.Prompt = chunk
+
+		req, err := chunkFlags.ToBuildRequest()
 		if err != nil {
-			return fmt.Errorf("failed to marshal JSON: %w", err)
+			return fmt.Errorf("failed to convert flags to build request: %w", err)
 		}
 
-		_, err = fmt.Fprintf(output, "%s\\n", jsonBytes)
+		result, err := buildCLIPrompt(ctx, builder, req, stderr)
 		if err != nil {
-			return fmt.Errorf("failed to write JSON output: %w", err)
+			return fmt.Errorf("prompt %d: %w", len(rendered)+1, err)
 		}
-	case "text":
-		_, err = fmt.Fprintf(output, "%s\\n", prompt.String())
+
+		var buf bytes.Buffer
+
+		err = writeResult(builder, &chunkFlags, result, &buf)
 		if err != nil {
-			return fmt.Errorf("failed to write text output: %w", err)
+			return err
 		}
-	default:
-		// Default to markdown format
-		_, err = fmt.Fprintf(output, "# Generated Prompt\\n\\n")
+
+		rendered = append(rendered, strings.TrimSuffix(buf.String(), "\n"))
+	}
+
+	if len(rendered) == 0 {
+		return ErrPromptRequired
+	}
+
+	_, err = io.WriteString(output, strings.Join(rendered, delimiter)+"\n")
+	if err != nil {
+		return fmt.Errorf("failed to write prompts: %w", err)
+	}
+
+	return nil
+}
+
+// buildCLIPrompt builds req under ctx, which carries the --timeout limit, and
+// writes any warnings to stderr.
+func buildCLIPrompt(ctx context.Context, builder *Builder, req *BuildRequest, stderr io.Writer) (*BuildResult, error) {
+	// Build the prompt
+	result, err := builder.BuildPromptContext(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build prompt: %w", err)
+	}
+
+	for _, warning := range result.Warnings {
+		_, err = fmt.Fprintf(stderr, "warning: %s\n", warning)
 		if err != nil {
-			return fmt.Errorf("failed to write markdown header: %w", err)
+			return nil, fmt.Errorf("failed to write warning: %w", err)
 		}
+	}
+
+	return result, nil
+}
+
+// writeResult post-processes a built prompt as the flags ask and writes it.
+func writeResult(builder *Builder, flags *CLIFlags, result *BuildResult, output io.Writer) error {
+	var err error
 
-		_, err = fmt.Fprintf(output, "```\\n%s\\n```\\n", prompt.String())
+	if flags.Explain {
+		_, err = io.WriteString(output, result.Explain())
 		if err != nil {
-			return fmt.Errorf("failed to write markdown content: %w", err)
+			return fmt.Errorf("failed to write explanation: %w", err)
 		}
+
+		return nil
 	}
 
-	return nil
+	if flags.Only != "" || flags.Exclude != "" {
+		result.Prompt, err = result.Prompt.FilterSections(splitList(flags.Only), splitList(flags.Exclude))
+		if err != nil {
+			return fmt.Errorf("failed to filter sections: %w", err)
+		}
+	}
+
+	if flags.Wrap > 0 {
+		result.Prompt = result.Prompt.Wrapped(flags.Wrap)
+	}
+
+	if flags.Breakdown {
+		return writeTokenBreakdown(output, result)
+	}
+
+	if flags.ChatTemplate != "" {
+		return writeChatTemplate(output, result, flags.ChatTemplate)
+	}
+
+	if flags.SplitOutput != "" {
+		_, err = result.Prompt.WriteSectionFiles(flags.SplitOutput)
+
+		return err
+	}
+
+	if flags.Publish != "" {
+		publisher := NewNATSPublisher(flags.NATSURL)
+		publisher.CredsFile = flags.NATSCreds
+
+		return PublishPrompt(publisher, flags.Publish, result.Prompt)
+	}
+
+	result.SignKey, err = loadSignKey(flags.SignKeyFile, flags.SignKeyEnv)
+	if err != nil {
+		return err
+	}
+
+	result.JSONKeys = flags.JSONKeys
+
+	if flags.HeadComment {
+		result.HeadComment = fmt.Sprintf("generated by prompt-builder %s at %s",
+			Version, time.Now().UTC().Format(time.RFC3339))
+	}
+
+	if flags.FileSections && (flags.OutputFormat == "" || flags.OutputFormat == FormatMarkdown) {
+		_, err = output.Write(builder.RenderFileSections(result, flags.Wrap))
+		if err != nil {
+			return fmt.Errorf("failed to write markdown output: %w", err)
+		}
+
+		return nil
+	}
+
+	_, err = result.WriteFormat(output, flags.OutputFormat)
+
+	return err
 }
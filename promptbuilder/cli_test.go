@@ -3,6 +3,13 @@ package promptbuilder_test
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/book-expert/prompt-builder/promptbuilder"
@@ -12,8 +19,28 @@ const (
 	// sampleImageB64 is a very small 1x1 PNG used in tests.
 	sampleImageB64Part1 = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAA"
 	sampleImageB64Part2 = "AAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII="
+
+	// sampleImageHex is the same 1x1 PNG, hex encoded.
+	sampleImageHex = "89504e470d0a1a0a0000000d4948445200000001000000010804000000b51c0c0200" +
+		"00000b4944415478da6364600000000600023081d02f0000000049454e44ae426082"
 )
 
+// TestMain clears PROMPT_BUILDER_* variables from the environment and turns
+// off project file discovery so that a developer's defaults cannot change the
+// results of CLI tests.
+func TestMain(m *testing.M) {
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, "PROMPT_BUILDER_") {
+			_ = os.Unsetenv(name)
+		}
+	}
+
+	_ = os.Setenv("PROMPT_BUILDER_NO_PROJECT", "true")
+
+	os.Exit(m.Run())
+}
+
 type validateCase struct {
 	name    string
 	flags   promptbuilder.CLIFlags
@@ -246,6 +273,23 @@ func TestParseFlags_WithFile(t *testing.T) {
 	}
 }
 
+func TestParseFlags_WithMultipleFiles(t *testing.T) {
+	t.Parallel()
+
+	flags, parseErr := promptbuilder.ParseFlags([]string{"-p", "test prompt", "-f", "a.go", "--file", "b.go", "-f", "c.go"})
+	if parseErr != nil {
+		t.Fatalf("ParseFlags() unexpected error = %v", parseErr)
+	}
+
+	if flags.File != "a.go" {
+		t.Errorf("Expected file %s, got %s", "a.go", flags.File)
+	}
+
+	if len(flags.Files) != 2 || flags.Files[0] != "b.go" || flags.Files[1] != "c.go" {
+		t.Errorf("Expected additional files [b.go c.go], got %v", flags.Files)
+	}
+}
+
 func TestParseFlags_WithTask(t *testing.T) {
 	t.Parallel()
 
@@ -320,6 +364,11 @@ func TestParseFlags_Errors(t *testing.T) {
 			args:    []string{"-p", ""},
 			wantErr: true,
 		},
+		{
+			name:    "unknown output format should fail",
+			args:    []string{"-p", "test prompt", "-o", "yml"},
+			wantErr: true,
+		},
 	}
 
 	for _, item := range tests {
@@ -371,7 +420,7 @@ func TestRunCLI(t *testing.T) {
 
 			var buf bytes.Buffer
 
-			err := promptbuilder.RunCLI(testCase.args, &buf)
+			err := promptbuilder.RunCLI(testCase.args, nil, &buf)
 			if (err != nil) != testCase.wantErr {
 				t.Errorf("RunCLI() error = %v, wantErr %v", err, testCase.wantErr)
 
@@ -384,3 +433,691 @@ func TestRunCLI(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteNDJSON(t *testing.T) {
+	t.Parallel()
+
+	builder := promptbuilder.New(promptbuilder.NewFileProcessor(1024, []string{".go"}))
+
+	requests := []*promptbuilder.BuildRequest{
+		{Prompt: "first prompt", Guidelines: "Be brief"},
+		{Prompt: "second prompt", SystemMessage: "You are a reviewer"},
+	}
+
+	prompts := make([]*promptbuilder.Prompt, 0, len(requests))
+
+	for _, req := range requests {
+		result, err := builder.BuildPrompt(req)
+		if err != nil {
+			t.Fatalf("BuildPrompt() unexpected error = %v", err)
+		}
+
+		prompts = append(prompts, result.Prompt)
+	}
+
+	var buf bytes.Buffer
+
+	err := promptbuilder.WriteNDJSON(&buf, prompts...)
+	if err != nil {
+		t.Fatalf("WriteNDJSON() unexpected error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(requests) {
+		t.Fatalf("Expected %d lines, got %d: %q", len(requests), len(lines), buf.String())
+	}
+
+	for index, line := range lines {
+		var decoded map[string]string
+
+		err := json.Unmarshal([]byte(line), &decoded)
+		if err != nil {
+			t.Fatalf("Line %d is not valid JSON: %v", index+1, err)
+		}
+
+		if decoded["user_prompt"] != requests[index].Prompt {
+			t.Errorf("Line %d: expected user prompt %q, got %q", index+1, requests[index].Prompt, decoded["user_prompt"])
+		}
+	}
+}
+
+func TestRunCLI_MarkdownFenceEscapesBackticks(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	err := promptbuilder.RunCLI([]string{"-p", "Explain:\n```go\nfmt.Println()\n```"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	output := buf.String()
+	if !strings.HasPrefix(output, "# Generated Prompt\n\n````\n") {
+		t.Errorf("Expected opening four-backtick fence, got %q", output)
+	}
+
+	if !strings.HasSuffix(output, "\n````\n") {
+		t.Errorf("Expected closing four-backtick fence, got %q", output)
+	}
+}
+
+func TestRunCLI_SplitOn(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	input := strings.NewReader("First question\n---\nSecond question\n")
+
+	err := promptbuilder.RunCLI([]string{"--split-on", `\n---\n`, "-o", "text"}, input, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	outputs := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n---\n")
+	if len(outputs) != 2 {
+		t.Fatalf("Expected 2 rendered prompts, got %d: %q", len(outputs), buf.String())
+	}
+
+	if !strings.Contains(outputs[0], "First question") || strings.Contains(outputs[0], "Second question") {
+		t.Errorf("First output = %q, want only the first prompt", outputs[0])
+	}
+
+	if !strings.Contains(outputs[1], "Second question") || strings.Contains(outputs[1], "First question") {
+		t.Errorf("Second output = %q, want only the second prompt", outputs[1])
+	}
+}
+
+func TestRunCLI_FileNote(t *testing.T) {
+	t.Parallel()
+
+	tmpFileName, _, cleanup := setupFileProcessorTest(t)
+	t.Cleanup(cleanup)
+
+	var buf bytes.Buffer
+
+	args := []string{
+		"-p", "Explain this code", "--ext", ".go", "-f", tmpFileName,
+		"--note", tmpFileName + "=this is the legacy version", "-o", "text",
+	}
+
+	err := promptbuilder.RunCLI(args, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	header := regexp.MustCompile("BEGIN (\\S+) — this is the legacy version\n```go\n").FindStringSubmatch(buf.String())
+	if header == nil {
+		t.Fatalf("Expected note in fence header followed by a go fence, got %q", buf.String())
+	}
+
+	if !strings.Contains(buf.String(), "\n```\nEND "+header[1]+"\n") {
+		t.Errorf("Expected END marker without the note, got %q", buf.String())
+	}
+}
+
+func TestRunCLI_ExtWidensAllowedExtensions(t *testing.T) {
+	t.Parallel()
+
+	tmpFileName, _, cleanup := setupFileProcessorTest(t)
+	t.Cleanup(cleanup)
+
+	var buf bytes.Buffer
+
+	err := promptbuilder.RunCLI([]string{"-p", "Explain this code", "-f", tmpFileName}, nil, &buf)
+	if !errors.Is(err, promptbuilder.ErrFileExtensionNotAllowed) {
+		t.Fatalf("Expected ErrFileExtensionNotAllowed for .go by default, got %v", err)
+	}
+
+	err = promptbuilder.RunCLI([]string{"-p", "Explain this code", "--ext", "go", "-f", tmpFileName}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() with --ext go unexpected error = %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "```go") {
+		t.Errorf("Expected the Go file to be included, got %q", buf.String())
+	}
+}
+
+//nolint:paralleltest // t.Setenv cannot be used in parallel tests.
+func TestRunCLI_ShowConfigMergesEnvironment(t *testing.T) {
+	t.Setenv("PROMPT_BUILDER_TASK", "analysis")
+	t.Setenv("PROMPT_BUILDER_OUTPUT", "json")
+
+	var buf bytes.Buffer
+
+	err := promptbuilder.RunCLI([]string{"-p", "Review", "-t", "coding", "--show-config"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	var config struct {
+		Flags   promptbuilder.CLIFlags     `json:"flags"`
+		Request promptbuilder.BuildRequest `json:"request"`
+	}
+
+	err = json.Unmarshal(buf.Bytes(), &config)
+	if err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, buf.String())
+	}
+
+	if config.Flags.Task != "coding" || config.Request.Task != "coding" {
+		t.Errorf("Expected -t to override PROMPT_BUILDER_TASK, got flags %q and request %q",
+			config.Flags.Task, config.Request.Task)
+	}
+
+	if config.Flags.OutputFormat != "json" || config.Request.OutputFormat != "json" {
+		t.Errorf("Expected output format from PROMPT_BUILDER_OUTPUT, got flags %q and request %q",
+			config.Flags.OutputFormat, config.Request.OutputFormat)
+	}
+}
+
+//nolint:paralleltest // t.Setenv cannot be used in parallel tests.
+func TestParseFlags_EnvironmentCannotEnableCommands(t *testing.T) {
+	t.Setenv("PROMPT_BUILDER_CMD", "echo pwned")
+	t.Setenv("PROMPT_BUILDER_ALLOW_SHELL", "true")
+	t.Setenv("PROMPT_BUILDER_POST_PROCESS", "cat")
+	t.Setenv("PROMPT_BUILDER_ALLOW_URLS", "true")
+	t.Setenv("PROMPT_BUILDER_P", "from the environment")
+
+	flags, err := promptbuilder.ParseFlags([]string{"-p", "Review"})
+	if err != nil {
+		t.Fatalf("ParseFlags() unexpected error = %v", err)
+	}
+
+	if len(flags.Commands) > 0 || flags.AllowShell || flags.PostProcess != "" || flags.AllowURLs {
+		t.Errorf("Expected command and network flags to ignore the environment, got %+v", flags)
+	}
+
+	if flags.Prompt != "Review" {
+		t.Errorf("Prompt = %q, want %q", flags.Prompt, "Review")
+	}
+}
+
+func TestRunCLI_WithContext(t *testing.T) {
+	t.Parallel()
+
+	var withContext, withoutContext bytes.Buffer
+
+	err := promptbuilder.RunCLI([]string{"-p", "Explain this code", "-o", "text", "--with-context"}, nil, &withContext)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	if !strings.Contains(withContext.String(), "System context:") {
+		t.Errorf("Expected system context section, got %q", withContext.String())
+	}
+
+	if !strings.Contains(withContext.String(), "OS: "+runtime.GOOS) {
+		t.Errorf("Expected GOOS %s in system context, got %q", runtime.GOOS, withContext.String())
+	}
+
+	err = promptbuilder.RunCLI([]string{"-p", "Explain this code", "-o", "text"}, nil, &withoutContext)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	if strings.Contains(withoutContext.String(), "System context:") {
+		t.Errorf("Expected no system context by default, got %q", withoutContext.String())
+	}
+}
+
+func TestRunCLI_FilesFrom(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	firstFile := filepath.Join(dir, "first.txt")
+	secondFile := filepath.Join(dir, "second.txt")
+	listFile := filepath.Join(dir, "list.txt")
+
+	files := map[string]string{
+		firstFile:  "first file content",
+		secondFile: "second file content",
+		listFile:   "# files to include\n" + firstFile + "\n\n" + secondFile + "\n",
+	}
+
+	for path, content := range files {
+		err := os.WriteFile(path, []byte(content), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	flags, err := promptbuilder.ParseFlags([]string{"-p", "Compare", "--files-from", listFile})
+	if err != nil {
+		t.Fatalf("ParseFlags() unexpected error = %v", err)
+	}
+
+	req, err := flags.ToBuildRequest()
+	if err != nil {
+		t.Fatalf("ToBuildRequest() unexpected error = %v", err)
+	}
+
+	if len(req.Files) != 2 || req.Files[0] != firstFile || req.Files[1] != secondFile {
+		t.Errorf("Expected listed files [%s %s], got %v", firstFile, secondFile, req.Files)
+	}
+
+	var buf bytes.Buffer
+
+	err = promptbuilder.RunCLI([]string{"-p", "Compare", "-o", "text", "--ext", ".txt", "--files-from", listFile}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	for _, want := range []string{"first file content", "second file content"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in output, got %q", want, buf.String())
+		}
+	}
+}
+
+func TestRunCLI_Wrap(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	err := promptbuilder.RunCLI([]string{"-p", "one two three four five six", "-o", "text", "--wrap", "10"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	want := "one two\nthree four\nfive six\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestRunCLI_MarkdownIsDefault(t *testing.T) {
+	t.Parallel()
+
+	var explicit, implicit bytes.Buffer
+
+	err := promptbuilder.RunCLI([]string{"-p", "Explain this code", "-t", "coding", "-o", "markdown"}, nil, &explicit)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	err = promptbuilder.RunCLI([]string{"-p", "Explain this code", "-t", "coding"}, nil, &implicit)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	if explicit.String() != implicit.String() {
+		t.Errorf("Expected identical output, got %q and %q", explicit.String(), implicit.String())
+	}
+}
+
+func TestCLIFlags_ToBuildRequestWithInput_ImageFromStdin(t *testing.T) {
+	t.Parallel()
+
+	pngData, err := base64.StdEncoding.DecodeString(sampleImageB64Part1 + sampleImageB64Part2)
+	if err != nil {
+		t.Fatalf("Failed to decode sample image: %v", err)
+	}
+
+	flags := promptbuilder.CLIFlags{Prompt: "describe", Image: "-"}
+
+	req, err := flags.ToBuildRequestWithInput(bytes.NewReader(pngData))
+	if err != nil {
+		t.Fatalf("ToBuildRequestWithInput() unexpected error = %v", err)
+	}
+
+	if !bytes.Equal(req.Image, pngData) {
+		t.Errorf("Expected image bytes %v, got %v", pngData, req.Image)
+	}
+
+	_, err = flags.ToBuildRequest()
+	if !errors.Is(err, promptbuilder.ErrNoImageInput) {
+		t.Errorf("Expected ErrNoImageInput without input, got %v", err)
+	}
+}
+
+func TestCLIFlags_ToBuildRequest_ImageHex(t *testing.T) {
+	t.Parallel()
+
+	pngData, err := base64.StdEncoding.DecodeString(sampleImageB64Part1 + sampleImageB64Part2)
+	if err != nil {
+		t.Fatalf("Failed to decode sample image: %v", err)
+	}
+
+	flags := promptbuilder.CLIFlags{Prompt: "describe", ImageHex: sampleImageHex}
+
+	req, err := flags.ToBuildRequest()
+	if err != nil {
+		t.Fatalf("ToBuildRequest() unexpected error = %v", err)
+	}
+
+	if !bytes.Equal(req.Image, pngData) {
+		t.Errorf("Expected image bytes %v, got %v", pngData, req.Image)
+	}
+
+	flags.Image = sampleImageB64Part1 + sampleImageB64Part2
+
+	err = flags.Validate()
+	if !errors.Is(err, promptbuilder.ErrImageAndImageHex) {
+		t.Errorf("Expected ErrImageAndImageHex, got %v", err)
+	}
+
+	flags = promptbuilder.CLIFlags{Prompt: "describe", ImageHex: "not hex"}
+
+	_, err = flags.ToBuildRequest()
+	if err == nil {
+		t.Error("Expected an error for invalid hex")
+	}
+}
+
+func TestRunCLI_ImageFromStdin(t *testing.T) {
+	t.Parallel()
+
+	pngData, err := base64.StdEncoding.DecodeString(sampleImageB64Part1 + sampleImageB64Part2)
+	if err != nil {
+		t.Fatalf("Failed to decode sample image: %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	err = promptbuilder.RunCLI([]string{"-p", "describe", "-img", "-", "-o", "text"}, bytes.NewReader(pngData), &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	want := "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngData)
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("Expected PNG data URI in output, got %q", buf.String())
+	}
+}
+
+func TestRunCLI_OnlyAndExclude(t *testing.T) {
+	t.Parallel()
+
+	base := []string{"-p", "Explain this code", "-sys", "You are a reviewer", "-g", "Be brief", "-o", "text"}
+
+	var onlyUser, withoutGuidelines bytes.Buffer
+
+	err := promptbuilder.RunCLI(append(base, "--only", "user"), nil, &onlyUser)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	if onlyUser.String() != "Explain this code\n" {
+		t.Errorf("Expected only the user prompt, got %q", onlyUser.String())
+	}
+
+	err = promptbuilder.RunCLI(append(base, "--exclude", "guidelines"), nil, &withoutGuidelines)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	if withoutGuidelines.String() != "You are a reviewer\n\nExplain this code\n" {
+		t.Errorf("Expected guidelines to be pruned, got %q", withoutGuidelines.String())
+	}
+}
+
+func TestRunCLI_PerFileSections(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	goFile := filepath.Join(dir, "main.go")
+	textFile := filepath.Join(dir, "notes.txt")
+
+	for path, content := range map[string]string{goFile: "package main\n", textFile: "remember this\n"} {
+		err := os.WriteFile(path, []byte(content), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	args := []string{"-p", "Review", "--ext", ".go", "--ext", "txt", "-f", goFile, "-f", textFile, "--per-file-sections"}
+
+	err := promptbuilder.RunCLI(args, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	output := buf.String()
+
+	for _, want := range []string{"\n## " + goFile + "\n\n```go\npackage main\n", "\n## " + textFile + "\n\n```\nremember this\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got %q", want, output)
+		}
+	}
+
+	if strings.Contains(output, "BEGIN ") {
+		t.Errorf("Expected no BEGIN/END fences with per-file sections, got %q", output)
+	}
+}
+
+func TestRunCLI_PerFileSectionsKeepsSectionOrder(t *testing.T) {
+	t.Parallel()
+
+	goFile := filepath.Join(t.TempDir(), "main.go")
+
+	err := os.WriteFile(goFile, []byte("package main\n"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write %s: %v", goFile, err)
+	}
+
+	var buf bytes.Buffer
+
+	args := []string{
+		"-p", "Review", "-sys", "You are a reviewer", "-g", "Be brief", "--manifest",
+		"--ext", ".go", "-f", goFile, "--per-file-sections",
+	}
+
+	err = promptbuilder.RunCLI(args, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	output := buf.String()
+	previous := -1
+
+	for _, want := range []string{
+		"You are a reviewer", "Guidelines:\n\nBe brief", "\n## " + goFile + "\n", "\n## Request\n", "Review", "Manifest:",
+	} {
+		index := strings.Index(output[previous+1:], want)
+		if index < 0 {
+			t.Fatalf("Expected %q after position %d in output, got %q", want, previous, output)
+		}
+
+		previous += 1 + index
+	}
+}
+
+func TestRunCLI_PerFileSectionsAppliesFileOptions(t *testing.T) {
+	t.Parallel()
+
+	goFile := filepath.Join(t.TempDir(), "main.go")
+
+	err := os.WriteFile(goFile, []byte("package main\n\n\n\nfunc main() {}\n"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write %s: %v", goFile, err)
+	}
+
+	var buf bytes.Buffer
+
+	args := []string{
+		"-p", "Review", "--ext", ".go", "-f", goFile, "--per-file-sections", "--line-numbers", "--collapse-blanks",
+		"--note", goFile + "=entry point", "--sign-key-file", writeSignKey(t, "secret"),
+	}
+
+	err = promptbuilder.RunCLI(args, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	want := "\n## " + goFile + " — entry point\n\n```go\n1: package main\n2:\n3: func main() {}\n```\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("Expected %q in output, got %q", want, buf.String())
+	}
+
+	if !promptbuilder.VerifyOutput([]byte("secret"), buf.Bytes()) {
+		t.Errorf("Expected the output to carry a valid signature, got %q", buf.String())
+	}
+}
+
+func TestRunCLI_RepeatedGuidelines(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "two guidelines",
+			args: []string{"-p", "Review", "-g", "Be brief", "--guidelines", "Cite lines", "-o", "text"},
+			want: "Guidelines:\n\n- Be brief\n- Cite lines\n\nReview\n",
+		},
+		{
+			name: "single guideline",
+			args: []string{"-p", "Review", "-g", "Be brief", "-o", "text"},
+			want: "Guidelines:\n\nBe brief\n\nReview\n",
+		},
+		{
+			name: "numbered guidelines",
+			args: []string{
+				"-p", "Review", "-g", "Read the diff", "-g", "Run the tests", "-g", "Write the summary",
+				"--numbered-guidelines", "-o", "text",
+			},
+			want: "Guidelines:\n\n1. Read the diff\n2. Run the tests\n3. Write the summary\n\nReview\n",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			err := promptbuilder.RunCLI(testCase.args, nil, &buf)
+			if err != nil {
+				t.Fatalf("RunCLI() unexpected error = %v", err)
+			}
+
+			if buf.String() != testCase.want {
+				t.Errorf("Expected %q, got %q", testCase.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestRunCLI_Separator(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	err := promptbuilder.RunCLI([]string{"-p", "Review", "--sys", "Be terse.", "--separator", `\n---\n`, "-o", "text"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	if got := buf.String(); got != "Be terse.\n---\nReview\n" {
+		t.Errorf("Expected sections separated by ---, got %q", got)
+	}
+}
+
+func TestRunCLI_ListLanguages(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	err := promptbuilder.RunCLI([]string{"--list-languages", "--lang", "tmpl=gotemplate", "--lang", "py=python3"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	output := buf.String()
+
+	for _, want := range []string{".go -> go\n", ".rs -> rust\n", "tmpl -> gotemplate\n", ".py -> python3\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in language table, got %q", want, output)
+		}
+	}
+
+	if strings.Contains(output, ".py -> python\n") {
+		t.Errorf("Expected the --lang override to replace the built-in entry, got %q", output)
+	}
+}
+
+func TestRunCLI_ExcludeGlob(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	for _, name := range []string{"main.go", "main_test.go"} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte("package main\n// "+name+"\n"), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	err := promptbuilder.RunCLI([]string{"-p", "Review", "--ext", ".go", "-f", dir, "--exclude-glob", "*_test.go", "-o", "text"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	if output := buf.String(); !strings.Contains(output, "// main.go") || strings.Contains(output, "main_test.go") {
+		t.Errorf("Expected only main.go in output, got %q", output)
+	}
+}
+
+func TestRunCLI_TokenBreakdown(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	err := promptbuilder.RunCLI([]string{"-p", "Review this", "--sys", "Be terse.", "--token-breakdown"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	want := "Estimated tokens by section:\n- system: 3\n- user: 3\n- total: 6\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestRunCLI_Trim(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "trimmed by default", args: nil, want: "Review\n"},
+		{name: "trim given", args: []string{"--trim"}, want: "Review\n"},
+		{name: "trim off", args: []string{"--trim=false"}, want: "Review  \n"},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			err := promptbuilder.RunCLI(append([]string{"-p", "Review  ", "-o", "text"}, testCase.args...), nil, &buf)
+			if err != nil {
+				t.Fatalf("RunCLI() unexpected error = %v", err)
+			}
+
+			if got := buf.String(); got != testCase.want {
+				t.Errorf("Expected %q, got %q", testCase.want, got)
+			}
+		})
+	}
+
+	// The zero CLIFlags trims, as the command line does by default.
+	req, err := (&promptbuilder.CLIFlags{Prompt: "Review  "}).ToBuildRequest()
+	if err != nil {
+		t.Fatalf("ToBuildRequest() unexpected error = %v", err)
+	}
+
+	if req.KeepWhitespace {
+		t.Error("Expected the zero CLIFlags to trim whitespace")
+	}
+}
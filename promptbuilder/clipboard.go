@@ -0,0 +1,69 @@
+package promptbuilder
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrNoClipboard is returned when no clipboard command is available.
+var ErrNoClipboard = errors.New("no clipboard command found")
+
+// Clipboard receives text copied by the --clipboard flag.
+type Clipboard interface {
+	Copy(text string) error
+}
+
+// SystemClipboard copies text to the system clipboard with the platform's
+// clipboard command: pbcopy on macOS, clip on Windows, and wl-copy, xclip, or
+// xsel elsewhere, whichever is installed first.
+type SystemClipboard struct{}
+
+// Copy pipes text into the clipboard command.
+func (SystemClipboard) Copy(text string) error {
+	for _, command := range clipboardCommands() {
+		path, err := exec.LookPath(command[0])
+		if err != nil {
+			continue
+		}
+
+		// #nosec G204 -- The command is one of the fixed clipboard commands.
+		cmd := exec.Command(path, command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to copy to clipboard with %s: %w: %s",
+				command[0], err, strings.TrimSpace(string(output)))
+		}
+
+		return nil
+	}
+
+	return ErrNoClipboard
+}
+
+// clipboardCommands returns the clipboard commands to try on this platform,
+// in order of preference.
+func clipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip"}}
+	}
+
+	commands := [][]string{
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+	}
+
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		commands = append([][]string{{"wl-copy"}}, commands...)
+	}
+
+	return commands
+}
@@ -0,0 +1,62 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+// fakeClipboard records the text copied to it.
+type fakeClipboard struct {
+	copied []string
+}
+
+func (c *fakeClipboard) Copy(text string) error {
+	c.copied = append(c.copied, text)
+
+	return nil
+}
+
+func TestRunCLIWithOptions_Clipboard(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCopies int
+	}{
+		{name: "with --clipboard", args: []string{"-p", "Explain this code", "-o", "text", "--clipboard"}, wantCopies: 1},
+		{name: "without --clipboard", args: []string{"-p", "Explain this code", "-o", "text"}, wantCopies: 0},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				buf       bytes.Buffer
+				clipboard fakeClipboard
+			)
+
+			options := promptbuilder.Options{Clipboard: &clipboard, Editor: nil, Stderr: nil}
+
+			err := promptbuilder.RunCLIWithOptions(testCase.args, nil, &buf, options)
+			if err != nil {
+				t.Fatalf("RunCLIWithOptions() unexpected error = %v", err)
+			}
+
+			if buf.String() != "Explain this code\n" {
+				t.Errorf("Expected the prompt on output, got %q", buf.String())
+			}
+
+			if len(clipboard.copied) != testCase.wantCopies {
+				t.Fatalf("Expected %d copies, got %d", testCase.wantCopies, len(clipboard.copied))
+			}
+
+			if testCase.wantCopies > 0 && clipboard.copied[0] != buf.String() {
+				t.Errorf("Copied %q, want the rendered output %q", clipboard.copied[0], buf.String())
+			}
+		})
+	}
+}
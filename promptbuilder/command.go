@@ -0,0 +1,181 @@
+package promptbuilder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// shellMetacharacters are the characters a shell would interpret. Commands
+// containing them are rejected unless shell execution is allowed, because
+// without a shell they would be passed to the program literally.
+const shellMetacharacters = "|&;<>()$`\\\"'*?[]{}~#\n"
+
+var (
+	// ErrEmptyCommand is returned for a blank command.
+	ErrEmptyCommand = errors.New("command is empty")
+	// ErrShellMetacharacters is returned for a command that needs a shell
+	// when shell execution has not been allowed.
+	ErrShellMetacharacters = errors.New("command contains shell metacharacters")
+	// ErrCommandNotAllowed is returned for a command that is not on the
+	// allowed commands.
+	ErrCommandNotAllowed = errors.New("command is not allowed")
+)
+
+// checkCommandAllowed reports whether command may run under allowed, the
+// allowed commands. Without allowShell the command's program, its first word,
+// must be listed. With it the whole command line must be listed, since the
+// shell could run any program named later in the line. An empty list allows
+// nothing.
+func checkCommandAllowed(command string, allowed []string, allowShell bool) error {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return ErrEmptyCommand
+	}
+
+	name := command
+	if !allowShell {
+		name = strings.Fields(command)[0]
+	}
+
+	if !slices.Contains(allowed, name) {
+		return fmt.Errorf("%w: %q", ErrCommandNotAllowed, name)
+	}
+
+	return nil
+}
+
+// validateCommand checks that command can be run: it must not be blank, and
+// without allowShell it must not contain shell metacharacters and its program
+// must be found in PATH.
+func validateCommand(command string, allowShell bool) error {
+	if strings.TrimSpace(command) == "" {
+		return ErrEmptyCommand
+	}
+
+	if allowShell {
+		return nil
+	}
+
+	if strings.ContainsAny(command, shellMetacharacters) {
+		return fmt.Errorf("%w: %q", ErrShellMetacharacters, command)
+	}
+
+	_, err := exec.LookPath(strings.Fields(command)[0])
+	if err != nil {
+		return fmt.Errorf("invalid command %q: %w", command, err)
+	}
+
+	return nil
+}
+
+// newCommand validates command and returns it ready to run. Without
+// allowShell the command is split on whitespace and run directly; with it the
+// command is run by sh -c.
+func newCommand(ctx context.Context, command string, allowShell bool) (*exec.Cmd, error) {
+	err := validateCommand(command, allowShell)
+	if err != nil {
+		return nil, err
+	}
+
+	if allowShell {
+		// #nosec G204 -- Shell execution is an explicit opt-in by the caller.
+		return exec.CommandContext(ctx, "sh", "-c", command), nil
+	}
+
+	fields := strings.Fields(command)
+
+	// #nosec G204 -- The command is an explicit opt-in by the caller and is
+	// run without a shell.
+	return exec.CommandContext(ctx, fields[0], fields[1:]...), nil
+}
+
+// commandOutput runs command and returns its standard output. Without
+// allowShell the command is split on whitespace and run directly, and shell
+// metacharacters are rejected; with it the command is run by sh -c. A command
+// that exits with a non-zero status still returns its output, together with a
+// warning describing the status.
+func commandOutput(ctx context.Context, command string, allowShell bool) ([]byte, string, error) {
+	cmd, err := newCommand(ctx, command, allowShell)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var stdout bytes.Buffer
+
+	cmd.Stdout = &stdout
+
+	err = cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.Bytes(), fmt.Sprintf("command %q exited with status %d", command, exitErr.ExitCode()), nil
+	}
+
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to run command %q: %w", command, err)
+	}
+
+	return stdout.Bytes(), "", nil
+}
+
+// fenceCommandOutputs runs each command and fences its output as a text
+// block headed by the command line. No command runs unless all of them are
+// on the allowed list. Warnings describe commands that exited with a non-zero
+// status.
+func fenceCommandOutputs(
+	ctx context.Context, commands, allowed []string, allowShell bool,
+) ([]string, []string, error) {
+	for _, command := range commands {
+		err := checkCommandAllowed(command, allowed, allowShell)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	fenced := make([]string, 0, len(commands))
+
+	var warnings []string
+
+	for _, command := range commands {
+		output, warning, err := commandOutput(ctx, command, allowShell)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+
+		fenced = append(fenced, fenceContent(bytes.TrimRight(output, "\n"), "$ "+command, "", "text", nil))
+	}
+
+	return fenced, warnings, nil
+}
+
+// postProcess pipes output through command and returns what the command
+// writes to its standard output. Unlike commandOutput, a non-zero exit status
+// is an error, because the output could be incomplete.
+func postProcess(ctx context.Context, command string, allowShell bool, output []byte) ([]byte, error) {
+	cmd, err := newCommand(ctx, command, allowShell)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdin = bytes.NewReader(output)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("post-process command %q failed: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,168 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestBuildPrompt_CommandOutput(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("echo"); err != nil {
+		t.Skip("echo is not installed")
+	}
+
+	result, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:          "What does this print?",
+		Commands:        []string{"echo hello"},
+		AllowedCommands: []string{"echo"},
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	want := "BEGIN $ echo hello\n```text\nhello\n```\nEND $ echo hello"
+	if result.Prompt.FileContent != want {
+		t.Errorf("FileContent = %q, want %q", result.Prompt.FileContent, want)
+	}
+}
+
+func TestBuildPrompt_CommandShellMetacharacters(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	req := &promptbuilder.BuildRequest{
+		Prompt:          "What does this print?",
+		Commands:        []string{"echo hello | tr a-z A-Z"},
+		AllowedCommands: []string{"echo", "echo hello | tr a-z A-Z"},
+	}
+
+	_, err := newTestBuilder().BuildPrompt(req)
+	if !errors.Is(err, promptbuilder.ErrShellMetacharacters) {
+		t.Fatalf("Expected ErrShellMetacharacters, got %v", err)
+	}
+
+	req.AllowShell = true
+
+	result, err := newTestBuilder().BuildPrompt(req)
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if !strings.Contains(result.Prompt.FileContent, "```text\nHELLO\n```") {
+		t.Errorf("Expected shell pipeline output, got %q", result.Prompt.FileContent)
+	}
+}
+
+func TestBuildPrompt_CommandNotAllowed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		command    string
+		allowed    []string
+		allowShell bool
+	}{
+		{name: "empty allowlist", command: "echo hello", allowed: nil},
+		{name: "other program", command: "rm -rf build", allowed: []string{"echo", "go"}},
+		{name: "program prefix", command: "echo-all hello", allowed: []string{"echo"}},
+		{name: "shell line", command: "echo hello; rm -rf build", allowed: []string{"echo"}, allowShell: true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{
+				Prompt:          "What does this print?",
+				Commands:        []string{testCase.command},
+				AllowedCommands: testCase.allowed,
+				AllowShell:      testCase.allowShell,
+			})
+			if !errors.Is(err, promptbuilder.ErrCommandNotAllowed) {
+				t.Errorf("Expected ErrCommandNotAllowed for %q, got %v", testCase.command, err)
+			}
+		})
+	}
+
+	_, err := promptbuilder.ParseFlags([]string{"-p", "What does this print?", "--cmd", "echo hello"})
+	if !errors.Is(err, promptbuilder.ErrCommandNotAllowed) {
+		t.Errorf("Expected --cmd without --allow-cmd to be refused, got %v", err)
+	}
+
+	flags, err := promptbuilder.ParseFlags([]string{"-p", "What?", "--cmd", "echo hello", "--allow-cmd", "echo"})
+	if err != nil || !slices.Equal(flags.AllowedCommands, []string{"echo"}) {
+		t.Errorf("Expected --allow-cmd echo to allow the command, got %+v, %v", flags, err)
+	}
+}
+
+func TestRunCLI_PostProcess(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat is not installed")
+	}
+
+	args := []string{"-p", "Explain this", "-g", "Be brief", "-o", "markdown"}
+
+	var plain, piped bytes.Buffer
+
+	err := promptbuilder.RunCLI(args, nil, &plain)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	err = promptbuilder.RunCLI(append(args, "--post-process", "cat"), nil, &piped)
+	if err != nil {
+		t.Fatalf("RunCLI() with --post-process unexpected error = %v", err)
+	}
+
+	if piped.String() != plain.String() {
+		t.Errorf("Expected output piped through cat to be unchanged, got %q, want %q", piped.String(), plain.String())
+	}
+}
+
+func TestRunCLI_PostProcessTimeout(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is not installed")
+	}
+
+	var buf bytes.Buffer
+
+	start := time.Now()
+
+	err := promptbuilder.RunCLI([]string{"-p", "Explain this", "--post-process", "sleep 10", "--timeout", "100ms"}, nil, &buf)
+	if !errors.Is(err, promptbuilder.ErrBuildTimeout) {
+		t.Fatalf("Expected ErrBuildTimeout, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected --timeout to stop the post-process command, took %s", elapsed)
+	}
+}
+
+func TestParseFlags_PostProcessValidated(t *testing.T) {
+	t.Parallel()
+
+	_, err := promptbuilder.ParseFlags([]string{"-p", "Explain this", "--post-process", "cat | tr a-z A-Z"})
+	if !errors.Is(err, promptbuilder.ErrShellMetacharacters) {
+		t.Errorf("Expected ErrShellMetacharacters, got %v", err)
+	}
+
+	_, err = promptbuilder.ParseFlags([]string{"-p", "Explain this", "--post-process", "no-such-formatter-command"})
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("Expected exec.ErrNotFound for a missing command, got %v", err)
+	}
+}
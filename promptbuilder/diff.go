@@ -0,0 +1,280 @@
+package promptbuilder
+
+import (
+	"strconv"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// Diff compares the prompt with other section by section. Unchanged sections
+// are reported as "= name (unchanged)"; changed sections get a unified diff
+// header followed by "@@" hunks of the changed lines with three lines of
+// context, each line prefixed with " ", "-", or "+".
+func (p *Prompt) Diff(other *Prompt) string {
+	before := sectionContents(p)
+	after := sectionContents(other)
+
+	var builder strings.Builder
+
+	for _, name := range AllSections() {
+		if before[name] == after[name] {
+			builder.WriteString("= " + name + " (unchanged)\n")
+
+			continue
+		}
+
+		builder.WriteString("--- a/" + name + "\n")
+		builder.WriteString("+++ b/" + name + "\n")
+
+		for _, line := range diffHunks(splitSectionLines(before[name]), splitSectionLines(after[name])) {
+			builder.WriteString(line + "\n")
+		}
+	}
+
+	return builder.String()
+}
+
+// sectionContents maps section names to their content, including empty ones.
+func sectionContents(prompt *Prompt) map[string]string {
+	return map[string]string{
+		SectionContext:    prompt.SystemContext,
+		SectionSystem:     prompt.SystemMessage,
+		SectionGuidelines: prompt.Guidelines,
+		SectionContents:   prompt.TableOfContents,
+		SectionFiles:      prompt.FileContent,
+		SectionImage:      prompt.ImagePath,
+		SectionUser:       prompt.UserPrompt,
+		SectionManifest:   prompt.Manifest,
+	}
+}
+
+// splitSectionLines splits section content into lines; empty content has none.
+func splitSectionLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+
+	return strings.Split(content, "\n")
+}
+
+// diffEdit is one line of an edit script: a line of a kept (' '), deleted
+// ('-'), or a line of b inserted ('+').
+type diffEdit struct {
+	op   byte
+	line string
+}
+
+// diffHunks returns the unified diff of a and b as "@@" hunk headers, each
+// followed by its lines. Changes less than twice diffContext lines apart
+// share a hunk.
+func diffHunks(a, b []string) []string {
+	edits := diffLines(a, b)
+
+	var lines []string
+
+	for start := 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+
+			continue
+		}
+
+		// Extend the hunk while the next change is close enough to share it.
+		end := start
+		for next := start; next < len(edits); next++ {
+			if edits[next].op != ' ' {
+				if next-end > 2*diffContext {
+					break
+				}
+
+				end = next + 1
+			}
+		}
+
+		first := max(start-diffContext, 0)
+		last := min(end+diffContext, len(edits))
+
+		lines = append(lines, hunkHeader(edits, first, last))
+		for _, edit := range edits[first:last] {
+			lines = append(lines, string(edit.op)+edit.line)
+		}
+
+		start = last
+	}
+
+	return lines
+}
+
+// hunkHeader returns the "@@ -l,s +l,s @@" header of edits[first:last],
+// numbering lines from one as diff -u does: a range with no lines starts at
+// the line before it, and a count of one is left out.
+func hunkHeader(edits []diffEdit, first, last int) string {
+	var beforeStart, afterStart, beforeCount, afterCount int
+
+	for _, edit := range edits[:first] {
+		if edit.op != '+' {
+			beforeStart++
+		}
+
+		if edit.op != '-' {
+			afterStart++
+		}
+	}
+
+	for _, edit := range edits[first:last] {
+		if edit.op != '+' {
+			beforeCount++
+		}
+
+		if edit.op != '-' {
+			afterCount++
+		}
+	}
+
+	return "@@ -" + hunkRange(beforeStart, beforeCount) + " +" + hunkRange(afterStart, afterCount) + " @@"
+}
+
+// hunkRange formats the range of count lines after the first skipped lines.
+func hunkRange(skipped, count int) string {
+	switch count {
+	case 0:
+		return strconv.Itoa(skipped) + ",0"
+	case 1:
+		return strconv.Itoa(skipped + 1)
+	default:
+		return strconv.Itoa(skipped+1) + "," + strconv.Itoa(count)
+	}
+}
+
+// diffLines returns a shortest edit script turning a into b. It uses Myers'
+// linear-space refinement, which finds the middle snake of the edit graph
+// and recurses on either side of it, so memory grows with len(a)+len(b)
+// rather than their product.
+func diffLines(a, b []string) []diffEdit {
+	size := len(a) + len(b) + 2
+	differ := &lineDiffer{
+		a:        a,
+		b:        b,
+		forward:  make([]int, 2*size),
+		backward: make([]int, 2*size),
+		edits:    make([]diffEdit, 0, size),
+	}
+
+	differ.compare(0, len(a), 0, len(b))
+
+	return differ.edits
+}
+
+// lineDiffer holds the inputs, scratch space, and output of diffLines.
+type lineDiffer struct {
+	a, b              []string
+	forward, backward []int
+	edits             []diffEdit
+}
+
+// compare appends the edits turning a[aLow:aHigh] into b[bLow:bHigh].
+func (d *lineDiffer) compare(aLow, aHigh, bLow, bHigh int) {
+	for aLow < aHigh && bLow < bHigh && d.a[aLow] == d.b[bLow] {
+		d.edits = append(d.edits, diffEdit{op: ' ', line: d.a[aLow]})
+		aLow++
+		bLow++
+	}
+
+	suffix := 0
+	for aLow < aHigh-suffix && bLow < bHigh-suffix && d.a[aHigh-suffix-1] == d.b[bHigh-suffix-1] {
+		suffix++
+	}
+
+	aHigh -= suffix
+	bHigh -= suffix
+
+	switch {
+	case aLow == aHigh:
+		for _, line := range d.b[bLow:bHigh] {
+			d.edits = append(d.edits, diffEdit{op: '+', line: line})
+		}
+	case bLow == bHigh:
+		for _, line := range d.a[aLow:aHigh] {
+			d.edits = append(d.edits, diffEdit{op: '-', line: line})
+		}
+	default:
+		x, y, u, v := d.middleSnake(aLow, aHigh, bLow, bHigh)
+
+		d.compare(aLow, x, bLow, y)
+
+		for _, line := range d.a[x:u] {
+			d.edits = append(d.edits, diffEdit{op: ' ', line: line})
+		}
+
+		d.compare(u, aHigh, v, bHigh)
+	}
+
+	for _, line := range d.a[aHigh : aHigh+suffix] {
+		d.edits = append(d.edits, diffEdit{op: ' ', line: line})
+	}
+}
+
+// middleSnake returns the start (x, y) and end (u, v) of the middle snake of
+// a shortest path from (aLow, bLow) to (aHigh, bHigh), searching from both
+// ends until the paths meet. Both ranges must be non-empty.
+func (d *lineDiffer) middleSnake(aLow, aHigh, bLow, bHigh int) (int, int, int, int) {
+	n, m := aHigh-aLow, bHigh-bLow
+	delta := n - m
+	odd := delta%2 != 0
+	offset := (n+m+1)/2 + 1
+
+	// forward[offset+k] is the furthest x reached on diagonal k = x-y from
+	// the start; backward[offset+c] is the furthest distance reached on
+	// diagonal c from the end, where c = delta-k.
+	forward := d.forward[:2*offset+1]
+	backward := d.backward[:2*offset+1]
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	for steps := 0; steps <= (n+m+1)/2; steps++ {
+		for k := -steps; k <= steps; k += 2 {
+			var x int
+			if k == -steps || (k != steps && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+
+			startX, startY := x, x-k
+			for x < n && x-k < m && d.a[aLow+x] == d.b[bLow+x-k] {
+				x++
+			}
+
+			forward[offset+k] = x
+
+			if c := delta - k; odd && c >= -(steps-1) && c <= steps-1 && x+backward[offset+c] >= n {
+				return aLow + startX, bLow + startY, aLow + x, bLow + x - k
+			}
+		}
+
+		for c := -steps; c <= steps; c += 2 {
+			var x int
+			if c == -steps || (c != steps && backward[offset+c-1] < backward[offset+c+1]) {
+				x = backward[offset+c+1]
+			} else {
+				x = backward[offset+c-1] + 1
+			}
+
+			startX, startY := x, x-c
+			for x < n && x-c < m && d.a[aHigh-x-1] == d.b[bHigh-(x-c)-1] {
+				x++
+			}
+
+			backward[offset+c] = x
+
+			if k := delta - c; !odd && k >= -steps && k <= steps && x+forward[offset+k] >= n {
+				return aHigh - x, bHigh - (x - c), aHigh - startX, bHigh - startY
+			}
+		}
+	}
+
+	// A path always exists, so the searches meet before this point.
+	return aLow, bLow, aLow, bLow
+}
@@ -0,0 +1,111 @@
+package promptbuilder_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestPrompt_Diff(t *testing.T) {
+	t.Parallel()
+
+	before := &promptbuilder.Prompt{
+		SystemMessage: "You are a reviewer.",
+		UserPrompt:    "Review this code",
+		Guidelines:    "Be brief\nCite lines",
+	}
+	after := &promptbuilder.Prompt{
+		SystemMessage: "You are a reviewer.",
+		UserPrompt:    "Review this code",
+		Guidelines:    "Be thorough\nCite lines",
+	}
+
+	diff := before.Diff(after)
+
+	for _, want := range []string{
+		"= system (unchanged)\n",
+		"= user (unchanged)\n",
+		"--- a/guidelines\n+++ b/guidelines\n@@ -1,2 +1,2 @@\n-Be brief\n+Be thorough\n Cite lines\n",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Expected %q in diff, got %q", want, diff)
+		}
+	}
+
+	if same := before.Diff(before); strings.Contains(same, "---") {
+		t.Errorf("Expected no changes when diffing a prompt with itself, got %q", same)
+	}
+}
+
+func TestPrompt_DiffLargeSection(t *testing.T) {
+	t.Parallel()
+
+	lines := make([]string, 20000)
+	for index := range lines {
+		lines[index] = "line " + strconv.Itoa(index+1)
+	}
+
+	before := &promptbuilder.Prompt{FileContent: strings.Join(lines, "\n")}
+
+	lines[9999] = "changed"
+	lines = append(lines[:15000], lines[15001:]...)
+	after := &promptbuilder.Prompt{FileContent: strings.Join(lines, "\n")}
+
+	want := "--- a/files\n+++ b/files\n" +
+		"@@ -9997,7 +9997,7 @@\n line 9997\n line 9998\n line 9999\n-line 10000\n+changed\n line 10001\n line 10002\n line 10003\n" +
+		"@@ -14998,7 +14998,6 @@\n line 14998\n line 14999\n line 15000\n-line 15001\n line 15002\n line 15003\n line 15004\n"
+
+	if diff := before.Diff(after); !strings.Contains(diff, want) {
+		t.Errorf("Expected hunks %q in diff, got %q", want, diff)
+	}
+}
+
+func TestPrompt_DiffHunks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		before, after string
+		want          string
+	}{
+		{
+			name:   "added section",
+			before: "",
+			after:  "one\ntwo",
+			want:   "@@ -0,0 +1,2 @@\n+one\n+two\n",
+		},
+		{
+			name:   "nearby changes share a hunk",
+			before: "a\nb\nc\nd\ne\nf\ng\nh",
+			after:  "A\nb\nc\nd\ne\nf\ng\nH",
+			want:   "@@ -1,8 +1,8 @@\n-a\n+A\n b\n c\n d\n e\n f\n g\n-h\n+H\n",
+		},
+		{
+			name:   "distant changes get their own hunks",
+			before: "a\nb\nc\nd\ne\nf\ng\nh\ni\nj",
+			after:  "A\nb\nc\nd\ne\nf\ng\nh\ni\nJ",
+			want:   "@@ -1,4 +1,4 @@\n-a\n+A\n b\n c\n d\n@@ -7,4 +7,4 @@\n g\n h\n i\n-j\n+J\n",
+		},
+		{
+			name:   "reordered lines",
+			before: "x\ny\nz",
+			after:  "z\nx\ny",
+			want:   "@@ -1,3 +1,3 @@\n+z\n x\n y\n-z\n",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			diff := (&promptbuilder.Prompt{UserPrompt: testCase.before}).Diff(&promptbuilder.Prompt{UserPrompt: testCase.after})
+
+			want := "--- a/user\n+++ b/user\n" + testCase.want
+			if !strings.Contains(diff, want) {
+				t.Errorf("Expected %q in diff, got %q", want, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package promptbuilder
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// editPromptPattern names the temporary file --edit opens; the .md extension
+// lets editors highlight the prompt as Markdown.
+const editPromptPattern = "prompt-builder-*.md"
+
+// EditorLauncher opens a file for the user to edit, returning once the
+// editor has exited.
+type EditorLauncher interface {
+	Edit(path string) error
+}
+
+// SystemEditor edits files with the command in $VISUAL or $EDITOR, falling
+// back to notepad on Windows and vi elsewhere. The command may include
+// arguments, e.g. "code --wait", and a program path containing spaces must
+// then be quoted.
+type SystemEditor struct{}
+
+// Edit runs the editor on path, attached to the terminal.
+func (SystemEditor) Edit(path string) error {
+	fields := editorArgs(editorCommand())
+
+	// #nosec G204 -- The editor is chosen by the user through $VISUAL or
+	// $EDITOR, as with other command line tools.
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin = os.Stdin
+	// The editor draws on stderr so that it stays on the terminal when
+	// stdout, which receives the prompt, is redirected.
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("failed to run editor %s: %w", fields[0], err)
+	}
+
+	return nil
+}
+
+// editorCommand returns the user's preferred editor command.
+func editorCommand() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(name)); editor != "" {
+			return editor
+		}
+	}
+
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+
+	return "vi"
+}
+
+// editorArgs splits an editor command into its program and arguments. A
+// command that names a program as a whole, such as a path with spaces, is
+// used as is. Otherwise it is split on whitespace outside single or double
+// quotes; backslashes are kept, as they separate Windows paths.
+func editorArgs(command string) []string {
+	_, err := exec.LookPath(command)
+	if err == nil {
+		return []string{command}
+	}
+
+	var (
+		fields  []string
+		current strings.Builder
+		quote   rune
+		inField bool
+	)
+
+	for _, char := range command {
+		switch {
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(char)
+		case char == '\'' || char == '"':
+			quote = char
+			inField = true
+		case char == ' ' || char == '\t':
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+		default:
+			current.WriteRune(char)
+
+			inField = true
+		}
+	}
+
+	if inField {
+		fields = append(fields, current.String())
+	}
+
+	return fields
+}
+
+// editPrompt writes initial to a temporary file, lets the user edit it with
+// editor, and returns the saved content without trailing whitespace.
+func editPrompt(editor EditorLauncher, initial string) (string, error) {
+	file, err := os.CreateTemp("", editPromptPattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create prompt file: %w", err)
+	}
+
+	path := file.Name()
+
+	defer func() { _ = os.Remove(path) }()
+
+	_, err = file.WriteString(initial)
+
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to write prompt file: %w", err)
+	}
+
+	err = editor.Edit(path)
+	if err != nil {
+		return "", err
+	}
+
+	// #nosec G304 -- The file is the temporary file created above.
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited prompt: %w", err)
+	}
+
+	prompt := strings.TrimRight(string(content), " \t\r\n")
+	if prompt == "" {
+		return "", ErrPromptRequired
+	}
+
+	return prompt, nil
+}
@@ -0,0 +1,150 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+// fakeEditor replaces the edited file with content, recording what the file
+// held when it was opened.
+type fakeEditor struct {
+	content string
+	initial string
+}
+
+func (e *fakeEditor) Edit(path string) error {
+	initial, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	e.initial = string(initial)
+
+	return os.WriteFile(path, []byte(e.content), 0o600)
+}
+
+func TestRunCLIWithOptions_Editor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		args        []string
+		content     string
+		wantInitial string
+		wantOutput  string
+		wantErr     error
+	}{
+		{
+			name:       "edited content becomes the prompt",
+			args:       []string{"--edit", "-o", "text"},
+			content:    "Explain the retry logic\n\n",
+			wantOutput: "Explain the retry logic\n",
+		},
+		{
+			name:        "editing starts from -p",
+			args:        []string{"--edit", "-p", "Draft", "-o", "text"},
+			content:     "Draft, expanded",
+			wantInitial: "Draft",
+			wantOutput:  "Draft, expanded\n",
+		},
+		{
+			name:    "image from standard input is rejected",
+			args:    []string{"--edit", "-img", "-", "-o", "text"},
+			content: "Describe this image",
+			wantErr: promptbuilder.ErrEditAndStdinImage,
+		},
+		{
+			name:    "empty edit is rejected",
+			args:    []string{"--edit", "-o", "text"},
+			content: "  \n",
+			wantErr: promptbuilder.ErrPromptRequired,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			editor := &fakeEditor{content: testCase.content}
+			options := promptbuilder.Options{Clipboard: nil, Editor: editor, Stderr: nil}
+
+			err := promptbuilder.RunCLIWithOptions(testCase.args, strings.NewReader("image"), &buf, options)
+			if testCase.wantErr != nil {
+				if !errors.Is(err, testCase.wantErr) {
+					t.Fatalf("RunCLIWithOptions() error = %v, want %v", err, testCase.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("RunCLIWithOptions() unexpected error = %v", err)
+			}
+
+			if editor.initial != testCase.wantInitial {
+				t.Errorf("Editor opened with %q, want %q", editor.initial, testCase.wantInitial)
+			}
+
+			if buf.String() != testCase.wantOutput {
+				t.Errorf("Output = %q, want %q", buf.String(), testCase.wantOutput)
+			}
+		})
+	}
+}
+
+// fakeEditorScript writes its arguments into the file named by the last one.
+const fakeEditorScript = "#!/bin/sh\nfor last; do :; done\nprintf '%s' \"$*\" > \"$last\"\n"
+
+//nolint:paralleltest // t.Setenv cannot be used in parallel tests.
+func TestSystemEditor_EditorPathWithSpaces(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake editor is a shell script")
+	}
+
+	script := filepath.Join(t.TempDir(), "fake editor")
+
+	err := os.WriteFile(script, []byte(fakeEditorScript), 0o700) //nolint:gosec // The editor must be executable.
+	if err != nil {
+		t.Fatalf("Failed to write editor script: %v", err)
+	}
+
+	target := filepath.Join(t.TempDir(), "prompt.md")
+
+	tests := []struct {
+		name   string
+		visual string
+		want   string
+	}{
+		{name: "unquoted path", visual: script, want: target},
+		{name: "quoted path with arguments", visual: "'" + script + "' --wait", want: "--wait " + target},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Setenv("VISUAL", testCase.visual)
+
+			err := promptbuilder.SystemEditor{}.Edit(target)
+			if err != nil {
+				t.Fatalf("Edit() unexpected error = %v", err)
+			}
+
+			edited, err := os.ReadFile(target)
+			if err != nil {
+				t.Fatalf("Failed to read edited file: %v", err)
+			}
+
+			if string(edited) != testCase.want {
+				t.Errorf("Editor was run with %q, want %q", edited, testCase.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package promptbuilder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Supported output encodings. UTF-8 is the default and writes bytes unchanged.
+const (
+	EncodingUTF8   = "utf-8"
+	EncodingLatin1 = "latin1"
+)
+
+// Encoding errors.
+var (
+	ErrUnsupportedEncoding = errors.New("unsupported output encoding")
+	ErrUnencodable         = errors.New("output contains characters the encoding cannot represent")
+)
+
+// outputEncoding resolves an encoding name. UTF-8 resolves to encoding.Nop.
+func outputEncoding(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", EncodingUTF8, "utf8":
+		return encoding.Nop, nil
+	case EncodingLatin1, "latin-1", "iso-8859-1", "iso8859-1":
+		return charmap.ISO8859_1, nil
+	default:
+		return nil, fmt.Errorf("%w: %s (supported: %s, %s)", ErrUnsupportedEncoding, name, EncodingUTF8, EncodingLatin1)
+	}
+}
+
+// encodingWriter transcodes UTF-8 writes into another encoding. Each write
+// must hold complete UTF-8 sequences, which holds for rendered prompts.
+type encodingWriter struct {
+	writer  io.Writer
+	encoder *encoding.Encoder
+}
+
+// NewEncodingWriter returns a writer that transcodes UTF-8 output into the named
+// encoding before writing it to w. Characters that cannot be represented make
+// Write fail with ErrUnencodable. For UTF-8, w is returned unchanged.
+func NewEncodingWriter(w io.Writer, name string) (io.Writer, error) {
+	enc, err := outputEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if enc == encoding.Nop {
+		return w, nil
+	}
+
+	return &encodingWriter{writer: w, encoder: enc.NewEncoder()}, nil
+}
+
+// Write transcodes p and writes it, reporting len(p) on success.
+func (e *encodingWriter) Write(p []byte) (int, error) {
+	encoded, err := e.encoder.Bytes(p)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrUnencodable, err)
+	}
+
+	_, err = e.writer.Write(encoded)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write encoded output: %w", err)
+	}
+
+	return len(p), nil
+}
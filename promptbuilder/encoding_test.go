@@ -0,0 +1,56 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestRunCLI_OutputEncodingLatin1(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	err := promptbuilder.RunCLI([]string{"-p", "Résumé", "-o", "text", "--output-encoding", "latin1"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), []byte("R\xe9sum\xe9\n")) {
+		t.Errorf("Expected Latin-1 bytes, got %q", buf.Bytes())
+	}
+
+	decoded, err := charmap.ISO8859_1.NewDecoder().Bytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to decode Latin-1 output: %v", err)
+	}
+
+	if string(decoded) != "Résumé\n" {
+		t.Errorf("Expected round trip to Résumé, got %q", decoded)
+	}
+}
+
+func TestNewEncodingWriter_Unencodable(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	writer, err := promptbuilder.NewEncodingWriter(&buf, "iso-8859-1")
+	if err != nil {
+		t.Fatalf("NewEncodingWriter() unexpected error = %v", err)
+	}
+
+	_, err = writer.Write([]byte("price: 5€"))
+	if !errors.Is(err, promptbuilder.ErrUnencodable) {
+		t.Errorf("Expected ErrUnencodable, got %v", err)
+	}
+
+	_, err = promptbuilder.NewEncodingWriter(&buf, "ebcdic")
+	if !errors.Is(err, promptbuilder.ErrUnsupportedEncoding) {
+		t.Errorf("Expected ErrUnsupportedEncoding, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package promptbuilder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// ErrorCode is a stable identifier for a class of error, for programs that
+// drive the CLI and should not match error messages.
+type ErrorCode string
+
+// Error codes reported by --json-errors.
+const (
+	CodePromptRequired      ErrorCode = "PROMPT_REQUIRED"
+	CodeFileNotFound        ErrorCode = "FILE_NOT_FOUND"
+	CodeFileTooLarge        ErrorCode = "FILE_TOO_LARGE"
+	CodeTooManyFiles        ErrorCode = "TOO_MANY_FILES"
+	CodeExtensionNotAllowed ErrorCode = "EXTENSION_NOT_ALLOWED"
+	CodePathNotAllowed      ErrorCode = "PATH_NOT_ALLOWED"
+	CodeImageTooLarge       ErrorCode = "IMAGE_TOO_LARGE"
+	CodeUnknownPreset       ErrorCode = "UNKNOWN_PRESET"
+	CodeUnsupportedFormat   ErrorCode = "UNSUPPORTED_FORMAT"
+	CodeInvalidSyntax       ErrorCode = "INVALID_SYNTAX"
+	CodeBuildTimeout        ErrorCode = "BUILD_TIMEOUT"
+	CodeInternal            ErrorCode = "INTERNAL_ERROR"
+)
+
+// errorCodes maps sentinel errors to their codes. The first match wins, so
+// more specific errors come first.
+var errorCodes = []struct {
+	err  error
+	code ErrorCode
+}{
+	{err: ErrPromptRequired, code: CodePromptRequired},
+	{err: fs.ErrNotExist, code: CodeFileNotFound},
+	{err: ErrFileTooLarge, code: CodeFileTooLarge},
+	{err: ErrTooManyFiles, code: CodeTooManyFiles},
+	{err: ErrFileExtensionRequired, code: CodeExtensionNotAllowed},
+	{err: ErrFileExtensionNotAllowed, code: CodeExtensionNotAllowed},
+	{err: ErrSuspiciousPath, code: CodePathNotAllowed},
+	{err: ErrPathOutsideAllowed, code: CodePathNotAllowed},
+	{err: ErrImageTooLarge, code: CodeImageTooLarge},
+	{err: ErrUnknownPreset, code: CodeUnknownPreset},
+	{err: ErrUnsupportedFormat, code: CodeUnsupportedFormat},
+	{err: ErrInvalidSyntax, code: CodeInvalidSyntax},
+	{err: ErrBuildTimeout, code: CodeBuildTimeout},
+}
+
+// ErrorCodeOf returns the code of err, or CodeInternal when err wraps none of
+// the errors that have a code.
+func ErrorCodeOf(err error) ErrorCode {
+	for _, entry := range errorCodes {
+		if errors.Is(err, entry.err) {
+			return entry.code
+		}
+	}
+
+	return CodeInternal
+}
+
+// ErrorReport is the JSON object written for an error by --json-errors.
+type ErrorReport struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// WriteErrorJSON writes err to w as an ErrorReport on a single line.
+func WriteErrorJSON(w io.Writer, err error) error {
+	encoded, marshalErr := json.Marshal(ErrorReport{Code: ErrorCodeOf(err), Message: err.Error()})
+	if marshalErr != nil {
+		return fmt.Errorf("failed to encode error: %w", marshalErr)
+	}
+
+	_, writeErr := fmt.Fprintf(w, "%s\n", encoded)
+	if writeErr != nil {
+		return fmt.Errorf("failed to write error: %w", writeErr)
+	}
+
+	return nil
+}
+
+// AllErrors returns every sentinel error the package can return, so callers
+// can handle them exhaustively with errors.Is. The slice is a new copy on each
+// call.
+func AllErrors() []error {
+	return []error{
+		// Requests and flags
+		ErrPromptRequired, ErrFilePathRequired, ErrFileContentRequired, ErrNoImageInput,
+		ErrUnknownSection, ErrUnbalancedFences, ErrImageAndImageHex, ErrNoPromptInput,
+		ErrPromptAndSplitOn, ErrInvalidNote, ErrInvalidProjectFile, ErrEditAndStdinImage,
+		ErrNegativeMaxIncluded, ErrImageRefConflict,
+		// Builder
+		ErrPresetNameEmpty, ErrUnknownPreset, ErrFormatterName, ErrFormatterNil,
+		ErrImageTooLarge, ErrBuildTimeout, ErrBatchFailures,
+		// Files
+		ErrFileExtensionRequired, ErrFileTooLarge, ErrSuspiciousPath, ErrPathOutsideAllowed,
+		ErrPathIsDirectory, ErrFileExtensionNotAllowed, ErrTooManyFiles, ErrUnknownSortKey,
+		ErrInvalidLanguage, ErrInvalidFunction, ErrFunctionNotFound, ErrInvalidSyntax,
+		ErrInvalidImageData, ErrNotebookUnreadable, ErrPDFEncrypted, ErrPDFUnreadable,
+		ErrUnknownUnreadablePolicy,
+		// Remote files, commands, and publishing
+		ErrURLsDisabled, ErrFetchFailed, ErrHostNotAllowed, ErrEmptyCommand,
+		ErrShellMetacharacters, ErrCommandNotAllowed, ErrSubjectRequired, ErrInvalidSubject, ErrNATSProtocol, ErrUnsupportedNATSURL,
+		ErrNoClipboard, ErrEmptySignKey,
+		// Templates
+		ErrInvalidVar, ErrPromptAndTemplate, ErrNoTemplates,
+		// Output
+		ErrUnsupportedFormat, ErrUnknownJSONKeys, ErrUnknownFileRole, ErrUnknownChatTemplate,
+		ErrUnsupportedEncoding, ErrUnencodable, ErrUnknownModel, ErrUnknownImageDetail,
+	}
+}
+
+// reportedError wraps an error that RunCLI has already written to stderr.
+type reportedError struct {
+	err error
+}
+
+func (e reportedError) Error() string { return e.err.Error() }
+
+func (e reportedError) Unwrap() error { return e.err }
+
+// ErrorReported reports whether RunCLI has already written err to stderr,
+// as it does under --json-errors, so the caller should not print it again.
+func ErrorReported(err error) bool {
+	var reported reportedError
+
+	return errors.As(err, &reported)
+}
+
+// jsonErrorsRequested reports whether args or PROMPT_BUILDER_JSON_ERRORS turn
+// on --json-errors, for errors met before ParseFlags has parsed the
+// arguments. Only the arguments before the first invalid one are read.
+func jsonErrorsRequested(args []string) bool {
+	return probeFlags(args).JSONErrors
+}
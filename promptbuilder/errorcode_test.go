@@ -0,0 +1,217 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want promptbuilder.ErrorCode
+	}{
+		{
+			name: "prompt required",
+			err:  fmt.Errorf("invalid flags: %w", promptbuilder.ErrPromptRequired),
+			want: promptbuilder.CodePromptRequired,
+		},
+		{
+			name: "file too large",
+			err:  fmt.Errorf("failed to process file: %w", promptbuilder.ErrFileTooLarge),
+			want: promptbuilder.CodeFileTooLarge,
+		},
+		{
+			name: "missing file",
+			err:  fmt.Errorf("failed to stat file: %w", fs.ErrNotExist),
+			want: promptbuilder.CodeFileNotFound,
+		},
+		{
+			name: "uncoded error",
+			err:  errors.New("something else"),
+			want: promptbuilder.CodeInternal,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := promptbuilder.ErrorCodeOf(testCase.err)
+			if got != testCase.want {
+				t.Errorf("ErrorCodeOf() = %q, want %q", got, testCase.want)
+			}
+		})
+	}
+}
+
+func TestRunCLI_JSONErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantErr    error
+		wantReport bool
+	}{
+		{name: "flag", args: []string{"--json-errors"}, wantErr: promptbuilder.ErrPromptRequired, wantReport: true},
+		{name: "bool value", args: []string{"-json-errors=t"}, wantErr: promptbuilder.ErrPromptRequired, wantReport: true},
+		{
+			name:       "prompt text is not the flag",
+			args:       []string{"-p", "--json-errors", "-o", "bogus"},
+			wantErr:    promptbuilder.ErrUnsupportedFormat,
+			wantReport: false,
+		},
+		{name: "turned off", args: []string{"--json-errors=false"}, wantErr: promptbuilder.ErrPromptRequired, wantReport: false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var output, stderr bytes.Buffer
+
+			options := promptbuilder.Options{Clipboard: nil, Editor: nil, Stderr: &stderr}
+
+			err := promptbuilder.RunCLIWithOptions(testCase.args, nil, &output, options)
+			if !errors.Is(err, testCase.wantErr) {
+				t.Fatalf("RunCLIWithOptions() error = %v, want %v", err, testCase.wantErr)
+			}
+
+			if promptbuilder.ErrorReported(err) != testCase.wantReport {
+				t.Errorf("ErrorReported() = %v, want %v", promptbuilder.ErrorReported(err), testCase.wantReport)
+			}
+
+			if !testCase.wantReport {
+				if stderr.Len() != 0 {
+					t.Errorf("Expected no error report, got %q", stderr.String())
+				}
+
+				return
+			}
+
+			checkErrorReport(t, stderr.Bytes(), promptbuilder.ErrorCodeOf(testCase.wantErr))
+		})
+	}
+}
+
+//nolint:paralleltest // t.Setenv cannot be used in parallel tests.
+func TestRunCLI_JSONErrorsFromEnvironment(t *testing.T) {
+	t.Setenv("PROMPT_BUILDER_JSON_ERRORS", "1")
+
+	var output, stderr bytes.Buffer
+
+	options := promptbuilder.Options{Clipboard: nil, Editor: nil, Stderr: &stderr}
+
+	err := promptbuilder.RunCLIWithOptions(nil, nil, &output, options)
+	if !promptbuilder.ErrorReported(err) {
+		t.Fatalf("Expected a reported error, got %v", err)
+	}
+
+	checkErrorReport(t, stderr.Bytes(), promptbuilder.CodePromptRequired)
+}
+
+// checkErrorReport checks that stderr holds an error report with code.
+func checkErrorReport(t *testing.T, stderr []byte, code promptbuilder.ErrorCode) {
+	t.Helper()
+
+	var report promptbuilder.ErrorReport
+
+	err := json.Unmarshal(stderr, &report)
+	if err != nil {
+		t.Fatalf("Failed to decode stderr %q: %v", stderr, err)
+	}
+
+	if report.Code != code {
+		t.Errorf("Code = %q, want %q", report.Code, code)
+	}
+
+	if report.Message == "" {
+		t.Error("Expected a message in the error report")
+	}
+}
+
+func TestAllErrors(t *testing.T) {
+	t.Parallel()
+
+	all := promptbuilder.AllErrors()
+
+	for _, want := range []error{promptbuilder.ErrPromptRequired, promptbuilder.ErrFileTooLarge} {
+		if !slices.Contains(all, want) {
+			t.Errorf("AllErrors() is missing %v", want)
+		}
+	}
+
+	seen := make(map[error]bool, len(all))
+	for _, err := range all {
+		if seen[err] {
+			t.Errorf("AllErrors() lists %v more than once", err)
+		}
+
+		seen[err] = true
+	}
+
+	declared := declaredErrorNames(t)
+	if len(all) != len(declared) {
+		t.Errorf("AllErrors() returned %d errors, but the package declares %d: %v", len(all), len(declared), declared)
+	}
+}
+
+// declaredErrorNames returns the exported Err variables declared in the
+// package's non-test source files.
+func declaredErrorNames(t *testing.T) []string {
+	t.Helper()
+
+	paths, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatalf("Failed to list source files: %v", err)
+	}
+
+	var names []string
+
+	fileSet := token.NewFileSet()
+
+	for _, path := range paths {
+		// Other tests create test_*.go fixtures in this directory while
+		// running, so only package sources are parsed.
+		if strings.HasSuffix(path, "_test.go") || strings.HasPrefix(path, "test_") {
+			continue
+		}
+
+		file, err := parser.ParseFile(fileSet, path, nil, 0)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", path, err)
+		}
+
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.VAR {
+				continue
+			}
+
+			for _, spec := range genDecl.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					if strings.HasPrefix(name.Name, "Err") {
+						names = append(names, name.Name)
+					}
+				}
+			}
+		}
+	}
+
+	return names
+}
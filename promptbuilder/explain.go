@@ -0,0 +1,57 @@
+package promptbuilder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Explain returns a human readable breakdown of how the prompt was assembled:
+// where the system message came from, which files were included with their
+// sizes, and the estimated token count. It is intended for debugging preset
+// and file precedence.
+func (r *BuildResult) Explain() string {
+	var builder strings.Builder
+
+	builder.WriteString("Prompt assembly:\n")
+	builder.WriteString("- System message: " + r.explainSystemSource() + "\n")
+
+	if len(r.Files) == 0 {
+		builder.WriteString("- Files: none\n")
+	} else {
+		fmt.Fprintf(&builder, "- Files: %d\n", len(r.Files))
+
+		for _, file := range r.Files {
+			fmt.Fprintf(&builder, "  - %s (%d bytes)\n", file.Path, file.Size)
+		}
+	}
+
+	if r.ImageSize > 0 {
+		fmt.Fprintf(&builder, "- Image: %d bytes\n", r.ImageSize)
+	}
+
+	fmt.Fprintf(&builder, "- Estimated tokens: %d\n", r.TokenEstimate())
+
+	return builder.String()
+}
+
+// explainSystemSource describes the origin of the system message.
+func (r *BuildResult) explainSystemSource() string {
+	switch r.SystemSource {
+	case SystemSourcePreset:
+		return fmt.Sprintf("task preset %q", r.Task)
+	case SystemSourceCustom:
+		if r.Task != "" {
+			return fmt.Sprintf("custom message (overrides task preset %q)", r.Task)
+		}
+
+		return "custom message"
+	case SystemSourceNone:
+		if r.Task != "" {
+			return fmt.Sprintf("none (task preset %q is not registered)", r.Task)
+		}
+
+		return "none"
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,63 @@
+package promptbuilder_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestBuildResult_ExplainPreset(t *testing.T) {
+	t.Parallel()
+
+	tmpFileName, _, cleanup := setupFileProcessorTest(t)
+	t.Cleanup(cleanup)
+
+	builder := newTestBuilder()
+
+	err := builder.AddSystemPreset("coding", "You are an expert developer.")
+	if err != nil {
+		t.Fatalf("AddSystemPreset() unexpected error = %v", err)
+	}
+
+	result, err := builder.BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt: "Explain this code",
+		Task:   "coding",
+		File:   tmpFileName,
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	explanation := result.Explain()
+
+	for _, want := range []string{`task preset "coding"`, tmpFileName, "Estimated tokens:"} {
+		if !strings.Contains(explanation, want) {
+			t.Errorf("Expected %q in explanation, got %q", want, explanation)
+		}
+	}
+}
+
+func TestBuildResult_ExplainCustomOverride(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+
+	err := builder.AddSystemPreset("coding", "You are an expert developer.")
+	if err != nil {
+		t.Fatalf("AddSystemPreset() unexpected error = %v", err)
+	}
+
+	result, err := builder.BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:        "Explain this code",
+		Task:          "coding",
+		SystemMessage: "You are a reviewer.",
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if !strings.Contains(result.Explain(), `custom message (overrides task preset "coding")`) {
+		t.Errorf("Expected override explanation, got %q", result.Explain())
+	}
+}
@@ -0,0 +1,9 @@
+package promptbuilder
+
+import "context"
+
+// BuildPromptGeneral builds req without the prompt-only fast path, so tests
+// and benchmarks can compare the two paths on the same request.
+func (b *Builder) BuildPromptGeneral(req *BuildRequest) (*BuildResult, error) {
+	return b.buildGeneral(context.Background(), req, b.currentTemplates())
+}
@@ -0,0 +1,296 @@
+package promptbuilder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultFetchRetries = 2                      // retries after the first attempt
+	defaultFetchBackoff = 500 * time.Millisecond // delay before the first retry
+	defaultFetchTimeout = 30 * time.Second       // per-request HTTP timeout
+	maxFetchRedirects   = 10                     // matches net/http's default policy
+)
+
+// Errors returned when fetching remote files.
+var (
+	ErrURLsDisabled   = errors.New("remote file inclusion is disabled")
+	ErrFetchFailed    = errors.New("failed to fetch remote file")
+	ErrHostNotAllowed = errors.New("host is not allowed")
+)
+
+// isURL reports whether path refers to a remote http(s) resource.
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// fetchURL downloads a remote file, retrying network errors and 5xx responses
+// with exponential backoff. Client errors (4xx) are not retried.
+func (fp *FileProcessor) fetchURL(ctx context.Context, rawURL string) (*FileContent, error) {
+	if !fp.AllowURLs {
+		return nil, fmt.Errorf("%w: %s", ErrURLsDisabled, rawURL)
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", rawURL, err)
+	}
+
+	err = fp.checkHost(ctx, parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	err = fp.ValidateFile(parsed.Path)
+	if err != nil {
+		return nil, fmt.Errorf("file validation failed: %w", err)
+	}
+
+	client, release := fp.fetchClient()
+	defer release()
+
+	backoff := fp.FetchBackoff
+
+	for attempt := 0; ; attempt++ {
+		content, retryable, err := fetchOnce(ctx, client, rawURL, fp.maxFileSize)
+		if err == nil {
+			return fp.processFetched(rawURL, parsed.Path, content)
+		}
+
+		if !retryable || attempt >= fp.FetchRetries {
+			return nil, err
+		}
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil, fmt.Errorf("%w: %s: %w", ErrFetchFailed, rawURL, ctx.Err())
+		case <-timer.C:
+		}
+
+		backoff *= 2
+	}
+}
+
+// processFetched runs the content of the remote file at rawURL through the
+// same steps as a local file, chosen by the extension of urlPath.
+func (fp *FileProcessor) processFetched(rawURL, urlPath string, content []byte) (*FileContent, error) {
+	size := int64(len(content))
+
+	fileContent, err := fp.processContent(urlPath, content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", rawURL, err)
+	}
+
+	fileContent.Path = rawURL
+	fileContent.Size = size
+
+	return fileContent, nil
+}
+
+// fetchOnce performs a single GET request with client, reading at most
+// maxSize bytes. It reports whether a failure is worth retrying.
+func fetchOnce(ctx context.Context, client *http.Client, rawURL string, maxSize int64) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid request for %s: %w", rawURL, err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, ErrHostNotAllowed) {
+			return nil, false, fmt.Errorf("%w: %s: %w", ErrFetchFailed, rawURL, err)
+		}
+
+		return nil, true, fmt.Errorf("%w: %s: %w", ErrFetchFailed, rawURL, err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, true, fmt.Errorf("%w: %s: %s", ErrFetchFailed, rawURL, resp.Status)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, false, fmt.Errorf("%w: %s: %s", ErrFetchFailed, rawURL, resp.Status)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, true, fmt.Errorf("%w: %s: %w", ErrFetchFailed, rawURL, err)
+	}
+
+	if int64(len(content)) > maxSize {
+		return nil, false, fmt.Errorf("%w: %s exceeds %d bytes", ErrFileTooLarge, rawURL, maxSize)
+	}
+
+	return content, false, nil
+}
+
+// newFetchClient returns the default HTTP client for remote files. Its
+// transport checks every address it connects to, and redirects are checked
+// against the same host rules as the original request.
+func (fp *FileProcessor) newFetchClient() *http.Client {
+	client := &http.Client{
+		Transport:     fp.guardTransport(nil),
+		CheckRedirect: fp.checkRedirect(nil),
+		Jar:           nil,
+		Timeout:       defaultFetchTimeout,
+	}
+	fp.guardedClient = client
+
+	return client
+}
+
+// fetchClient returns the client for one remote file and a function that
+// releases it. A caller-supplied HTTPClient is copied with the host checks
+// added to its redirect policy and, when it uses an *http.Transport, to its
+// connections; other transports are trusted to do their own checking.
+func (fp *FileProcessor) fetchClient() (*http.Client, func()) {
+	if fp.HTTPClient != nil && fp.HTTPClient == fp.guardedClient {
+		return fp.HTTPClient, func() {}
+	}
+
+	base := fp.HTTPClient
+	if base == nil {
+		base = http.DefaultClient
+	}
+
+	client := *base
+	client.CheckRedirect = fp.checkRedirect(base.CheckRedirect)
+
+	transport, ok := base.Transport.(*http.Transport)
+	if base.Transport != nil && !ok {
+		return &client, func() {}
+	}
+
+	guarded := fp.guardTransport(transport)
+	client.Transport = guarded
+
+	return &client, guarded.CloseIdleConnections
+}
+
+// guardTransport returns a copy of base, or of http.DefaultTransport when base
+// is nil, that refuses to connect to addresses checkAddress rejects. The check
+// runs on the resolved address being dialled, so a host name cannot pass a
+// lookup and then resolve elsewhere for the connection.
+func (fp *FileProcessor) guardTransport(base *http.Transport) *http.Transport {
+	if base == nil {
+		base, _ = http.DefaultTransport.(*http.Transport)
+	}
+
+	transport := base.Clone()
+	dialer := &net.Dialer{
+		Timeout:   defaultFetchTimeout,
+		KeepAlive: 0,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			return fp.checkAddress(address)
+		},
+	}
+	transport.DialContext = dialer.DialContext
+	transport.DialTLSContext = nil
+	// A proxy would be the address dialled instead of the host, so the
+	// check above could not see where requests go.
+	transport.Proxy = nil
+
+	return transport
+}
+
+// checkRedirect returns a redirect policy that applies next, or net/http's
+// default limit when next is nil, and then checks the target host.
+func (fp *FileProcessor) checkRedirect(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if next != nil {
+			err := next(req, via)
+			if err != nil {
+				return err
+			}
+		} else if len(via) >= maxFetchRedirects {
+			return fmt.Errorf("%w: stopped after %d redirects", ErrFetchFailed, maxFetchRedirects)
+		}
+
+		return fp.checkHost(req.Context(), req.URL)
+	}
+}
+
+// checkHost enforces AllowedHosts and, unless AllowPrivateHosts is set,
+// rejects IP literals that checkAddress would refuse. Host names are checked
+// when the transport connects to the addresses they resolve to.
+func (fp *FileProcessor) checkHost(_ context.Context, target *url.URL) error {
+	host := strings.ToLower(target.Hostname())
+
+	if len(fp.AllowedHosts) > 0 && !slices.ContainsFunc(fp.AllowedHosts, func(allowed string) bool {
+		return strings.EqualFold(allowed, host)
+	}) {
+		return fmt.Errorf("%w: %s is not in the allowed hosts", ErrHostNotAllowed, host)
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil || fp.AllowPrivateHosts || isPublicAddress(addr) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s is a non-public address", ErrHostNotAllowed, host)
+}
+
+// checkAddress rejects a dialled "ip:port" address that is not public, unless
+// AllowPrivateHosts is set.
+func (fp *FileProcessor) checkAddress(address string) error {
+	if fp.AllowPrivateHosts {
+		return nil
+	}
+
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: cannot check address %s: %w", ErrHostNotAllowed, address, err)
+	}
+
+	if !isPublicAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: connection to non-public address %s", ErrHostNotAllowed, addrPort.Addr())
+	}
+
+	return nil
+}
+
+// nonPublicPrefixes are special-purpose ranges that netip.Addr's predicates
+// do not cover.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved and broadcast
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use IPv4/IPv6 translation
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+}
+
+// isPublicAddress reports whether addr is a global unicast address outside
+// the private and special-purpose ranges.
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	return !slices.ContainsFunc(nonPublicPrefixes, func(prefix netip.Prefix) bool {
+		return prefix.Contains(addr)
+	})
+}
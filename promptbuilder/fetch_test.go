@@ -0,0 +1,288 @@
+package promptbuilder_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+// newFlakyServer returns a server that fails with status for the first
+// failures requests and then serves body.
+func newFlakyServer(t *testing.T, failures int32, status int, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var hits atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if hits.Add(1) <= failures {
+			w.WriteHeader(status)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &hits
+}
+
+// newURLProcessor returns a file processor that may fetch remote text files,
+// including from the loopback test servers.
+func newURLProcessor() *promptbuilder.FileProcessor {
+	fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".txt"})
+	fileProcessor.AllowURLs = true
+	fileProcessor.AllowPrivateHosts = true
+	fileProcessor.FetchBackoff = time.Millisecond
+
+	return fileProcessor
+}
+
+func TestFileProcessor_ProcessFile_URLRetriesServerErrors(t *testing.T) {
+	t.Parallel()
+
+	server, hits := newFlakyServer(t, 2, http.StatusServiceUnavailable, "remote notes")
+
+	content, err := newURLProcessor().ProcessFile(server.URL + "/notes.txt")
+	if err != nil {
+		t.Fatalf("ProcessFile() unexpected error = %v", err)
+	}
+
+	if string(content.Content) != "remote notes" {
+		t.Errorf("Expected remote content, got %q", content.Content)
+	}
+
+	if hits.Load() != 3 {
+		t.Errorf("Expected 3 requests, got %d", hits.Load())
+	}
+}
+
+func TestFileProcessor_ProcessFile_URLUsesFileOptions(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{
+		"/docs/guide.md":   "---\ntitle: Guide\n---\nRead me.\n",
+		"/pkg/main.go":     "package main\n\nfunc Run() int {\n\treturn 1\n}\n",
+		"/pkg/broken.json": "{\"key\": ",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(files[r.URL.Path]))
+	}))
+	t.Cleanup(server.Close)
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".md", ".go", ".json"})
+	fileProcessor.AllowURLs = true
+	fileProcessor.AllowPrivateHosts = true
+	fileProcessor.StripFrontMatter = true
+	fileProcessor.SignaturesOnly = true
+	fileProcessor.ValidateSyntax = true
+
+	guide, err := fileProcessor.ProcessFile(server.URL + "/docs/guide.md?raw=1")
+	if err != nil {
+		t.Fatalf("ProcessFile() unexpected error = %v", err)
+	}
+
+	if string(guide.Content) != "Read me.\n" || guide.FrontMatter["title"] != "Guide" {
+		t.Errorf("Expected the front matter to be split off, got %q and %v", guide.Content, guide.FrontMatter)
+	}
+
+	if guide.Path != server.URL+"/docs/guide.md?raw=1" {
+		t.Errorf("Expected the URL as the path, got %s", guide.Path)
+	}
+
+	source, err := fileProcessor.ProcessFile(server.URL + "/pkg/main.go")
+	if err != nil {
+		t.Fatalf("ProcessFile() unexpected error = %v", err)
+	}
+
+	if strings.Contains(string(source.Content), "return 1") {
+		t.Errorf("Expected only signatures of the Go file, got %q", source.Content)
+	}
+
+	_, err = fileProcessor.ProcessFile(server.URL + "/pkg/broken.json")
+	if !errors.Is(err, promptbuilder.ErrInvalidSyntax) {
+		t.Errorf("Expected ErrInvalidSyntax for the fetched JSON, got %v", err)
+	}
+}
+
+func TestFileProcessor_ProcessFile_URLDoesNotRetryClientErrors(t *testing.T) {
+	t.Parallel()
+
+	server, hits := newFlakyServer(t, 5, http.StatusNotFound, "remote notes")
+
+	_, err := newURLProcessor().ProcessFile(server.URL + "/notes.txt")
+	if !errors.Is(err, promptbuilder.ErrFetchFailed) {
+		t.Errorf("Expected ErrFetchFailed, got %v", err)
+	}
+
+	if hits.Load() != 1 {
+		t.Errorf("Expected a single request, got %d", hits.Load())
+	}
+}
+
+func TestFileProcessor_ProcessFile_URLsDisabledByDefault(t *testing.T) {
+	t.Parallel()
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".txt"})
+
+	_, err := fileProcessor.ProcessFile("https://example.com/notes.txt")
+	if !errors.Is(err, promptbuilder.ErrURLsDisabled) {
+		t.Errorf("Expected ErrURLsDisabled, got %v", err)
+	}
+}
+
+func TestBuilder_BuildPromptContextTimeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+
+		_, _ = w.Write([]byte("too late"))
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	builder := promptbuilder.New(newURLProcessor())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	_, err := builder.BuildPromptContext(ctx, &promptbuilder.BuildRequest{
+		Prompt: "Summarize",
+		File:   server.URL + "/slow.txt",
+	})
+	if !errors.Is(err, promptbuilder.ErrBuildTimeout) {
+		t.Fatalf("Expected ErrBuildTimeout, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the build to stop at the deadline, took %s", elapsed)
+	}
+}
+
+func TestFileProcessor_ProcessFile_AllowedHosts(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newFlakyServer(t, 0, http.StatusOK, "allowed notes")
+
+	fileProcessor := newURLProcessor()
+	fileProcessor.AllowedHosts = []string{"127.0.0.1"}
+
+	content, err := fileProcessor.ProcessFile(server.URL + "/notes.txt")
+	if err != nil {
+		t.Fatalf("ProcessFile() unexpected error = %v", err)
+	}
+
+	if string(content.Content) != "allowed notes" {
+		t.Errorf("Expected allowed content, got %q", content.Content)
+	}
+
+	_, err = fileProcessor.ProcessFile("https://example.com/notes.txt")
+	if !errors.Is(err, promptbuilder.ErrHostNotAllowed) {
+		t.Errorf("Expected ErrHostNotAllowed for a host outside the allowlist, got %v", err)
+	}
+}
+
+func TestFileProcessor_ProcessFile_BlocksLoopbackByDefault(t *testing.T) {
+	t.Parallel()
+
+	server, hits := newFlakyServer(t, 0, http.StatusOK, "internal notes")
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".txt"})
+	fileProcessor.AllowURLs = true
+
+	_, err := fileProcessor.ProcessFile(server.URL + "/notes.txt")
+	if !errors.Is(err, promptbuilder.ErrHostNotAllowed) {
+		t.Errorf("Expected ErrHostNotAllowed for 127.0.0.1, got %v", err)
+	}
+
+	if hits.Load() != 0 {
+		t.Errorf("Expected no request to reach the loopback server, got %d", hits.Load())
+	}
+}
+
+func TestFileProcessor_ProcessFile_RedirectChecksAllowedHosts(t *testing.T) {
+	t.Parallel()
+
+	target, hits := newFlakyServer(t, 0, http.StatusOK, "other notes")
+	otherHost := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+
+	redirector := httptest.NewServer(http.RedirectHandler(otherHost+"/notes.txt", http.StatusFound))
+	t.Cleanup(redirector.Close)
+
+	fileProcessor := newURLProcessor()
+	fileProcessor.AllowedHosts = []string{"127.0.0.1"}
+
+	_, err := fileProcessor.ProcessFile(redirector.URL + "/notes.txt")
+	if !errors.Is(err, promptbuilder.ErrHostNotAllowed) {
+		t.Errorf("Expected ErrHostNotAllowed for the redirect target, got %v", err)
+	}
+
+	if hits.Load() != 0 {
+		t.Errorf("Expected the redirect not to be followed, got %d requests", hits.Load())
+	}
+}
+
+func TestFileProcessor_ProcessFile_BlocksNonPublicAddresses(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		rawURL string
+	}{
+		{name: "carrier-grade NAT", rawURL: "http://100.64.0.1/notes.txt"},
+		{name: "mapped loopback", rawURL: "http://[::ffff:127.0.0.1]/notes.txt"},
+		{name: "reserved", rawURL: "http://240.0.0.1/notes.txt"},
+		{name: "this network", rawURL: "http://0.1.2.3/notes.txt"},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".txt"})
+			fileProcessor.AllowURLs = true
+
+			_, err := fileProcessor.ProcessFile(testCase.rawURL)
+			if !errors.Is(err, promptbuilder.ErrHostNotAllowed) {
+				t.Errorf("ProcessFile(%s) = %v, want ErrHostNotAllowed", testCase.rawURL, err)
+			}
+		})
+	}
+}
+
+func TestFileProcessor_ProcessFile_ChecksResolvedAddressWithCallerClient(t *testing.T) {
+	t.Parallel()
+
+	server, hits := newFlakyServer(t, 0, http.StatusOK, "internal notes")
+	byName := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".txt"})
+	fileProcessor.AllowURLs = true
+	fileProcessor.FetchRetries = 0
+	fileProcessor.HTTPClient = &http.Client{Transport: &http.Transport{}, CheckRedirect: nil, Jar: nil, Timeout: time.Second}
+
+	_, err := fileProcessor.ProcessFile(byName + "/notes.txt")
+	if !errors.Is(err, promptbuilder.ErrHostNotAllowed) {
+		t.Errorf("Expected ErrHostNotAllowed for a name resolving to loopback, got %v", err)
+	}
+
+	if hits.Load() != 0 {
+		t.Errorf("Expected no request to reach the loopback server, got %d", hits.Load())
+	}
+}
@@ -1,13 +1,78 @@
 package promptbuilder
 
 import (
+	"cmp"
+	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
+const (
+	defaultMaxFiles = 100 // default limit for directory and glob expansion
+)
+
+// Supported keys for SortFiles. SortByInput keeps the order in which files were
+// given and is the default.
+const (
+	SortByInput = ""
+	SortByName  = "name"
+	SortBySize  = "size"
+	SortByMtime = "mtime"
+)
+
+// PathDisplay selects how file paths are shown in fence headers.
+type PathDisplay string
+
+// Supported path display modes. PathDisplayAsGiven keeps the path exactly as the
+// user supplied it and is the default.
+const (
+	PathDisplayAsGiven  PathDisplay = ""
+	PathDisplayRelative PathDisplay = "relative"
+	PathDisplayAbsolute PathDisplay = "absolute"
+	PathDisplayBasename PathDisplay = "basename"
+)
+
+// UnreadablePolicy controls how files that cannot be read are handled when
+// they were found by expanding a directory or glob.
+type UnreadablePolicy string
+
+// Supported unreadable file policies. UnreadableSkipWithWarning is the
+// default, and an empty policy behaves the same way.
+const (
+	UnreadableFail            UnreadablePolicy = "fail"
+	UnreadableSkip            UnreadablePolicy = "skip"
+	UnreadableSkipWithWarning UnreadablePolicy = "skip-with-warning"
+)
+
+// ErrUnknownUnreadablePolicy is returned for a policy other than fail, skip,
+// or skip-with-warning.
+var ErrUnknownUnreadablePolicy = errors.New("unknown unreadable file policy")
+
+// ParseUnreadablePolicy returns the policy named by name. An empty name
+// selects UnreadableSkipWithWarning.
+func ParseUnreadablePolicy(name string) (UnreadablePolicy, error) {
+	switch policy := UnreadablePolicy(name); policy {
+	case "":
+		return UnreadableSkipWithWarning, nil
+	case UnreadableFail, UnreadableSkip, UnreadableSkipWithWarning:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("%w: %s (valid: %s, %s, %s)", ErrUnknownUnreadablePolicy,
+			name, UnreadableFail, UnreadableSkip, UnreadableSkipWithWarning)
+	}
+}
+
 // ErrFileExtensionRequired is returned when a file path doesn't have an extension.
 var (
 	ErrFileExtensionRequired   = errors.New("file must have an extension")
@@ -16,53 +81,293 @@ var (
 	ErrPathOutsideAllowed      = errors.New("file path is outside allowed directories")
 	ErrPathIsDirectory         = errors.New("path is a directory, not a file")
 	ErrFileExtensionNotAllowed = errors.New("file extension is not allowed") // Add this line
+	ErrTooManyFiles            = errors.New("too many files")
+	ErrUnknownSortKey          = errors.New("unknown file sort key")
+	ErrInvalidLanguage         = errors.New("language override must be in pattern=language form")
 )
 
 // FileProcessor handles file operations for prompt building. It is responsible for
 // reading, validating, and fencing file content to be included in a prompt.
 type FileProcessor struct {
+	// MaxFiles limits how many files a directory or glob may expand to, and
+	// how many all the directories and globs given to one ProcessFiles call
+	// may expand to together. A value of zero or less disables the limit.
+	MaxFiles int
+
+	// UnreadableFiles decides whether a file or subdirectory that cannot be
+	// read while expanding a directory or glob fails the whole set or is
+	// skipped, with or without a warning. Paths named directly always fail.
+	UnreadableFiles UnreadablePolicy
+
+	// ExcludeGlobs drops matching paths while directories and globs are
+	// expanded. Patterns match the path relative to the expanded directory,
+	// or the glob match as returned; patterns without a slash also match the
+	// base name, so "*_test.go" excludes test files at any depth. Files named
+	// directly are never excluded.
+	ExcludeGlobs []string
+
+	// PathDisplay controls how file paths appear in fence headers so that full
+	// machine paths need not leak into prompts.
+	PathDisplay PathDisplay
+
+	// IncludeGitInfo records the last commit touching each file and shows it in
+	// the fence header. Files outside a git repository are left unannotated.
+	IncludeGitInfo bool
+
+	// AllowURLs enables including remote http(s) files. Remote fetches are
+	// retried FetchRetries times on network errors and 5xx responses, waiting
+	// FetchBackoff before the first retry and doubling it after each one.
+	AllowURLs    bool
+	FetchRetries int
+	FetchBackoff time.Duration
+	HTTPClient   *http.Client
+
+	// AllowedHosts, when non-empty, restricts remote files to these host
+	// names. Connections to loopback, private, and other non-public
+	// addresses are refused unless AllowPrivateHosts is set; the check also
+	// covers redirects and a caller-supplied HTTPClient.
+	AllowedHosts      []string
+	AllowPrivateHosts bool
+
+	// SignaturesOnly reduces .go files to their package-level declarations with
+	// function bodies removed, so large packages fit in context. Files that do
+	// not parse are included in full.
+	SignaturesOnly bool
+
+	// LineNumbers prefixes each line of fenced file content with its
+	// right-aligned, 1-based line number so models can cite lines.
+	LineNumbers bool
+
+	// CollapseBlanks replaces runs of blank lines in fenced file content with a
+	// single empty line to save tokens. Indentation is left intact.
+	CollapseBlanks bool
+
+	// StripFrontMatter removes "---" delimited front matter from .md files
+	// before they are fenced, keeping its top-level keys in
+	// FileContent.FrontMatter.
+	StripFrontMatter bool
+
+	// ImagesAsBase64 includes files with an image extension whose content is
+	// a recognized image as a base64 data URI, as for attached images,
+	// instead of as raw bytes. It is meant for directories that mix images
+	// with text; the size limit applies to the encoded data URI.
+	ImagesAsBase64 bool
+
+	// ValidateSyntax makes ProcessFile fail with ErrInvalidSyntax when a .go
+	// or .json file does not parse, so broken snippets are not sent.
+	ValidateSyntax bool
+
+	// FileSystem, when set, is read instead of the operating system's file
+	// system, for example an embed.FS or an fstest.MapFS in tests. Paths are
+	// then names within it and must satisfy fs.ValidPath, which rules out
+	// absolute paths and ".." elements; the home, working, and temporary
+	// directory checks apply only to the operating system.
+	FileSystem fs.FS
+
 	maxFileSize       int64
 	allowedExtensions []string
+	languageOverrides map[string]string
+	cache             *fileCache
+	guardedClient     *http.Client
 }
 
 // NewFileProcessor creates a new file processor with the given constraints. This
 // function is the designated constructor for the FileProcessor struct and ensures
 // that the processor is initialized with the necessary constraints.
 func NewFileProcessor(maxFileSize int64, allowedExtensions []string) *FileProcessor {
-	return &FileProcessor{
+	fileProcessor := &FileProcessor{
+		MaxFiles:          defaultMaxFiles,
+		UnreadableFiles:   UnreadableSkipWithWarning,
+		ExcludeGlobs:      nil,
+		PathDisplay:       PathDisplayAsGiven,
+		IncludeGitInfo:    false,
+		AllowURLs:         false,
+		FetchRetries:      defaultFetchRetries,
+		FetchBackoff:      defaultFetchBackoff,
+		HTTPClient:        nil,
+		AllowedHosts:      nil,
+		AllowPrivateHosts: false,
+		SignaturesOnly:    false,
+		LineNumbers:       false,
+		CollapseBlanks:    false,
+		StripFrontMatter:  false,
+		ImagesAsBase64:    false,
+		ValidateSyntax:    false,
+		FileSystem:        nil,
 		maxFileSize:       maxFileSize,
 		allowedExtensions: allowedExtensions,
+		languageOverrides: nil,
+		cache:             nil,
+		guardedClient:     nil,
 	}
+
+	fileProcessor.HTTPClient = fileProcessor.newFetchClient()
+
+	return fileProcessor
+}
+
+// Clone returns a copy of the processor whose settings, including language
+// overrides, can be changed without affecting fp. The clone shares fp's cache
+// of processed files, which is safe for concurrent use, until EnableCache is
+// called on either, and any HTTPClient supplied by the caller.
+func (fp *FileProcessor) Clone() *FileProcessor {
+	clone := *fp
+	clone.ExcludeGlobs = slices.Clone(fp.ExcludeGlobs)
+	clone.AllowedHosts = slices.Clone(fp.AllowedHosts)
+	clone.allowedExtensions = slices.Clone(fp.allowedExtensions)
+	clone.languageOverrides = maps.Clone(fp.languageOverrides)
+
+	// The default client checks hosts against fp's settings, so the clone
+	// needs its own.
+	if fp.HTTPClient != nil && fp.HTTPClient == fp.guardedClient {
+		clone.HTTPClient = clone.newFetchClient()
+	}
+
+	return &clone
 }
 
 // ProcessFile reads and validates a file, returning its content. This is the main
 // entry point for the file processor and is responsible for orchestrating the
 // entire file processing workflow.
 func (fp *FileProcessor) ProcessFile(path string) (*FileContent, error) {
+	return fp.ProcessFileContext(context.Background(), path)
+}
+
+// ProcessFileContext is like ProcessFile but stops when ctx is done, including
+// while fetching or retrying a remote file. Warnings about the file, such as
+// a Go file included in full because its signatures could not be parsed, are
+// logged.
+func (fp *FileProcessor) ProcessFileContext(ctx context.Context, path string) (*FileContent, error) {
+	fileContent, err := fp.processFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, warning := range fileContent.warnings {
+		log.Print(warning)
+	}
+
+	return fileContent, nil
+}
+
+// processFile implements ProcessFileContext, leaving the file's warnings on
+// the returned FileContent instead of logging them.
+func (fp *FileProcessor) processFile(ctx context.Context, path string) (*FileContent, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to process %s: %w", path, err)
+	}
+
+	if isURL(path) {
+		return fp.fetchURL(ctx, path)
+	}
+
 	// Validate file path and extension
-	err := fp.ValidateFile(path)
+	err = fp.ValidateFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("file validation failed: %w", err)
 	}
 
-	// Validate path is absolute or relative to current directory
-	absPath, err := filepath.Abs(path)
+	// Validate the path against traversal and resolve it for reading
+	name, fileInfo, err := fp.statLocalFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("invalid file path %s: %w", path, err)
+		return nil, err
 	}
 
-	// Additional security validation: ensure the path doesn't contain path traversal
-	err = fp.validatePathSecurity(absPath)
-	if err != nil {
-		return nil, fmt.Errorf("security validation failed for %s: %w", absPath, err)
+	cacheKey := fp.cacheKey(name, fileInfo)
+
+	if fp.cache != nil {
+		if cached, ok := fp.cache.get(cacheKey); ok {
+			cached.Path = path
+
+			return cached, nil
+		}
 	}
 
 	// Read file content
-	// #nosec G304 -- Path is validated for security: checked for path traversal,
-	// suspicious patterns, and ensured it's within current working directory
-	content, err := os.ReadFile(absPath)
+	content, err := fp.readLocalFile(name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s: %w", absPath, err)
+		return nil, err
+	}
+
+	fileContent, err := fp.processContent(path, content)
+	if err != nil {
+		return nil, err
+	}
+
+	fileContent.Size = fileInfo.Size()
+	fileContent.ModTime = fileInfo.ModTime()
+
+	if fp.IncludeGitInfo && fp.FileSystem == nil {
+		fileContent.LastCommit = lastCommit(path)
+	}
+
+	if fp.cache != nil {
+		fp.cache.add(cacheKey, fileContent)
+	}
+
+	return fileContent, nil
+}
+
+// processContent turns the raw bytes of the file at path into its
+// FileContent: images are embedded, PDF and notebook text is extracted,
+// syntax is checked, and Go files are reduced to signatures and Markdown
+// front matter is split off when those options are set. The extension of
+// path picks the steps, so remote files pass the path of their URL. Size and
+// ModTime are left for the caller to set.
+func (fp *FileProcessor) processContent(path string, content []byte) (*FileContent, error) {
+	var (
+		err      error
+		warnings []string
+	)
+
+	sourceDigest := sha256.Sum256(content)
+
+	// Embed recognized images as data URIs rather than raw bytes
+	if fp.ImagesAsBase64 && isImageFile(path) && ValidateImageData(content) == nil {
+		content = []byte(imageDataURI(content))
+	}
+
+	// Extract the text of PDF documents; the size limit applies to the text
+	if strings.EqualFold(filepath.Ext(path), ".pdf") {
+		content, err = extractPDFText(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract text from %s: %w", path, err)
+		}
+	}
+
+	// Reduce notebooks to their cell sources; outputs are dropped
+	if strings.EqualFold(filepath.Ext(path), ".ipynb") {
+		content, err = extractNotebookText(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract cells from %s: %w", path, err)
+		}
+	}
+
+	if fp.ValidateSyntax {
+		err = checkSyntax(path, content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if fp.SignaturesOnly && filepath.Ext(path) == ".go" {
+		signatures, err := goSignatures(path, content)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("including %s in full: %v", path, err))
+		} else {
+			content = signatures
+		}
+	}
+
+	var frontMatter map[string]string
+
+	if fp.StripFrontMatter && strings.EqualFold(filepath.Ext(path), ".md") {
+		metadata, body, found := splitFrontMatter(content)
+		if found {
+			frontMatter = metadata
+			content = body
+		}
 	}
 
 	// Check file size
@@ -71,36 +376,670 @@ func (fp *FileProcessor) ProcessFile(path string) (*FileContent, error) {
 			ErrFileTooLarge, path, len(content), fp.maxFileSize)
 	}
 
-	// Get file info for size
-	fileInfo, err := os.Stat(path)
+	return &FileContent{
+		Path:         path,
+		Content:      content,
+		Size:         int64(len(content)),
+		ModTime:      time.Time{},
+		LastCommit:   "",
+		FrontMatter:  frontMatter,
+		sourceDigest: sourceDigest,
+		warnings:     warnings,
+	}, nil
+}
+
+// ProcessPath processes a single path that may name a file, a directory, or a
+// glob pattern, returning the content of every file it expands to.
+func (fp *FileProcessor) ProcessPath(path string) ([]*FileContent, error) {
+	return fp.ProcessFiles([]string{path})
+}
+
+// ExpandPath expands a directory or glob pattern into the files it contains.
+// Directories are walked recursively, keeping only files with allowed
+// extensions and skipping hidden entries. Plain file paths are returned as-is.
+// ErrTooManyFiles is returned when the expansion exceeds MaxFiles.
+// Subdirectories skipped under UnreadableFiles are logged.
+func (fp *FileProcessor) ExpandPath(path string) ([]string, error) {
+	expanded, warnings, err := fp.expandPath(context.Background(), path, fp.MaxFiles)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get file info for %s: %w", path, err)
+		return nil, err
 	}
 
-	return &FileContent{
-		Path:    path,
-		Content: content,
-		Size:    fileInfo.Size(),
-	}, nil
+	for _, warning := range warnings {
+		log.Print(warning)
+	}
+
+	return expanded, nil
+}
+
+// expandPath implements ExpandPath, stopping directory walks when ctx is done
+// and returning warnings for skipped subdirectories instead of logging them.
+// A maxFiles of zero or less disables the limit.
+func (fp *FileProcessor) expandPath(ctx context.Context, path string, maxFiles int) ([]string, []string, error) {
+	if isURL(path) {
+		return []string{path}, nil, nil
+	}
+
+	var expanded, warnings []string
+
+	if strings.ContainsAny(path, "*?[") {
+		matches, err := fp.glob(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid glob pattern %s: %w", path, err)
+		}
+
+		for _, match := range matches {
+			if fp.excluded(match) {
+				continue
+			}
+
+			files, matchWarnings, err := fp.expandDirectory(ctx, match)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			expanded = append(expanded, files...)
+			warnings = append(warnings, matchWarnings...)
+		}
+	} else {
+		files, dirWarnings, err := fp.expandDirectory(ctx, path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		expanded = files
+		warnings = dirWarnings
+	}
+
+	if maxFiles > 0 && len(expanded) > maxFiles {
+		return nil, nil, fmt.Errorf("%w: %s expands to %d files (max %d)",
+			ErrTooManyFiles, path, len(expanded), maxFiles)
+	}
+
+	return expanded, warnings, nil
+}
+
+// expandPaths expands every path in order, limiting the files that
+// directories and globs expand to, taken together, to maxFiles. Plain file
+// paths do not count toward the limit.
+func (fp *FileProcessor) expandPaths(ctx context.Context, paths []string, maxFiles int) ([]string, []string, error) {
+	expanded := make([]string, 0, len(paths))
+
+	var warnings []string
+
+	found := 0
+
+	for _, path := range paths {
+		files, pathWarnings, err := fp.expandPath(ctx, path, maxFiles)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(files) != 1 || files[0] != path {
+			found += len(files)
+		}
+
+		if maxFiles > 0 && found > maxFiles {
+			return nil, nil, fmt.Errorf("%w: directories and globs expand to more than %d files",
+				ErrTooManyFiles, maxFiles)
+		}
+
+		expanded = append(expanded, files...)
+		warnings = append(warnings, pathWarnings...)
+	}
+
+	return expanded, warnings, nil
+}
+
+// skipUnreadable reports whether a file or directory found by expansion that
+// failed with err is skipped under UnreadableFiles, and the warning to record
+// for it, if any. Only errors reaching the file system are skipped; files
+// rejected by validation still fail.
+func (fp *FileProcessor) skipUnreadable(err error) (bool, string) {
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) {
+		return false, ""
+	}
+
+	switch fp.UnreadableFiles {
+	case UnreadableFail:
+		return false, ""
+	case UnreadableSkip:
+		return true, ""
+	default:
+		return true, fmt.Sprintf("skipping unreadable path: %v", err)
+	}
+}
+
+// expandDirectory returns the allowed files below path when it is a directory,
+// or path itself otherwise, with warnings for unreadable subdirectories that
+// were skipped.
+func (fp *FileProcessor) expandDirectory(ctx context.Context, path string) ([]string, []string, error) {
+	info, statErr := fp.stat(path)
+
+	// Missing files and other stat errors are reported later by ProcessFile.
+	isDir := statErr == nil && info.IsDir()
+	if !isDir {
+		return []string{path}, nil, nil
+	}
+
+	var files, warnings []string
+
+	err := fp.walkDir(path, func(entryPath string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			skip, warning := fp.skipUnreadable(walkErr)
+			if !skip || entryPath == path {
+				return walkErr
+			}
+
+			if warning != "" {
+				warnings = append(warnings, warning)
+			}
+
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if entryPath != path && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		relPath, relErr := filepath.Rel(path, entryPath)
+		if relErr == nil && entryPath != path && fp.excluded(relPath) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if entry.IsDir() || !slices.Contains(fp.allowedExtensions, filepath.Ext(entryPath)) {
+			return nil
+		}
+
+		files = append(files, entryPath)
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to walk directory %s: %w", path, err)
+	}
+
+	return files, warnings, nil
+}
+
+// excluded reports whether path matches one of the ExcludeGlobs.
+func (fp *FileProcessor) excluded(path string) bool {
+	slashPath := filepath.ToSlash(path)
+
+	for _, pattern := range fp.ExcludeGlobs {
+		if matched, _ := filepath.Match(pattern, slashPath); matched {
+			return true
+		}
+
+		if !strings.Contains(pattern, "/") {
+			if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ProcessFiles processes several files in order, skipping duplicates. Directories
+// and glob patterns are expanded first. A file is
+// considered a duplicate when it resolves to an absolute path that was already
+// processed or when its content is identical to an earlier file, which happens
+// easily when overlapping globs are expanded by the shell. When the paths
+// expand to more than one file, files over the size limit are skipped too.
+// Skipped files are logged.
+func (fp *FileProcessor) ProcessFiles(paths []string) ([]*FileContent, error) {
+	return fp.ProcessFilesContext(context.Background(), paths)
+}
+
+// ProcessFilesContext is like ProcessFiles but stops when ctx is done.
+func (fp *FileProcessor) ProcessFilesContext(ctx context.Context, paths []string) ([]*FileContent, error) {
+	contents, _, warnings, err := fp.processFiles(ctx, paths, fileSelection{sortBy: SortByInput, reverse: false, limit: 0}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, warning := range warnings {
+		log.Print(warning)
+	}
+
+	return contents, nil
+}
+
+// fileSelection orders the expanded paths and limits how many files are read.
+type fileSelection struct {
+	sortBy  string
+	reverse bool
+
+	// limit stops reading once this many files are included, after MaxFiles
+	// has been applied to the expansion. Zero reads every file.
+	limit int
+}
+
+// processFiles implements ProcessFilesContext, returning the reasons files
+// were skipped as warnings instead of logging them. When paths expand to more
+// than one file, files over the size limit are skipped with a warning rather
+// than failing the whole set, and files found by expansion that cannot be
+// read are handled according to UnreadableFiles. The expanded paths are
+// sorted by selection before reading, and the display paths of files left
+// unread by its limit are returned as omitted. Image files larger than
+// maxImageBytes fail with ErrImageTooLarge; zero or less disables the check.
+func (fp *FileProcessor) processFiles(
+	ctx context.Context, paths []string, selection fileSelection, maxImageBytes int,
+) (contents []*FileContent, omitted, warnings []string, err error) {
+	named := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		named[path] = true
+	}
+
+	paths, warnings, err = fp.expandPaths(ctx, paths, fp.MaxFiles)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	paths, err = fp.sortPaths(paths, selection.sortBy, selection.reverse)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	contents = make([]*FileContent, 0, len(paths))
+	seenPaths := make(map[string]string, len(paths))
+	seenHashes := make(map[[sha256.Size]byte]string, len(paths))
+
+	for index, path := range paths {
+		if selection.limit > 0 && len(contents) == selection.limit {
+			for _, rest := range paths[index:] {
+				omitted = append(omitted, fp.DisplayPath(rest))
+			}
+
+			break
+		}
+
+		absPath, err := resolvePath(path)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if original, ok := seenPaths[absPath]; ok {
+			warnings = append(warnings, fmt.Sprintf("skipping duplicate file %s (same path as %s)", path, original))
+
+			continue
+		}
+
+		fileContent, err := fp.processFile(ctx, path)
+		if errors.Is(err, ErrFileTooLarge) && len(paths) > 1 {
+			warnings = append(warnings, fmt.Sprintf("skipping file: %v", err))
+
+			continue
+		}
+
+		if err != nil && !named[path] {
+			if skip, warning := fp.skipUnreadable(err); skip {
+				if warning != "" {
+					warnings = append(warnings, warning)
+				}
+
+				continue
+			}
+		}
+
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if isImageFile(path) {
+			err = checkImageSize(fp.DisplayPath(path), fileContent.Size, maxImageBytes)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		}
+
+		hash := sha256.Sum256(fileContent.Content)
+		if original, ok := seenHashes[hash]; ok {
+			warnings = append(warnings, fmt.Sprintf("skipping duplicate file %s (same content as %s)", path, original))
+
+			continue
+		}
+
+		seenPaths[absPath] = path
+		seenHashes[hash] = path
+		warnings = append(warnings, fileContent.warnings...)
+
+		contents = append(contents, fileContent)
+	}
+
+	return contents, omitted, warnings, nil
+}
+
+// sortPaths returns paths in the order SortFiles would put their files, using
+// the size and modification time on disk so the order is known before any
+// file is read. Paths that cannot be stated, such as URLs, sort as empty.
+func (fp *FileProcessor) sortPaths(paths []string, sortBy string, reverse bool) ([]string, error) {
+	if sortBy == SortByInput {
+		return paths, nil
+	}
+
+	stubs := make([]*FileContent, 0, len(paths))
+	for _, path := range paths {
+		var (
+			size    int64
+			modTime time.Time
+		)
+
+		if !isURL(path) {
+			if info, err := fp.stat(path); err == nil {
+				size = info.Size()
+				modTime = info.ModTime()
+			}
+		}
+
+		stubs = append(stubs, &FileContent{
+			Path: path, Content: nil, Size: size, ModTime: modTime, LastCommit: "", Note: "", FrontMatter: nil,
+			sourceDigest: [sha256.Size]byte{}, warnings: nil,
+		})
+	}
+
+	err := SortFiles(stubs, sortBy, reverse)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := make([]string, 0, len(stubs))
+	for _, stub := range stubs {
+		sorted = append(sorted, stub.Path)
+	}
+
+	return sorted, nil
+}
+
+// resolvePath returns the absolute form of a local path. URLs are returned
+// unchanged.
+func resolvePath(path string) (string, error) {
+	if isURL(path) {
+		return path, nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid file path %s: %w", path, err)
+	}
+
+	return absPath, nil
+}
+
+// SortFiles orders files in place by name, size, or modification time,
+// ascending unless reverse is set. The sort is stable so files with equal keys
+// keep their input order. SortByInput leaves the order unchanged.
+func SortFiles(files []*FileContent, sortBy string, reverse bool) error {
+	var compare func(a, b *FileContent) int
+
+	switch sortBy {
+	case SortByInput:
+		return nil
+	case SortByName:
+		compare = func(a, b *FileContent) int { return strings.Compare(a.Path, b.Path) }
+	case SortBySize:
+		compare = func(a, b *FileContent) int { return cmp.Compare(a.Size, b.Size) }
+	case SortByMtime:
+		compare = func(a, b *FileContent) int { return a.ModTime.Compare(b.ModTime) }
+	default:
+		return fmt.Errorf("%w: %s (valid: name, size, mtime)", ErrUnknownSortKey, sortBy)
+	}
+
+	slices.SortStableFunc(files, func(a, b *FileContent) int {
+		if reverse {
+			return compare(b, a)
+		}
+
+		return compare(a, b)
+	})
+
+	return nil
+}
+
+// DisplayPath returns the path as it should appear in fence headers according to
+// the PathDisplay option. Paths that cannot be resolved are shown as given.
+func (fp *FileProcessor) DisplayPath(path string) string {
+	if isURL(path) {
+		return path
+	}
+
+	switch fp.PathDisplay {
+	case PathDisplayAsGiven:
+		return path
+	case PathDisplayBasename:
+		return filepath.Base(path)
+	case PathDisplayAbsolute:
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return path
+		}
+
+		return absPath
+	case PathDisplayRelative:
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return path
+		}
+
+		cwd, err := os.Getwd()
+		if err != nil {
+			return path
+		}
+
+		relPath, err := filepath.Rel(cwd, absPath)
+		if err != nil {
+			return path
+		}
+
+		return relPath
+	default:
+		return path
+	}
 }
 
 // FenceContent wraps file content with BEGIN/END markers for security and clarity.
 // This makes it clear to the model where the file content begins and ends.
 func (fp *FileProcessor) FenceContent(content []byte, filename string) string {
-	ext := filepath.Ext(filename)
+	return fenceContent(content, filename, "", fp.FenceLanguage(filename), nil)
+}
+
+// FenceFile fences processed file content, showing the path according to
+// PathDisplay, the file's note, and any file metadata such as the last commit
+// in the header.
+func (fp *FileProcessor) FenceFile(fileContent *FileContent) string {
+	return fenceContent(fp.fileText(fileContent), fp.DisplayPath(fileContent.Path), fileContent.Note,
+		fp.FenceLanguage(fileContent.Path), fileHeaderLines(fileContent))
+}
+
+// fileText returns the content of a processed file as FenceFile shows it,
+// with blank lines collapsed and lines numbered when those options are set.
+func (fp *FileProcessor) fileText(fileContent *FileContent) []byte {
+	content := fileContent.Content
+	if fp.CollapseBlanks {
+		content = collapseBlankLines(content)
+	}
+
+	if fp.LineNumbers {
+		content = numberLines(content)
+	}
+
+	return content
+}
+
+// fileHeaderLines returns the metadata lines shown before a file's content,
+// such as its last commit.
+func fileHeaderLines(fileContent *FileContent) []string {
+	var headerLines []string
+
+	if fileContent.LastCommit != "" {
+		headerLines = append(headerLines, "Last commit: "+fileContent.LastCommit)
+	}
+
+	return headerLines
+}
+
+// SetLanguageForPath forces the code fence language for matching files. The
+// pattern is either an extension, with or without the leading dot ("tmpl"),
+// or a filename glob matched against the base name ("Dockerfile", "*.tpl").
+// Later calls for the same pattern replace earlier ones. Extensionless files
+// whose exact name has a language set pass ValidateFile.
+func (fp *FileProcessor) SetLanguageForPath(pattern, language string) error {
+	if strings.TrimSpace(pattern) == "" || strings.TrimSpace(language) == "" {
+		return fmt.Errorf("%w: %q=%q", ErrInvalidLanguage, pattern, language)
+	}
+
+	_, err := filepath.Match(pattern, "")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidLanguage, err)
+	}
+
+	if fp.languageOverrides == nil {
+		fp.languageOverrides = make(map[string]string)
+	}
+
+	fp.languageOverrides[pattern] = language
+
+	return nil
+}
+
+// FenceLanguage returns the code fence language for path: a language set with
+// SetLanguageForPath, the language of a known code extension, or "" when the
+// file should not be wrapped in a code fence.
+func (fp *FileProcessor) FenceLanguage(path string) string {
+	base := filepath.Base(path)
+	ext := filepath.Ext(path)
+
+	for _, pattern := range slices.Sorted(maps.Keys(fp.languageOverrides)) {
+		matched, _ := filepath.Match(pattern, base)
+		if matched || (ext != "" && strings.TrimPrefix(pattern, ".") == ext[1:]) {
+			return fp.languageOverrides[pattern]
+		}
+	}
+
+	if isCodeFile(ext) {
+		return getLanguageFromExt(ext)
+	}
+
+	return ""
+}
+
+// LanguageTable returns the fence language of every built-in code extension
+// together with the patterns registered with SetLanguageForPath. An override
+// of a built-in extension replaces its entry.
+func (fp *FileProcessor) LanguageTable() map[string]string {
+	table := maps.Clone(extensionLanguages)
+
+	for pattern, language := range fp.languageOverrides {
+		if _, builtin := extensionLanguages["."+pattern]; builtin {
+			pattern = "." + pattern
+		}
+
+		table[pattern] = language
+	}
 
+	return table
+}
+
+// collapseBlankLines replaces every run of whitespace-only lines with a single
+// empty line.
+func collapseBlankLines(content []byte) []byte {
+	var builder strings.Builder
+
+	previousBlank := false
+
+	for line := range strings.Lines(string(content)) {
+		blank := strings.TrimSpace(line) == ""
+		if blank && previousBlank {
+			continue
+		}
+
+		if blank {
+			line = strings.TrimLeft(line, " \t\r\v\f")
+		}
+
+		builder.WriteString(line)
+
+		previousBlank = blank
+	}
+
+	return []byte(builder.String())
+}
+
+// numberLines prefixes every line with its right-aligned 1-based number, e.g.
+// " 9: " and "10: ". A trailing newline does not start a new numbered line.
+func numberLines(content []byte) []byte {
+	text := strings.TrimSuffix(string(content), "\n")
+	if text == "" {
+		return content
+	}
+
+	lines := strings.Split(text, "\n")
+	width := len(strconv.Itoa(len(lines)))
+
+	var builder strings.Builder
+
+	for index, line := range lines {
+		if index > 0 {
+			builder.WriteString("\n")
+		}
+
+		builder.WriteString(strings.TrimRight(fmt.Sprintf("%*d: %s", width, index+1, line), " "))
+	}
+
+	if strings.HasSuffix(string(content), "\n") {
+		builder.WriteString("\n")
+	}
+
+	return []byte(builder.String())
+}
+
+// fenceNoteSeparator separates the filename from a note in a BEGIN marker.
+const fenceNoteSeparator = " — "
+
+// fenceContent wraps content in BEGIN/END markers, adding a code fence when a
+// language is given. A note is appended to the BEGIN marker, and header lines
+// are written directly after it.
+func fenceContent(content []byte, filename, note, language string, headerLines []string) string {
 	var builder strings.Builder
 
-	builder.WriteString(fmt.Sprintf("BEGIN %s\n", filename))
+	builder.WriteString("BEGIN " + filename)
+
+	if note = strings.TrimSpace(note); note != "" {
+		builder.WriteString(fenceNoteSeparator + strings.ReplaceAll(note, "\n", " "))
+	}
+
+	builder.WriteString("\n")
+
+	for _, line := range headerLines {
+		builder.WriteString(line + "\n")
+	}
 
 	// Add code fence if it's a code file
-	if isCodeFile(ext) {
-		builder.WriteString(fmt.Sprintf("```%s\n", getLanguageFromExt(ext)))
+	if language != "" {
+		builder.WriteString(fmt.Sprintf("```%s\n", language))
 	}
 
 	builder.Write(content)
 
-	if isCodeFile(ext) {
+	if language != "" {
 		builder.WriteString("\n```")
 	}
 
@@ -109,6 +1048,13 @@ func (fp *FileProcessor) FenceContent(content []byte, filename string) string {
 	return builder.String()
 }
 
+// fenceName returns the filename of a BEGIN marker line, without any note.
+func fenceName(line string) string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(line, "BEGIN "), fenceNoteSeparator)
+
+	return name
+}
+
 // ValidateFile checks if a file path is valid according to the processor's rules.
 // This function is responsible for ensuring that the file path is not empty, has a
 // valid extension, and that the extension is allowed.
@@ -119,6 +1065,12 @@ func (fp *FileProcessor) ValidateFile(path string) error {
 
 	ext := filepath.Ext(path)
 	if ext == "" {
+		// Extensionless files such as Dockerfile are accepted once a fence
+		// language has been set for their exact name.
+		if _, ok := fp.languageOverrides[filepath.Base(path)]; ok {
+			return nil
+		}
+
 		return ErrFileExtensionRequired
 	}
 
@@ -167,24 +1119,25 @@ func isCodeFile(ext string) bool {
 	return false
 }
 
+// extensionLanguages maps code file extensions to their fence languages.
+var extensionLanguages = map[string]string{
+	".go":   "go",
+	".py":   "python",
+	".js":   "javascript",
+	".ts":   "typescript",
+	".java": "java",
+	".cpp":  "cpp",
+	".c":    "c",
+	".h":    "c",
+	".cs":   "csharp",
+	".php":  "php",
+	".rb":   "ruby",
+	".rs":   "rust",
+}
+
 // getLanguageFromExt returns the language identifier for code fencing.
 func getLanguageFromExt(ext string) string {
-	languageMap := map[string]string{
-		".go":   "go",
-		".py":   "python",
-		".js":   "javascript",
-		".ts":   "typescript",
-		".java": "java",
-		".cpp":  "cpp",
-		".c":    "c",
-		".h":    "c",
-		".cs":   "csharp",
-		".php":  "php",
-		".rb":   "ruby",
-		".rs":   "rust",
-	}
-
-	if lang, exists := languageMap[ext]; exists {
+	if lang, exists := extensionLanguages[ext]; exists {
 		return lang
 	}
 
@@ -1,8 +1,12 @@
 package promptbuilder_test
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/book-expert/prompt-builder/promptbuilder"
@@ -114,3 +118,396 @@ func TestFileProcessor_ProcessFile_Security(t *testing.T) {
 		})
 	}
 }
+
+func TestFileProcessor_ProcessFiles_SkipsDuplicateContent(t *testing.T) {
+	t.Parallel()
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024*1024, []string{".go", ".txt"})
+
+	tmpFileName, _, cleanup := setupFileProcessorTest(t)
+	t.Cleanup(cleanup)
+
+	copyName, _, copyCleanup := setupFileProcessorTest(t)
+	t.Cleanup(copyCleanup)
+
+	contents, err := fileProcessor.ProcessFiles([]string{tmpFileName, tmpFileName, copyName})
+	if err != nil {
+		t.Fatalf("ProcessFiles() unexpected error = %v", err)
+	}
+
+	if len(contents) != 1 {
+		t.Fatalf("Expected 1 unique file, got %d", len(contents))
+	}
+
+	if contents[0].Path != tmpFileName {
+		t.Errorf("Expected first occurrence %s to be kept, got %s", tmpFileName, contents[0].Path)
+	}
+}
+
+// writeNumberedFiles creates count text files in dir and returns their paths.
+func writeNumberedFiles(t *testing.T, dir string, count int) []string {
+	t.Helper()
+
+	paths := make([]string, 0, count)
+
+	for index := range count {
+		path := filepath.Join(dir, fmt.Sprintf("file_%02d.txt", index))
+
+		err := os.WriteFile(path, fmt.Appendf(nil, "content %d", index), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+func TestFileProcessor_ProcessPath_Directory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	want := writeNumberedFiles(t, dir, 3)
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".txt"})
+
+	contents, err := fileProcessor.ProcessPath(dir)
+	if err != nil {
+		t.Fatalf("ProcessPath() unexpected error = %v", err)
+	}
+
+	if len(contents) != len(want) {
+		t.Fatalf("Expected %d files, got %d", len(want), len(contents))
+	}
+
+	for index, content := range contents {
+		if content.Path != want[index] {
+			t.Errorf("Expected file %d to be %s, got %s", index, want[index], content.Path)
+		}
+	}
+}
+
+func TestFileProcessor_ProcessPath_TooManyFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeNumberedFiles(t, dir, 5)
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".txt"})
+	fileProcessor.MaxFiles = 3
+
+	for _, path := range []string{dir, filepath.Join(dir, "*.txt")} {
+		_, err := fileProcessor.ProcessPath(path)
+		if !errors.Is(err, promptbuilder.ErrTooManyFiles) {
+			t.Errorf("ProcessPath(%s) expected ErrTooManyFiles, got %v", path, err)
+		}
+	}
+}
+
+func TestFileProcessor_ProcessFiles_TooManyFilesCombined(t *testing.T) {
+	t.Parallel()
+
+	first, second := t.TempDir(), t.TempDir()
+	named := writeNumberedFiles(t, first, 2)
+
+	for _, name := range []string{"x.txt", "y.txt"} {
+		err := os.WriteFile(filepath.Join(second, name), []byte(name), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".txt"})
+	fileProcessor.MaxFiles = 3
+
+	// Each glob stays within the limit, but together they exceed it.
+	_, err := fileProcessor.ProcessFiles([]string{filepath.Join(first, "*.txt"), filepath.Join(second, "*.txt")})
+	if !errors.Is(err, promptbuilder.ErrTooManyFiles) {
+		t.Errorf("Expected ErrTooManyFiles for the combined expansion, got %v", err)
+	}
+
+	// Files named directly do not count toward the limit.
+	contents, err := fileProcessor.ProcessFiles(append(named, second))
+	if err != nil {
+		t.Fatalf("ProcessFiles() unexpected error = %v", err)
+	}
+
+	if len(contents) != 4 {
+		t.Errorf("Expected 4 files, got %d", len(contents))
+	}
+}
+
+func TestFileProcessor_ExpandPath_ExcludeGlobs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	for _, name := range []string{"main.go", "main_test.go", "sub/util.go", "sub/util_test.go", "vendor/dep.go"} {
+		path := filepath.Join(dir, name)
+
+		err := os.MkdirAll(filepath.Dir(path), 0o750)
+		if err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+
+		err = os.WriteFile(path, []byte("package main\n"), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		exclude []string
+		want    []string
+	}{
+		{
+			name:    "directory",
+			path:    dir,
+			exclude: []string{"*_test.go", "vendor"},
+			want:    []string{"main.go", "sub/util.go"},
+		},
+		{
+			name:    "relative path pattern",
+			path:    dir,
+			exclude: []string{"sub/*"},
+			want:    []string{"main.go", "main_test.go", "vendor/dep.go"},
+		},
+		{
+			name:    "glob",
+			path:    filepath.Join(dir, "*.go"),
+			exclude: []string{"*_test.go"},
+			want:    []string{"main.go"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".go"})
+			fileProcessor.ExcludeGlobs = test.exclude
+
+			paths, err := fileProcessor.ExpandPath(test.path)
+			if err != nil {
+				t.Fatalf("ExpandPath() unexpected error = %v", err)
+			}
+
+			got := make([]string, 0, len(paths))
+
+			for _, path := range paths {
+				relPath, err := filepath.Rel(dir, path)
+				if err != nil {
+					t.Fatalf("Failed to relativize %s: %v", path, err)
+				}
+
+				got = append(got, filepath.ToSlash(relPath))
+			}
+
+			if !slices.Equal(got, test.want) {
+				t.Errorf("ExpandPath() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFileProcessor_ProcessFile_KeepsGivenPath(t *testing.T) {
+	t.Parallel()
+
+	tmpFileName, cwd, cleanup := setupFileProcessorTest(t)
+	t.Cleanup(cleanup)
+
+	relativeName, err := filepath.Rel(cwd, tmpFileName)
+	if err != nil {
+		t.Fatalf("Failed to compute relative path: %v", err)
+	}
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024*1024, []string{".go"})
+
+	content, err := fileProcessor.ProcessFile(relativeName)
+	if err != nil {
+		t.Fatalf("ProcessFile() unexpected error = %v", err)
+	}
+
+	if content.Path != relativeName {
+		t.Errorf("Expected path %s, got %s", relativeName, content.Path)
+	}
+}
+
+func TestFileProcessor_DisplayPath(t *testing.T) {
+	t.Parallel()
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current working directory: %v", err)
+	}
+
+	givenPath := filepath.Join("testdata", "sample.go")
+
+	tests := []struct {
+		name    string
+		display promptbuilder.PathDisplay
+		path    string
+		want    string
+	}{
+		{name: "as given", display: promptbuilder.PathDisplayAsGiven, path: givenPath, want: givenPath},
+		{name: "relative", display: promptbuilder.PathDisplayRelative, path: filepath.Join(cwd, givenPath), want: givenPath},
+		{name: "absolute", display: promptbuilder.PathDisplayAbsolute, path: givenPath, want: filepath.Join(cwd, givenPath)},
+		{name: "basename", display: promptbuilder.PathDisplayBasename, path: givenPath, want: "sample.go"},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".go"})
+			fileProcessor.PathDisplay = testCase.display
+
+			got := fileProcessor.DisplayPath(testCase.path)
+			if got != testCase.want {
+				t.Errorf("DisplayPath(%s) = %s, want %s", testCase.path, got, testCase.want)
+			}
+		})
+	}
+}
+
+func TestFileProcessor_FenceFileLineNumbers(t *testing.T) {
+	t.Parallel()
+
+	lines := make([]string, 0, 10)
+	for index := range 10 {
+		lines = append(lines, fmt.Sprintf("line %d", index+1))
+	}
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".go"})
+	fileProcessor.LineNumbers = true
+
+	fenced := fileProcessor.FenceFile(&promptbuilder.FileContent{
+		Path:    "main.go",
+		Content: []byte(strings.Join(lines, "\n") + "\n"),
+		Size:    0,
+	})
+
+	if !strings.Contains(fenced, "```go\n 1: line 1\n 2: line 2\n") {
+		t.Errorf("Expected numbered lines inside the code fence, got %q", fenced)
+	}
+
+	if !strings.Contains(fenced, "10: line 10\n\n```") {
+		t.Errorf("Expected line 10 to be the last numbered line, got %q", fenced)
+	}
+
+	if strings.Contains(fenced, "11:") {
+		t.Errorf("Expected no number for the trailing newline, got %q", fenced)
+	}
+}
+
+func TestFileProcessor_SetLanguageForPath(t *testing.T) {
+	t.Parallel()
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".go", ".tmpl"})
+
+	for pattern, language := range map[string]string{"Dockerfile": "dockerfile", "tmpl": "gotemplate"} {
+		err := fileProcessor.SetLanguageForPath(pattern, language)
+		if err != nil {
+			t.Fatalf("SetLanguageForPath(%s) unexpected error = %v", pattern, err)
+		}
+	}
+
+	fenced := fileProcessor.FenceContent([]byte("FROM golang:1.25"), "build/Dockerfile")
+	if !strings.Contains(fenced, "BEGIN build/Dockerfile\n```dockerfile\nFROM golang:1.25\n```\nEND build/Dockerfile") {
+		t.Errorf("Expected dockerfile fence, got %q", fenced)
+	}
+
+	if got := fileProcessor.FenceLanguage("views/page.tmpl"); got != "gotemplate" {
+		t.Errorf("Expected gotemplate for .tmpl, got %q", got)
+	}
+
+	if got := fileProcessor.FenceLanguage("main.go"); got != "go" {
+		t.Errorf("Expected detected language go, got %q", got)
+	}
+
+	err := fileProcessor.SetLanguageForPath("Dockerfile", "")
+	if !errors.Is(err, promptbuilder.ErrInvalidLanguage) {
+		t.Errorf("Expected ErrInvalidLanguage, got %v", err)
+	}
+}
+
+func TestFileProcessor_CloneIsIndependent(t *testing.T) {
+	t.Parallel()
+
+	base := promptbuilder.NewFileProcessor(1024, []string{".go", ".tmpl"})
+	base.ExcludeGlobs = []string{"*_test.go"}
+
+	clone := base.Clone()
+
+	err := clone.SetLanguageForPath("tmpl", "gotemplate")
+	if err != nil {
+		t.Fatalf("SetLanguageForPath() unexpected error = %v", err)
+	}
+
+	clone.ExcludeGlobs[0] = "vendor/*"
+
+	if got := base.FenceLanguage("page.tmpl"); got != "" {
+		t.Errorf("Expected the clone's language override to stay off the base, got %q", got)
+	}
+
+	if base.ExcludeGlobs[0] != "*_test.go" {
+		t.Errorf("Expected the base exclude globs to be unchanged, got %q", base.ExcludeGlobs)
+	}
+
+	if clone.HTTPClient == base.HTTPClient {
+		t.Error("Expected the clone to get its own default HTTP client")
+	}
+}
+
+func TestFileProcessor_ProcessFileExtensionlessWithLanguage(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "Dockerfile")
+
+	err := os.WriteFile(path, []byte("FROM scratch"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".go"})
+
+	_, err = fileProcessor.ProcessFile(path)
+	if !errors.Is(err, promptbuilder.ErrFileExtensionRequired) {
+		t.Fatalf("Expected ErrFileExtensionRequired without an override, got %v", err)
+	}
+
+	err = fileProcessor.SetLanguageForPath("Dockerfile", "dockerfile")
+	if err != nil {
+		t.Fatalf("SetLanguageForPath() unexpected error = %v", err)
+	}
+
+	content, err := fileProcessor.ProcessFile(path)
+	if err != nil {
+		t.Fatalf("ProcessFile() unexpected error = %v", err)
+	}
+
+	if fenced := fileProcessor.FenceFile(content); !strings.Contains(fenced, "```dockerfile\nFROM scratch") {
+		t.Errorf("Expected dockerfile fence, got %q", fenced)
+	}
+}
+
+func TestFileProcessor_FenceFileCollapseBlanks(t *testing.T) {
+	t.Parallel()
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".txt"})
+	fileProcessor.CollapseBlanks = true
+
+	fenced := fileProcessor.FenceFile(&promptbuilder.FileContent{
+		Path:    "data.txt",
+		Content: []byte("first\n\n \n\n\t\n\nsecond\n    indented\n"),
+		Size:    0,
+	})
+
+	want := "BEGIN data.txt\nfirst\n\nsecond\n    indented\n\nEND data.txt"
+	if fenced != want {
+		t.Errorf("Expected %q, got %q", want, fenced)
+	}
+}
@@ -0,0 +1,132 @@
+package promptbuilder
+
+import (
+	"container/list"
+	"io/fs"
+	"sync"
+)
+
+// fileCacheKey identifies one version of a file as processed with one set of
+// options: a change to its modification time or size, or to the options,
+// makes earlier cache entries unreachable.
+type fileCacheKey struct {
+	path    string
+	modTime int64
+	size    int64
+	options fileOptions
+}
+
+// fileOptions are the FileProcessor settings that change what ProcessFile
+// returns for a file, or whether it fails, after the file has been read.
+type fileOptions struct {
+	maxFileSize      int64
+	imagesAsBase64   bool
+	validateSyntax   bool
+	signaturesOnly   bool
+	stripFrontMatter bool
+	includeGitInfo   bool
+}
+
+// cacheKey returns the cache key of the file at absPath with info under the
+// processor's current settings.
+func (fp *FileProcessor) cacheKey(absPath string, info fs.FileInfo) fileCacheKey {
+	return fileCacheKey{
+		path:    absPath,
+		modTime: info.ModTime().UnixNano(),
+		size:    info.Size(),
+		options: fileOptions{
+			maxFileSize:      fp.maxFileSize,
+			imagesAsBase64:   fp.ImagesAsBase64,
+			validateSyntax:   fp.ValidateSyntax,
+			signaturesOnly:   fp.SignaturesOnly,
+			stripFrontMatter: fp.StripFrontMatter,
+			includeGitInfo:   fp.IncludeGitInfo,
+		},
+	}
+}
+
+// fileCacheEntry is the value stored in each element of the recency list.
+type fileCacheEntry struct {
+	key     fileCacheKey
+	content *FileContent
+}
+
+// fileCache is a least recently used cache of processed files, safe for
+// concurrent use.
+type fileCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	recency    *list.List
+	entries    map[fileCacheKey]*list.Element
+}
+
+// newFileCache returns an empty cache holding at most maxEntries files.
+func newFileCache(maxEntries int) *fileCache {
+	return &fileCache{
+		mu:         sync.Mutex{},
+		maxEntries: maxEntries,
+		recency:    list.New(),
+		entries:    make(map[fileCacheKey]*list.Element),
+	}
+}
+
+// get returns a copy of the cached content for key, marking it as the most
+// recently used entry.
+func (c *fileCache) get(key fileCacheKey) (*FileContent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.recency.MoveToFront(element)
+
+	content := *element.Value.(*fileCacheEntry).content
+
+	return &content, true
+}
+
+// add stores a copy of content under key, evicting the least recently used
+// entry when the cache is full.
+func (c *fileCache) add(key fileCacheKey, content *FileContent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stored := *content
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*fileCacheEntry).content = &stored
+		c.recency.MoveToFront(element)
+
+		return
+	}
+
+	c.entries[key] = c.recency.PushFront(&fileCacheEntry{key: key, content: &stored})
+
+	if c.recency.Len() > c.maxEntries {
+		oldest := c.recency.Back()
+		c.recency.Remove(oldest)
+		delete(c.entries, oldest.Value.(*fileCacheEntry).key)
+	}
+}
+
+// EnableCache keeps up to maxEntries processed local files in memory, so
+// ProcessFile returns a file again without reading it while its modification
+// time and size are unchanged. Files are cached separately for each
+// combination of the options that change their content, such as
+// ImagesAsBase64 and ValidateSyntax, so changing an option never returns a
+// file processed under the old one. The least recently used file is evicted
+// when the cache is full. Calling EnableCache again starts with an empty
+// cache, and a maxEntries of zero or less disables caching. Cached results
+// share their Content with the cache and must not be modified.
+func (fp *FileProcessor) EnableCache(maxEntries int) {
+	if maxEntries <= 0 {
+		fp.cache = nil
+
+		return
+	}
+
+	fp.cache = newFileCache(maxEntries)
+}
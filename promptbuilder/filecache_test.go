@@ -0,0 +1,136 @@
+package promptbuilder_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+// rewriteKeepingStat replaces the content of path with content of the same
+// length and restores its modification time, so only a read can notice.
+func rewriteKeepingStat(t *testing.T, path, content string) {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat %s: %v", path, err)
+	}
+
+	err = os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to rewrite %s: %v", path, err)
+	}
+
+	err = os.Chtimes(path, time.Time{}, info.ModTime())
+	if err != nil {
+		t.Fatalf("Failed to restore mtime of %s: %v", path, err)
+	}
+}
+
+func TestFileProcessor_EnableCache(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		maxEntries  int
+		wantContent string
+	}{
+		{name: "cached file is not read again", maxEntries: 8, wantContent: "first"},
+		{name: "disabled cache reads the file", maxEntries: 0, wantContent: "again"},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "notes.txt")
+
+			err := os.WriteFile(path, []byte("first"), 0o600)
+			if err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+
+			processor := promptbuilder.NewFileProcessor(1024*1024, []string{".txt"})
+			processor.EnableCache(testCase.maxEntries)
+
+			_, err = processor.ProcessFile(path)
+			if err != nil {
+				t.Fatalf("ProcessFile() unexpected error = %v", err)
+			}
+
+			rewriteKeepingStat(t, path, "again")
+
+			content, err := processor.ProcessFile(path)
+			if err != nil {
+				t.Fatalf("ProcessFile() unexpected error = %v", err)
+			}
+
+			if string(content.Content) != testCase.wantContent {
+				t.Errorf("Content = %q, want %q", content.Content, testCase.wantContent)
+			}
+		})
+	}
+}
+
+func TestFileProcessor_CacheEvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")}
+
+	processor := promptbuilder.NewFileProcessor(1024*1024, []string{".txt"})
+	processor.EnableCache(1)
+
+	for _, path := range paths {
+		err := os.WriteFile(path, []byte("first"), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		_, err = processor.ProcessFile(path)
+		if err != nil {
+			t.Fatalf("ProcessFile() unexpected error = %v", err)
+		}
+	}
+
+	rewriteKeepingStat(t, paths[0], "again")
+
+	content, err := processor.ProcessFile(paths[0])
+	if err != nil {
+		t.Fatalf("ProcessFile() unexpected error = %v", err)
+	}
+
+	if string(content.Content) != "again" {
+		t.Errorf("Expected evicted file to be read again, got %q", content.Content)
+	}
+}
+
+func TestFileProcessor_CacheKeepsOptionsApart(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "broken.go")
+
+	err := os.WriteFile(path, []byte("package main\nfunc broken( {\n"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	processor := promptbuilder.NewFileProcessor(1024*1024, []string{".go"})
+	processor.EnableCache(8)
+
+	_, err = processor.ProcessFile(path)
+	if err != nil {
+		t.Fatalf("ProcessFile() without syntax validation unexpected error = %v", err)
+	}
+
+	processor.ValidateSyntax = true
+
+	_, err = processor.ProcessFile(path)
+	if !errors.Is(err, promptbuilder.ErrInvalidSyntax) {
+		t.Errorf("Expected ErrInvalidSyntax once ValidateSyntax is set, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package promptbuilder
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// fsName converts a path given to the processor into a name within
+// FileSystem, rejecting names that could escape it.
+func fsName(path string) (string, error) {
+	name := filepath.ToSlash(filepath.Clean(path))
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("%w: %s is not a valid file system name", ErrSuspiciousPath, path)
+	}
+
+	return name, nil
+}
+
+// statLocalFile validates a local file path and returns the name to read it
+// by, which is its name within FileSystem or its absolute path, together with
+// its file info. Directories are rejected with ErrPathIsDirectory.
+func (fp *FileProcessor) statLocalFile(path string) (string, fs.FileInfo, error) {
+	if fp.FileSystem != nil {
+		name, err := fsName(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("security validation failed for %s: %w", path, err)
+		}
+
+		info, err := fs.Stat(fp.FileSystem, name)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to stat file %s: %w", name, err)
+		}
+
+		if info.IsDir() {
+			return "", nil, fmt.Errorf("%w: path %s is a directory, not a file", ErrPathIsDirectory, name)
+		}
+
+		return name, info, nil
+	}
+
+	// Validate path is absolute or relative to current directory
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid file path %s: %w", path, err)
+	}
+
+	// Additional security validation: ensure the path doesn't contain path traversal
+	err = fp.validatePathSecurity(absPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("security validation failed for %s: %w", absPath, err)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get file info for %s: %w", path, err)
+	}
+
+	return absPath, info, nil
+}
+
+// readLocalFile reads a file by the name returned from statLocalFile.
+func (fp *FileProcessor) readLocalFile(name string) ([]byte, error) {
+	var (
+		content []byte
+		err     error
+	)
+
+	if fp.FileSystem != nil {
+		content, err = fs.ReadFile(fp.FileSystem, name)
+	} else {
+		// #nosec G304 -- Path is validated for security: checked for path traversal,
+		// suspicious patterns, and ensured it's within current working directory
+		content, err = os.ReadFile(name)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", name, err)
+	}
+
+	return content, nil
+}
+
+// stat returns the file info of path in FileSystem or the operating system.
+func (fp *FileProcessor) stat(path string) (fs.FileInfo, error) {
+	if fp.FileSystem == nil {
+		return os.Stat(path)
+	}
+
+	name, err := fsName(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.Stat(fp.FileSystem, name)
+}
+
+// glob returns the paths matching pattern in FileSystem or the operating
+// system.
+func (fp *FileProcessor) glob(pattern string) ([]string, error) {
+	if fp.FileSystem == nil {
+		return filepath.Glob(pattern)
+	}
+
+	return fs.Glob(fp.FileSystem, filepath.ToSlash(filepath.Clean(pattern)))
+}
+
+// walkDir walks the tree rooted at root in FileSystem or the operating
+// system.
+func (fp *FileProcessor) walkDir(root string, walkFn fs.WalkDirFunc) error {
+	if fp.FileSystem == nil {
+		return filepath.WalkDir(root, walkFn)
+	}
+
+	name, err := fsName(root)
+	if err != nil {
+		return err
+	}
+
+	return fs.WalkDir(fp.FileSystem, name, walkFn)
+}
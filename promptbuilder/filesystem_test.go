@@ -0,0 +1,186 @@
+package promptbuilder_test
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+// newMapFSProcessor returns a processor reading from an in-memory file system
+// with a 16 byte size limit.
+func newMapFSProcessor() *promptbuilder.FileProcessor {
+	processor := promptbuilder.NewFileProcessor(16, []string{".go", ".txt"})
+	processor.FileSystem = fstest.MapFS{
+		"notes.txt":      {Data: []byte("short note")},
+		"big.txt":        {Data: []byte(strings.Repeat("x", 17))},
+		"pkg/main.go":    {Data: []byte("package main")},
+		"pkg/.hidden.go": {Data: []byte("package main")},
+	}
+
+	return processor
+}
+
+func TestFileProcessor_FileSystemProcessFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr error
+	}{
+		{name: "reads file", path: "notes.txt", want: "short note", wantErr: nil},
+		{name: "cleans relative path", path: "./pkg/../notes.txt", want: "short note", wantErr: nil},
+		{name: "size limit", path: "big.txt", want: "", wantErr: promptbuilder.ErrFileTooLarge},
+		{name: "escapes root", path: "../notes.txt", want: "", wantErr: promptbuilder.ErrSuspiciousPath},
+		{name: "absolute path", path: "/notes.txt", want: "", wantErr: promptbuilder.ErrSuspiciousPath},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			content, err := newMapFSProcessor().ProcessFile(testCase.path)
+			if testCase.wantErr != nil {
+				if !errors.Is(err, testCase.wantErr) {
+					t.Errorf("Expected %v, got %v", testCase.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ProcessFile() unexpected error = %v", err)
+			}
+
+			if string(content.Content) != testCase.want {
+				t.Errorf("Content = %q, want %q", content.Content, testCase.want)
+			}
+		})
+	}
+}
+
+func TestFileProcessor_FileSystemExpandPath(t *testing.T) {
+	t.Parallel()
+
+	processor := newMapFSProcessor()
+
+	files, err := processor.ExpandPath("pkg")
+	if err != nil {
+		t.Fatalf("ExpandPath() unexpected error = %v", err)
+	}
+
+	if !slices.Equal(files, []string{"pkg/main.go"}) {
+		t.Errorf("ExpandPath(pkg) = %v, want [pkg/main.go]", files)
+	}
+
+	files, err = processor.ExpandPath("*.txt")
+	if err != nil {
+		t.Fatalf("ExpandPath() unexpected error = %v", err)
+	}
+
+	if !slices.Equal(files, []string{"big.txt", "notes.txt"}) {
+		t.Errorf("ExpandPath(*.txt) = %v, want [big.txt notes.txt]", files)
+	}
+}
+
+// unreadableFS is a file system in which opening the unreadable file fails
+// with a permission error.
+type unreadableFS struct {
+	fs.FS
+
+	unreadable string
+}
+
+func (f unreadableFS) Open(name string) (fs.File, error) {
+	if name == f.unreadable {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+
+	return f.FS.Open(name)
+}
+
+func TestBuilder_BuildPromptUnreadableFiles(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		policy       promptbuilder.UnreadablePolicy
+		path         string
+		wantFiles    int
+		wantWarnings int
+		wantErr      error
+	}{
+		{name: "default skips with warning", policy: "", path: "src", wantFiles: 2, wantWarnings: 1, wantErr: nil},
+		{name: "skip", policy: promptbuilder.UnreadableSkip, path: "src", wantFiles: 2, wantWarnings: 0, wantErr: nil},
+		{name: "fail", policy: promptbuilder.UnreadableFail, path: "src", wantErr: fs.ErrPermission},
+		{name: "named file fails", policy: "", path: "src/secret.txt", wantErr: fs.ErrPermission},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			processor := promptbuilder.NewFileProcessor(1024, []string{".txt"})
+			processor.FileSystem = unreadableFS{
+				FS: fstest.MapFS{
+					"src/a.txt":      {Data: []byte("first")},
+					"src/secret.txt": {Data: []byte("secret")},
+					"src/z.txt":      {Data: []byte("last")},
+				},
+				unreadable: "src/secret.txt",
+			}
+
+			if testCase.policy != "" {
+				processor.UnreadableFiles = testCase.policy
+			}
+
+			result, err := promptbuilder.New(processor).BuildPrompt(&promptbuilder.BuildRequest{
+				Prompt: "Review",
+				File:   testCase.path,
+			})
+			if testCase.wantErr != nil {
+				if !errors.Is(err, testCase.wantErr) {
+					t.Fatalf("Expected %v, got %v", testCase.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("BuildPrompt() unexpected error = %v", err)
+			}
+
+			if len(result.Files) != testCase.wantFiles {
+				t.Errorf("Expected %d files, got %d", testCase.wantFiles, len(result.Files))
+			}
+
+			if len(result.Warnings) != testCase.wantWarnings {
+				t.Fatalf("Expected %d warnings, got %q", testCase.wantWarnings, result.Warnings)
+			}
+
+			if testCase.wantWarnings > 0 && !strings.Contains(result.Warnings[0], "secret.txt") {
+				t.Errorf("Expected the warning to name secret.txt, got %q", result.Warnings[0])
+			}
+		})
+	}
+}
+
+func TestParseUnreadablePolicy(t *testing.T) {
+	t.Parallel()
+
+	policy, err := promptbuilder.ParseUnreadablePolicy("")
+	if err != nil || policy != promptbuilder.UnreadableSkipWithWarning {
+		t.Errorf("ParseUnreadablePolicy(\"\") = %q, %v, want %q", policy, err, promptbuilder.UnreadableSkipWithWarning)
+	}
+
+	_, err = promptbuilder.ParseUnreadablePolicy("ignore")
+	if !errors.Is(err, promptbuilder.ErrUnknownUnreadablePolicy) {
+		t.Errorf("Expected ErrUnknownUnreadablePolicy, got %v", err)
+	}
+}
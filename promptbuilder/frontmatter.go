@@ -0,0 +1,48 @@
+package promptbuilder
+
+import (
+	"strings"
+)
+
+const frontMatterDelimiter = "---"
+
+// splitFrontMatter separates "---" delimited YAML front matter from the start
+// of a Markdown document. Top-level "key: value" lines are returned as
+// metadata; nested structures and lists are not interpreted. ok is false when
+// the document has no front matter, in which case body is the input.
+func splitFrontMatter(content []byte) (map[string]string, []byte, bool) {
+	text := string(content)
+
+	firstLine, rest, found := strings.Cut(text, "\n")
+	if !found || strings.TrimRight(firstLine, "\r") != frontMatterDelimiter {
+		return nil, content, false
+	}
+
+	metadata := make(map[string]string)
+	offset := len(firstLine) + 1
+
+	for line := range strings.Lines(rest) {
+		offset += len(line)
+		trimmed := strings.TrimRight(line, "\r\n")
+
+		if trimmed == frontMatterDelimiter || trimmed == "..." {
+			body := strings.TrimLeft(text[offset:], "\r\n")
+
+			return metadata, []byte(body), true
+		}
+
+		if strings.HasPrefix(trimmed, " ") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+
+		metadata[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+
+	// An unterminated block is not front matter.
+	return nil, content, false
+}
@@ -0,0 +1,102 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestFileProcessor_StripFrontMatter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		content  string
+		wantBody string
+		wantMeta map[string]string
+	}{
+		{
+			name:     "with front matter",
+			content:  "---\ntitle: \"Release notes\"\ntags:\n  - go\ndraft: false\n---\n\n# Notes\nBody text\n",
+			wantBody: "# Notes\nBody text\n",
+			wantMeta: map[string]string{"title": "Release notes", "tags": "", "draft": "false"},
+		},
+		{
+			name:     "without front matter",
+			content:  "# Notes\n---\nBody text\n",
+			wantBody: "# Notes\n---\nBody text\n",
+			wantMeta: nil,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "notes.md")
+
+			err := os.WriteFile(path, []byte(testCase.content), 0o600)
+			if err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+
+			fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".md"})
+			fileProcessor.StripFrontMatter = true
+
+			content, err := fileProcessor.ProcessFile(path)
+			if err != nil {
+				t.Fatalf("ProcessFile() unexpected error = %v", err)
+			}
+
+			if string(content.Content) != testCase.wantBody {
+				t.Errorf("Expected body %q, got %q", testCase.wantBody, content.Content)
+			}
+
+			if !maps.Equal(content.FrontMatter, testCase.wantMeta) {
+				t.Errorf("Expected front matter %v, got %v", testCase.wantMeta, content.FrontMatter)
+			}
+		})
+	}
+}
+
+func TestRunCLI_FrontMatterInJSON(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "post.md")
+
+	err := os.WriteFile(path, []byte("---\nauthor: Ada\n---\nHello\n"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	var buf bytes.Buffer
+
+	err = promptbuilder.RunCLI([]string{"-p", "Summarize", "--ext", ".md", "-f", path, "--strip-frontmatter", "-o", "json"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	var output struct {
+		FileContent string                      `json:"file_content"`
+		Files       []promptbuilder.FileSummary `json:"files"`
+	}
+
+	err = json.Unmarshal(buf.Bytes(), &output)
+	if err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+
+	if len(output.Files) != 1 || output.Files[0].FrontMatter["author"] != "Ada" {
+		t.Errorf("Expected author front matter in files, got %+v", output.Files)
+	}
+
+	if strings.Contains(output.FileContent, "author:") {
+		t.Errorf("Expected front matter removed from file content, got %q", output.FileContent)
+	}
+}
@@ -0,0 +1,193 @@
+package promptbuilder
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrInvalidFunction is returned for a function reference that is not in
+	// path:name form or does not name a Go file.
+	ErrInvalidFunction = errors.New("function must be given as file.go:Name")
+	// ErrFunctionNotFound is returned when a Go file does not declare a
+	// requested function.
+	ErrFunctionNotFound = errors.New("function not found")
+)
+
+// splitFunctionRef splits a "path:Name" function reference. Methods are named
+// "Type.Method".
+func splitFunctionRef(ref string) (string, string, error) {
+	index := strings.LastIndex(ref, ":")
+	if index <= 0 || index == len(ref)-1 {
+		return "", "", fmt.Errorf("%w: %q", ErrInvalidFunction, ref)
+	}
+
+	path, name := ref[:index], ref[index+1:]
+	if filepath.Ext(path) != ".go" {
+		return "", "", fmt.Errorf("%w: %q is not a Go file", ErrInvalidFunction, path)
+	}
+
+	return path, name, nil
+}
+
+// goFunctions returns the full source, including doc comments, of the named
+// functions in src, in the order requested and separated by blank lines.
+// Methods are named "Type.Method". ErrFunctionNotFound lists any names that
+// src does not declare.
+func goFunctions(filename string, src []byte, names []string) ([]byte, error) {
+	fileSet := token.NewFileSet()
+
+	file, err := parser.ParseFile(fileSet, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Go source: %w", err)
+	}
+
+	declared := make(map[string]*ast.FuncDecl)
+
+	for _, decl := range file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if ok {
+			declared[funcDeclName(funcDecl)] = funcDecl
+		}
+	}
+
+	var (
+		sources []string
+		missing []string
+	)
+
+	for _, name := range names {
+		funcDecl, ok := declared[name]
+		if !ok {
+			missing = append(missing, name)
+
+			continue
+		}
+
+		start := funcDecl.Pos()
+		if funcDecl.Doc != nil {
+			start = funcDecl.Doc.Pos()
+		}
+
+		sources = append(sources, string(src[fileSet.Position(start).Offset:fileSet.Position(funcDecl.End()).Offset]))
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w in %s: %s", ErrFunctionNotFound, filename, strings.Join(missing, ", "))
+	}
+
+	return []byte(strings.Join(sources, "\n\n")), nil
+}
+
+// funcDeclName returns the name of a function, or "Type.Method" for a method.
+func funcDeclName(funcDecl *ast.FuncDecl) string {
+	if funcDecl.Recv == nil || len(funcDecl.Recv.List) == 0 {
+		return funcDecl.Name.Name
+	}
+
+	recvType := funcDecl.Recv.List[0].Type
+
+	if star, ok := recvType.(*ast.StarExpr); ok {
+		recvType = star.X
+	}
+
+	switch typed := recvType.(type) {
+	case *ast.IndexExpr:
+		recvType = typed.X
+	case *ast.IndexListExpr:
+		recvType = typed.X
+	}
+
+	if ident, ok := recvType.(*ast.Ident); ok {
+		return ident.Name + "." + funcDecl.Name.Name
+	}
+
+	return funcDecl.Name.Name
+}
+
+// ProcessFunctions reads the Go file at path and returns only the full source
+// of the named functions, noted in the fence header. The file passes the same
+// validation as in ProcessFile, and the size limit applies to the extracted
+// source.
+func (fp *FileProcessor) ProcessFunctions(path string, names []string) (*FileContent, error) {
+	err := fp.ValidateFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("file validation failed: %w", err)
+	}
+
+	if filepath.Ext(path) != ".go" {
+		return nil, fmt.Errorf("%w: %q is not a Go file", ErrInvalidFunction, path)
+	}
+
+	name, fileInfo, err := fp.statLocalFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := fp.readLocalFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := goFunctions(path, src, names)
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(content)) > fp.maxFileSize {
+		return nil, fmt.Errorf("%w: functions of %s are too large (%d bytes, max %d bytes)",
+			ErrFileTooLarge, path, len(content), fp.maxFileSize)
+	}
+
+	return &FileContent{
+		Path:         path,
+		Content:      content,
+		Size:         fileInfo.Size(),
+		ModTime:      fileInfo.ModTime(),
+		LastCommit:   "",
+		Note:         "only " + strings.Join(names, ", "),
+		FrontMatter:  nil,
+		sourceDigest: sha256.Sum256(src),
+		warnings:     nil,
+	}, nil
+}
+
+// processFunctionRefs groups "path:Name" references by file, in the order the
+// files first appear, and returns the extracted functions of each file.
+func (fp *FileProcessor) processFunctionRefs(refs []string) ([]*FileContent, error) {
+	var paths []string
+
+	names := make(map[string][]string)
+
+	for _, ref := range refs {
+		path, name, err := splitFunctionRef(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, seen := names[path]; !seen {
+			paths = append(paths, path)
+		}
+
+		names[path] = append(names[path], name)
+	}
+
+	contents := make([]*FileContent, 0, len(paths))
+
+	for _, path := range paths {
+		content, err := fp.ProcessFunctions(path, names[path])
+		if err != nil {
+			return nil, err
+		}
+
+		contents = append(contents, content)
+	}
+
+	return contents, nil
+}
@@ -0,0 +1,99 @@
+package promptbuilder_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+const functionsSource = `package calc
+
+// Add returns the sum of a and b.
+func Add(a, b int) int {
+	return a + b
+}
+
+func Sub(a, b int) int { return a - b }
+
+type Pair struct{ Left, Right int }
+
+// Sum adds the pair.
+func (p *Pair) Sum() int { return Add(p.Left, p.Right) }
+`
+
+func TestFileProcessor_ProcessFunctions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		names   []string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "single function",
+			names: []string{"Add"},
+			want:  "// Add returns the sum of a and b.\nfunc Add(a, b int) int {\n\treturn a + b\n}",
+		},
+		{
+			name:  "method and function in requested order",
+			names: []string{"Pair.Sum", "Sub"},
+			want: "// Sum adds the pair.\nfunc (p *Pair) Sum() int { return Add(p.Left, p.Right) }\n\n" +
+				"func Sub(a, b int) int { return a - b }",
+		},
+		{name: "missing function", names: []string{"Add", "Mul"}, wantErr: promptbuilder.ErrFunctionNotFound},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			processor := promptbuilder.NewFileProcessor(1024, []string{".go"})
+			processor.FileSystem = fstest.MapFS{"calc.go": {Data: []byte(functionsSource)}}
+
+			content, err := processor.ProcessFunctions("calc.go", testCase.names)
+			if testCase.wantErr != nil {
+				if !errors.Is(err, testCase.wantErr) || !strings.Contains(err.Error(), "Mul") {
+					t.Errorf("Expected %v naming Mul, got %v", testCase.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ProcessFunctions() unexpected error = %v", err)
+			}
+
+			if string(content.Content) != testCase.want {
+				t.Errorf("Content = %q, want %q", content.Content, testCase.want)
+			}
+		})
+	}
+}
+
+func TestBuildPrompt_Functions(t *testing.T) {
+	t.Parallel()
+
+	processor := promptbuilder.NewFileProcessor(1024, []string{".go"})
+	processor.FileSystem = fstest.MapFS{"calc.go": {Data: []byte(functionsSource)}}
+
+	result, err := promptbuilder.New(processor).BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:    "Review Add",
+		Functions: []string{"calc.go:Add"},
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	fileContent := result.Prompt.FileContent
+	if !strings.HasPrefix(fileContent, "BEGIN calc.go — only Add\n```go\n// Add returns") {
+		t.Errorf("Expected fenced Add function, got %q", fileContent)
+	}
+
+	if strings.Contains(fileContent, "Sub") || strings.Contains(fileContent, "package calc") {
+		t.Errorf("Expected only the Add function, got %q", fileContent)
+	}
+}
@@ -0,0 +1,33 @@
+package promptbuilder
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// lastCommit describes the most recent commit touching path, formatted as
+// "<hash> <author> <date>". It returns an empty string when git is unavailable
+// or the file is not tracked in a repository.
+func lastCommit(path string) string {
+	if isURL(path) {
+		return ""
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+
+	// #nosec G204 -- Arguments are passed directly to git without a shell and
+	// the path has already passed the file processor's security validation.
+	cmd := exec.Command("git", "-C", filepath.Dir(absPath),
+		"log", "-1", "--format=%h %an %ad", "--date=short", "--", filepath.Base(absPath))
+
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,90 @@
+package promptbuilder_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+// runGit runs a git command in dir, failing the test on error.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, output)
+	}
+
+	return strings.TrimSpace(string(output))
+}
+
+func TestBuildPrompt_WithGitInfo(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := t.TempDir()
+	path := filepath.Join(repo, "notes.txt")
+
+	err := os.WriteFile(path, []byte("release notes"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	runGit(t, repo, "init", "--quiet")
+	runGit(t, repo, "add", "notes.txt")
+	runGit(t, repo, "-c", "user.name=Test Author", "-c", "user.email=author@example.com",
+		"commit", "--quiet", "-m", "Add notes")
+
+	hash := runGit(t, repo, "log", "-1", "--format=%h")
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".txt"})
+	fileProcessor.IncludeGitInfo = true
+
+	result, err := promptbuilder.New(fileProcessor).BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt: "Summarize",
+		File:   path,
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	want := "Last commit: " + hash + " Test Author "
+	if !strings.Contains(result.Prompt.FileContent, want) {
+		t.Errorf("Expected %q in file content, got %q", want, result.Prompt.FileContent)
+	}
+}
+
+func TestBuildPrompt_WithGitInfoOutsideRepo(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "notes.txt")
+
+	err := os.WriteFile(path, []byte("release notes"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024, []string{".txt"})
+	fileProcessor.IncludeGitInfo = true
+
+	result, err := promptbuilder.New(fileProcessor).BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt: "Summarize",
+		File:   path,
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if strings.Contains(result.Prompt.FileContent, "Last commit:") {
+		t.Errorf("Expected no commit line outside a repository, got %q", result.Prompt.FileContent)
+	}
+}
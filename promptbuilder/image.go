@@ -0,0 +1,146 @@
+package promptbuilder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF for ValidateImageData
+	_ "image/jpeg" // register JPEG for ValidateImageData
+	_ "image/png"  // register PNG for ValidateImageData
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidImageData is returned when image bytes are not a recognized image.
+var ErrInvalidImageData = errors.New("image data is not a recognized image format")
+
+// imageExtensions maps sniffed image MIME types to file extensions.
+var imageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/bmp":  ".bmp",
+}
+
+// DetectImageMIMEType sniffs the MIME type of image data from its leading
+// bytes. Unrecognized data is reported as image/png, which matches the
+// historical default of the builder.
+func DetectImageMIMEType(data []byte) string {
+	mimeType := http.DetectContentType(data)
+	if _, ok := imageExtensions[mimeType]; ok {
+		return mimeType
+	}
+
+	if strings.HasPrefix(mimeType, "image/") {
+		return mimeType
+	}
+
+	return "image/png"
+}
+
+// ValidateImageData checks that data holds a recognized image. PNG, JPEG, and
+// GIF headers are decoded; WebP and BMP, which the standard library cannot
+// decode, are accepted on their file signature.
+func ValidateImageData(data []byte) error {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == nil {
+		return nil
+	}
+
+	switch http.DetectContentType(data) {
+	case "image/webp", "image/bmp":
+		return nil
+	}
+
+	if format == "" {
+		return fmt.Errorf("%w: %w", ErrInvalidImageData, err)
+	}
+
+	return fmt.Errorf("%w: invalid %s header: %w", ErrInvalidImageData, format, err)
+}
+
+// EncodeImageDataURI returns data as a complete data URI, such as
+// "data:image/png;base64,...", with the MIME type sniffed from its leading
+// bytes. It returns ErrInvalidImageData when data is not a recognized image.
+func EncodeImageDataURI(data []byte) (string, error) {
+	err := ValidateImageData(data)
+	if err != nil {
+		return "", err
+	}
+
+	return imageDataURI(data), nil
+}
+
+// imageDataURI encodes data as a base64 data URI without validating it.
+func imageDataURI(data []byte) string {
+	return "data:" + DetectImageMIMEType(data) + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// checkImageSize returns ErrImageTooLarge, naming the image as name, when
+// size is over maxBytes. A maxBytes of zero or less disables the check.
+func checkImageSize(name string, size int64, maxBytes int) error {
+	if maxBytes > 0 && size > int64(maxBytes) {
+		return fmt.Errorf("%w: %s is %d bytes, max %d bytes", ErrImageTooLarge, name, size, maxBytes)
+	}
+
+	return nil
+}
+
+// validateImagePath checks that an image referenced by path is a file with an
+// image extension in an allowed location, without reading it.
+func (fp *FileProcessor) validateImagePath(path string) error {
+	if !isImageFile(path) {
+		return fmt.Errorf("%w: %s is not an image", ErrFileExtensionNotAllowed, path)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("invalid image path %s: %w", path, err)
+	}
+
+	err = fp.validatePathSecurity(absPath)
+	if err != nil {
+		return fmt.Errorf("security validation failed for %s: %w", absPath, err)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat image %s: %w", path, err)
+	}
+
+	if info.IsDir() {
+		return fmt.Errorf("%w: %s", ErrPathIsDirectory, path)
+	}
+
+	return nil
+}
+
+// isImageFile reports whether path has the extension of a known image format.
+func isImageFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".jpeg" {
+		return true
+	}
+
+	for _, imageExt := range imageExtensions {
+		if ext == imageExt {
+			return true
+		}
+	}
+
+	return false
+}
+
+// imageFilename returns a placeholder filename matching the image MIME type.
+func imageFilename(mimeType string) string {
+	if ext, ok := imageExtensions[mimeType]; ok {
+		return "image" + ext
+	}
+
+	return "image"
+}
@@ -0,0 +1,232 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestValidateImageData(t *testing.T) {
+	t.Parallel()
+
+	pngData, err := base64.StdEncoding.DecodeString(sampleImageB64Part1 + sampleImageB64Part2)
+	if err != nil {
+		t.Fatalf("Failed to decode sample image: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "valid PNG", data: pngData, wantErr: false},
+		{name: "random bytes", data: []byte{0x3f, 0x91, 0x07, 0xd2, 0x5e, 0xaa, 0x10, 0xc4}, wantErr: true},
+		{name: "truncated PNG", data: pngData[:12], wantErr: true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := promptbuilder.ValidateImageData(testCase.data)
+			if testCase.wantErr && !errors.Is(err, promptbuilder.ErrInvalidImageData) {
+				t.Errorf("Expected ErrInvalidImageData, got %v", err)
+			}
+
+			if !testCase.wantErr && err != nil {
+				t.Errorf("ValidateImageData() unexpected error = %v", err)
+			}
+		})
+	}
+}
+
+func TestEncodeImageDataURI(t *testing.T) {
+	t.Parallel()
+
+	pngData, err := base64.StdEncoding.DecodeString(sampleImageB64Part1 + sampleImageB64Part2)
+	if err != nil {
+		t.Fatalf("Failed to decode sample image: %v", err)
+	}
+
+	uri, err := promptbuilder.EncodeImageDataURI(pngData)
+	if err != nil {
+		t.Fatalf("EncodeImageDataURI() unexpected error = %v", err)
+	}
+
+	want := "data:image/png;base64," + sampleImageB64Part1 + sampleImageB64Part2
+	if uri != want {
+		t.Errorf("EncodeImageDataURI() = %q, want %q", uri, want)
+	}
+
+	_, err = promptbuilder.EncodeImageDataURI([]byte("definitely not an image"))
+	if !errors.Is(err, promptbuilder.ErrInvalidImageData) {
+		t.Errorf("Expected ErrInvalidImageData, got %v", err)
+	}
+}
+
+func TestBuilder_BuildPromptValidateImages(t *testing.T) {
+	t.Parallel()
+
+	garbage := []byte("definitely not an image")
+
+	builder := newTestBuilder()
+
+	_, err := builder.BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Describe", Image: garbage})
+	if err != nil {
+		t.Fatalf("Expected unvalidated image to be accepted, got %v", err)
+	}
+
+	builder.ValidateImages = true
+
+	_, err = builder.BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Describe", Image: garbage})
+	if !errors.Is(err, promptbuilder.ErrInvalidImageData) {
+		t.Errorf("Expected ErrInvalidImageData, got %v", err)
+	}
+}
+
+func TestRunCLI_ImageByRef(t *testing.T) {
+	t.Parallel()
+
+	pngData, err := base64.StdEncoding.DecodeString(sampleImageB64Part1 + sampleImageB64Part2)
+	if err != nil {
+		t.Fatalf("Failed to decode sample image: %v", err)
+	}
+
+	imagePath := filepath.Join(t.TempDir(), "diagram.png")
+
+	err = os.WriteFile(imagePath, pngData, 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+
+	flags := promptbuilder.CLIFlags{Prompt: "Describe", ImageByRef: imagePath}
+
+	req, err := flags.ToBuildRequest()
+	if err != nil {
+		t.Fatalf("ToBuildRequest() unexpected error = %v", err)
+	}
+
+	result, err := newTestBuilder().BuildPrompt(req)
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if result.Prompt.ImagePath != imagePath {
+		t.Errorf("Expected ImagePath %s, got %q", imagePath, result.Prompt.ImagePath)
+	}
+
+	var buf bytes.Buffer
+
+	err = promptbuilder.RunCLI([]string{"-p", "Describe", "--image-by-ref", imagePath, "-o", "json"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	output := buf.String()
+	if strings.Contains(output, "base64") || strings.Contains(output, sampleImageB64Part1) {
+		t.Errorf("Expected no inline base64 in output, got %q", output)
+	}
+
+	if !strings.Contains(output, `"image_path": "`+imagePath+`"`) {
+		t.Errorf("Expected image path in output, got %q", output)
+	}
+}
+
+func TestBuilder_BuildPromptImageByRefRejectsConflicts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	imagePath := filepath.Join(dir, "diagram.png")
+	textPath := filepath.Join(dir, "notes.txt")
+
+	for _, path := range []string{imagePath, textPath} {
+		err := os.WriteFile(path, []byte("content"), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		req     *promptbuilder.BuildRequest
+		wantErr error
+	}{
+		{
+			name:    "with a file",
+			req:     &promptbuilder.BuildRequest{Prompt: "Describe", ImagePath: imagePath, File: textPath},
+			wantErr: promptbuilder.ErrImageRefConflict,
+		},
+		{
+			name:    "with image data",
+			req:     &promptbuilder.BuildRequest{Prompt: "Describe", ImagePath: imagePath, Image: []byte("data")},
+			wantErr: promptbuilder.ErrImageRefConflict,
+		},
+		{
+			name:    "not an image",
+			req:     &promptbuilder.BuildRequest{Prompt: "Describe", ImagePath: textPath},
+			wantErr: promptbuilder.ErrFileExtensionNotAllowed,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newTestBuilder().BuildPrompt(testCase.req)
+			if !errors.Is(err, testCase.wantErr) {
+				t.Errorf("BuildPrompt() error = %v, want %v", err, testCase.wantErr)
+			}
+		})
+	}
+
+	_, err := promptbuilder.ParseFlags([]string{"-p", "Describe", "--image-by-ref", imagePath, "--image-hex", "00"})
+	if !errors.Is(err, promptbuilder.ErrImageRefConflict) {
+		t.Errorf("Expected ParseFlags to return ErrImageRefConflict, got %v", err)
+	}
+}
+
+func TestBuilder_BuildPromptImagesAsBase64InDirectory(t *testing.T) {
+	t.Parallel()
+
+	pngData, err := base64.StdEncoding.DecodeString(sampleImageB64Part1 + sampleImageB64Part2)
+	if err != nil {
+		t.Fatalf("Failed to decode sample image: %v", err)
+	}
+
+	dir := t.TempDir()
+	imagePath := filepath.Join(dir, "chart.png")
+
+	err = os.WriteFile(imagePath, pngData, 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("quarterly chart"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write notes: %v", err)
+	}
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024*1024, []string{".png", ".txt"})
+	fileProcessor.ImagesAsBase64 = true
+
+	result, err := promptbuilder.New(fileProcessor).BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Describe", File: dir})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	wantImage := "BEGIN " + imagePath + "\ndata:image/png;base64," + sampleImageB64Part1 + sampleImageB64Part2 +
+		"\nEND " + imagePath
+	if !strings.Contains(result.Prompt.FileContent, wantImage) {
+		t.Errorf("Expected the PNG embedded as a data URI, got %q", result.Prompt.FileContent)
+	}
+
+	if !strings.Contains(result.Prompt.FileContent, "quarterly chart") {
+		t.Errorf("Expected text files to be included as text, got %q", result.Prompt.FileContent)
+	}
+}
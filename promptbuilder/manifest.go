@@ -0,0 +1,87 @@
+package promptbuilder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// TableOfContents returns a numbered list of files with their line counts,
+// such as "1. main.go (42 lines)", for navigating multi-file prompts.
+func TableOfContents(files []*FileContent) string {
+	return tableOfContents(files, func(path string) string { return path })
+}
+
+// tableOfContents implements TableOfContents, showing each path as
+// displayPath returns it.
+func tableOfContents(files []*FileContent, displayPath func(string) string) string {
+	lines := make([]string, 0, len(files))
+
+	for index, file := range files {
+		count := countLines(file.Content)
+
+		unit := "lines"
+		if count == 1 {
+			unit = "line"
+		}
+
+		lines = append(lines, fmt.Sprintf("%d. %s (%d %s)", index+1, displayPath(file.Path), count, unit))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ManifestEntry records the digest and size of one included file.
+type ManifestEntry struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+// Manifest lists included files with their SHA-256 digests so that a prompt
+// can be audited against the files it was built from.
+type Manifest struct {
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// Add records content under path.
+func (m *Manifest) Add(path string, content []byte) {
+	digest := sha256.Sum256(content)
+
+	m.Entries = append(m.Entries, ManifestEntry{
+		Path:   path,
+		SHA256: hex.EncodeToString(digest[:]),
+		Size:   len(content),
+	})
+}
+
+// addFile records the file under path with the digest and size of its bytes
+// on disk, so the manifest can be checked against the files themselves even
+// when their content was converted or reduced. Files not read from disk are
+// recorded by their content.
+func (m *Manifest) addFile(path string, file *FileContent) {
+	if file.sourceDigest == [sha256.Size]byte{} {
+		m.Add(path, file.Content)
+
+		return
+	}
+
+	m.Entries = append(m.Entries, ManifestEntry{
+		Path:   path,
+		SHA256: hex.EncodeToString(file.sourceDigest[:]),
+		Size:   int(file.Size),
+	})
+}
+
+// String renders one line per entry in the style of sha256sum, followed by
+// the size in bytes.
+func (m *Manifest) String() string {
+	lines := make([]string, 0, len(m.Entries))
+
+	for _, entry := range m.Entries {
+		lines = append(lines, fmt.Sprintf("%s  %s (%d bytes)", entry.SHA256, entry.Path, entry.Size))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,168 @@
+package promptbuilder_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestBuilder_BuildPromptManifest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	contents := map[string]string{
+		filepath.Join(dir, "one.txt"): "first file",
+		filepath.Join(dir, "two.txt"): "second file, a little longer",
+	}
+
+	paths := make([]string, 0, len(contents))
+
+	for path, content := range contents {
+		err := os.WriteFile(path, []byte(content), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	result, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:   "Review",
+		Files:    paths,
+		Manifest: true,
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	output := result.Prompt.String()
+
+	manifestStart := strings.Index(output, "Manifest:")
+	if manifestStart < 0 || manifestStart < strings.Index(output, "Review") {
+		t.Fatalf("Expected manifest section after the user prompt, got %q", output)
+	}
+
+	for path, content := range contents {
+		digest := sha256.Sum256([]byte(content))
+		want := hex.EncodeToString(digest[:]) + "  " + path
+
+		if !strings.Contains(output[manifestStart:], want) {
+			t.Errorf("Expected %q in manifest, got %q", want, output[manifestStart:])
+		}
+	}
+}
+
+func TestBuilder_BuildPromptManifestHashesFileOnDisk(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "notes.md")
+	original := "---\ntitle: Notes\n---\nBody text\n"
+
+	err := os.WriteFile(path, []byte(original), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+
+	processor := promptbuilder.NewFileProcessor(1024*1024, []string{".md"})
+	processor.StripFrontMatter = true
+
+	result, err := promptbuilder.New(processor).BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:   "Review",
+		File:     path,
+		Manifest: true,
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	digest := sha256.Sum256([]byte(original))
+
+	want := fmt.Sprintf("%s  %s (%d bytes)", hex.EncodeToString(digest[:]), path, len(original))
+	if result.Prompt.Manifest != want {
+		t.Errorf("Expected the manifest to describe the file on disk, %q, got %q", want, result.Prompt.Manifest)
+	}
+}
+
+func TestManifest_String(t *testing.T) {
+	t.Parallel()
+
+	var manifest promptbuilder.Manifest
+
+	manifest.Add("a.txt", []byte("abc"))
+
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  a.txt (3 bytes)"
+	if manifest.String() != want {
+		t.Errorf("Expected %q, got %q", want, manifest.String())
+	}
+}
+
+func TestTableOfContents(t *testing.T) {
+	t.Parallel()
+
+	files := []*promptbuilder.FileContent{
+		{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+		{Path: "README.md", Content: []byte("# Title")},
+		{Path: "empty.txt", Content: nil},
+	}
+
+	want := "1. main.go (3 lines)\n2. README.md (1 line)\n3. empty.txt (0 lines)"
+
+	got := promptbuilder.TableOfContents(files)
+	if got != want {
+		t.Errorf("TableOfContents() = %q, want %q", got, want)
+	}
+}
+
+func TestBuilder_BuildPromptTableOfContents(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	first := filepath.Join(dir, "one.txt")
+	second := filepath.Join(dir, "two.txt")
+
+	for path, content := range map[string]string{first: "a\nb\n", second: "c\nd\ne"} {
+		err := os.WriteFile(path, []byte(content), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	result, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:          "Review",
+		Files:           []string{first, second},
+		TableOfContents: true,
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	want := "1. " + first + " (2 lines)\n2. " + second + " (3 lines)"
+	if result.Prompt.TableOfContents != want {
+		t.Errorf("TableOfContents = %q, want %q", result.Prompt.TableOfContents, want)
+	}
+
+	output := result.Prompt.String()
+	contentsStart := strings.Index(output, "Contents:")
+	if contentsStart < 0 || contentsStart > strings.Index(output, "File content:") {
+		t.Errorf("Expected the table of contents before the file content, got %q", output)
+	}
+
+	single, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:          "Review",
+		Files:           []string{first},
+		TableOfContents: true,
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if single.Prompt.TableOfContents != "" {
+		t.Errorf("Expected no table of contents for a single file, got %q", single.Prompt.TableOfContents)
+	}
+}
@@ -0,0 +1,35 @@
+package promptbuilder
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeText applies Unicode NFC normalization and removes zero-width and
+// control characters that often sneak into copy-pasted text. Newlines and tabs
+// are preserved.
+func NormalizeText(text string) string {
+	return strings.Map(func(char rune) rune {
+		if char == '\n' || char == '\t' {
+			return char
+		}
+
+		if unicode.IsControl(char) || isZeroWidth(char) {
+			return -1
+		}
+
+		return char
+	}, norm.NFC.String(text))
+}
+
+// isZeroWidth reports whether char is an invisible zero-width character.
+func isZeroWidth(char rune) bool {
+	switch char {
+	case '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff':
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,55 @@
+package promptbuilder_test
+
+import (
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestNormalizeText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "zero-width space", input: "foo\u200bbar", want: "foobar"},
+		{name: "byte order mark", input: "\ufeffhello", want: "hello"},
+		{name: "combining characters", input: "cafe\u0301", want: "caf\u00e9"},
+		{name: "control characters", input: "a\x00b\x1bc", want: "abc"},
+		{name: "newlines and tabs kept", input: "line one\n\tline two", want: "line one\n\tline two"},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := promptbuilder.NormalizeText(testCase.input)
+			if got != testCase.want {
+				t.Errorf("NormalizeText(%q) = %q, want %q", testCase.input, got, testCase.want)
+			}
+		})
+	}
+}
+
+func TestBuildPrompt_Normalize(t *testing.T) {
+	t.Parallel()
+
+	result, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:     "Explain\u200b the cafe\u0301 menu",
+		Guidelines: "Be\u200d brief",
+		Normalize:  true,
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if result.Prompt.UserPrompt != "Explain the caf\u00e9 menu" {
+		t.Errorf("Expected normalized prompt, got %q", result.Prompt.UserPrompt)
+	}
+
+	if result.Prompt.Guidelines != "Be brief" {
+		t.Errorf("Expected normalized guidelines, got %q", result.Prompt.Guidelines)
+	}
+}
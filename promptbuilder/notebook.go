@@ -0,0 +1,94 @@
+package promptbuilder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// defaultNotebookLanguage fences code cells when a notebook does not name its
+// language.
+const defaultNotebookLanguage = "python"
+
+// ErrNotebookUnreadable is returned when a .ipynb file is not a valid notebook.
+var ErrNotebookUnreadable = errors.New("notebook could not be read")
+
+// notebook holds the parts of a Jupyter notebook that are included in prompts.
+type notebook struct {
+	Cells    []notebookCell `json:"cells"`
+	Metadata struct {
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+}
+
+// notebookCell is one notebook cell. Outputs are not decoded.
+type notebookCell struct {
+	CellType string         `json:"cell_type"`
+	Source   notebookSource `json:"source"`
+}
+
+// notebookSource is cell source, stored either as one string or as a list of
+// lines.
+type notebookSource string
+
+// UnmarshalJSON accepts both source representations.
+func (s *notebookSource) UnmarshalJSON(data []byte) error {
+	var lines []string
+
+	err := json.Unmarshal(data, &lines)
+	if err == nil {
+		*s = notebookSource(strings.Join(lines, ""))
+
+		return nil
+	}
+
+	var text string
+
+	err = json.Unmarshal(data, &text)
+	if err != nil {
+		return fmt.Errorf("cell source must be a string or list of strings: %w", err)
+	}
+
+	*s = notebookSource(text)
+
+	return nil
+}
+
+// extractNotebookText renders a notebook's cells in order: code cells fenced
+// in the notebook's language (python unless the metadata says otherwise) and
+// markdown cells as plain text. Outputs and raw cells are skipped.
+func extractNotebookText(content []byte) ([]byte, error) {
+	var book notebook
+
+	err := json.Unmarshal(content, &book)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotebookUnreadable, err)
+	}
+
+	language := book.Metadata.LanguageInfo.Name
+	if language == "" {
+		language = defaultNotebookLanguage
+	}
+
+	blocks := make([]string, 0, len(book.Cells))
+
+	for _, cell := range book.Cells {
+		source := strings.TrimRight(string(cell.Source), "\n")
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+
+		switch cell.CellType {
+		case "code":
+			fence := markdownFence(source)
+			blocks = append(blocks, fence+language+"\n"+source+"\n"+fence)
+		case "markdown":
+			blocks = append(blocks, source)
+		}
+	}
+
+	return []byte(strings.Join(blocks, "\n\n") + "\n"), nil
+}
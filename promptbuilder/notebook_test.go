@@ -0,0 +1,71 @@
+package promptbuilder_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+const testNotebook = `{
+ "cells": [
+  {"cell_type": "markdown", "metadata": {}, "source": ["# Analysis\n", "Load the data."]},
+  {"cell_type": "code", "execution_count": 1, "metadata": {},
+   "outputs": [{"output_type": "stream", "name": "stdout", "text": ["SECRET OUTPUT\n"]}],
+   "source": ["import pandas as pd\n", "df = pd.read_csv(\"data.csv\")"]},
+  {"cell_type": "code", "execution_count": null, "metadata": {}, "outputs": [], "source": "print(df.head())"}
+ ],
+ "metadata": {"kernelspec": {"name": "python3"}},
+ "nbformat": 4,
+ "nbformat_minor": 5
+}`
+
+func TestFileProcessor_ProcessFile_Notebook(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "analysis.ipynb")
+
+	err := os.WriteFile(path, []byte(testNotebook), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write notebook: %v", err)
+	}
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024*1024, []string{".ipynb"})
+
+	content, err := fileProcessor.ProcessFile(path)
+	if err != nil {
+		t.Fatalf("ProcessFile() unexpected error = %v", err)
+	}
+
+	want := "# Analysis\nLoad the data.\n\n" +
+		"```python\nimport pandas as pd\ndf = pd.read_csv(\"data.csv\")\n```\n\n" +
+		"```python\nprint(df.head())\n```\n"
+	if got := string(content.Content); got != want {
+		t.Errorf("Unexpected notebook text:\ngot:  %q\nwant: %q", got, want)
+	}
+
+	if strings.Contains(string(content.Content), "SECRET OUTPUT") {
+		t.Error("Expected cell outputs to be skipped")
+	}
+}
+
+func TestFileProcessor_ProcessFile_InvalidNotebook(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "broken.ipynb")
+
+	err := os.WriteFile(path, []byte("not json"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write notebook: %v", err)
+	}
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024*1024, []string{".ipynb"})
+
+	_, err = fileProcessor.ProcessFile(path)
+	if !errors.Is(err, promptbuilder.ErrNotebookUnreadable) {
+		t.Errorf("Expected ErrNotebookUnreadable, got %v", err)
+	}
+}
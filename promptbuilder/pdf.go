@@ -0,0 +1,49 @@
+package promptbuilder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Errors returned when PDF text cannot be extracted.
+var (
+	ErrPDFEncrypted  = errors.New("pdf is encrypted")
+	ErrPDFUnreadable = errors.New("pdf could not be read")
+)
+
+// extractPDFText returns the plain text of every page in a PDF document. The
+// PDF library panics on some malformed input, so panics are converted into
+// ErrPDFUnreadable.
+func extractPDFText(content []byte) (text []byte, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			text = nil
+			err = fmt.Errorf("%w: %v", ErrPDFUnreadable, recovered)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		if errors.Is(err, pdf.ErrInvalidPassword) {
+			return nil, fmt.Errorf("%w: %w", ErrPDFEncrypted, err)
+		}
+
+		return nil, fmt.Errorf("%w: %w", ErrPDFUnreadable, err)
+	}
+
+	plainText, err := reader.GetPlainText()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPDFUnreadable, err)
+	}
+
+	text, err = io.ReadAll(plainText)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPDFUnreadable, err)
+	}
+
+	return text, nil
+}
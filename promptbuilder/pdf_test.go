@@ -0,0 +1,94 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+// buildTestPDF generates a minimal single-page PDF that shows text.
+func buildTestPDF(text string) []byte {
+	stream := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] " +
+			"/Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString("%PDF-1.4\n")
+
+	offsets := make([]int, 0, len(objects))
+
+	for index, object := range objects {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", index+1, object)
+	}
+
+	xrefOffset := buf.Len()
+
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xrefOffset)
+
+	return buf.Bytes()
+}
+
+func TestFileProcessor_ProcessFile_PDF(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "report.pdf")
+
+	err := os.WriteFile(path, buildTestPDF("Quarterly results are strong"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024*1024, []string{".pdf"})
+
+	content, err := fileProcessor.ProcessFile(path)
+	if err != nil {
+		t.Fatalf("ProcessFile() unexpected error = %v", err)
+	}
+
+	if !strings.Contains(string(content.Content), "Quarterly results are strong") {
+		t.Errorf("Expected extracted text, got %q", content.Content)
+	}
+
+	fenced := fileProcessor.FenceContent(content.Content, content.Path)
+	if strings.Contains(fenced, "```") {
+		t.Errorf("Expected PDF text to be fenced as plain text, got %q", fenced)
+	}
+}
+
+func TestFileProcessor_ProcessFile_UnreadablePDF(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "broken.pdf")
+
+	err := os.WriteFile(path, []byte("this is not a pdf document at all"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024*1024, []string{".pdf"})
+
+	_, err = fileProcessor.ProcessFile(path)
+	if !errors.Is(err, promptbuilder.ErrPDFUnreadable) {
+		t.Errorf("Expected ErrPDFUnreadable, got %v", err)
+	}
+}
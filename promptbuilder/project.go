@@ -0,0 +1,110 @@
+package promptbuilder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFileName is the name of the project file the CLI discovers by
+// walking up from the working directory.
+const ProjectFileName = ".promptbuilder.yaml"
+
+// ErrInvalidProjectFile is returned when a project file cannot be parsed.
+var ErrInvalidProjectFile = errors.New("invalid project file")
+
+// ProjectConfig holds the settings of a project file, written in YAML:
+//
+//	extensions: [.go, .md]
+//	task: review
+//	output: json
+//	presets:
+//	  review: You are a careful code reviewer.
+//
+// Extensions may be written without the leading dot.
+type ProjectConfig struct {
+	// Extensions replaces the default allowed file extensions.
+	Extensions []string `json:"extensions,omitempty" yaml:"extensions"`
+	// Presets adds system presets, replacing built-in ones of the same name.
+	Presets map[string]string `json:"presets,omitempty" yaml:"presets"`
+	// Task and Output are the defaults of the -t and -o flags.
+	Task   string `json:"task,omitempty" yaml:"task"`
+	Output string `json:"output,omitempty" yaml:"output"`
+}
+
+// FindProjectFile returns the path of the nearest ProjectFileName in dir or
+// one of its parents. ok is false when there is none.
+func FindProjectFile(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+
+	for {
+		path := filepath.Join(dir, ProjectFileName)
+
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			return path, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+
+		dir = parent
+	}
+}
+
+// LoadProjectConfig reads and parses the project file at path.
+func LoadProjectConfig(path string) (*ProjectConfig, error) {
+	// #nosec G304 -- The project file is found by name above the working
+	// directory, like other per-project configuration files.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project file: %w", err)
+	}
+
+	config, err := parseProjectConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// parseProjectConfig decodes the YAML of a project file. Unknown keys are
+// rejected so that a misspelled setting is not silently ignored.
+func parseProjectConfig(data []byte) (*ProjectConfig, error) {
+	config := &ProjectConfig{Extensions: nil, Presets: nil, Task: "", Output: ""}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	err := decoder.Decode(config)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProjectFile, err)
+	}
+
+	for index, ext := range config.Extensions {
+		config.Extensions[index] = normalizeExtension(ext)
+	}
+
+	return config, nil
+}
+
+// normalizeExtension adds the leading dot to an extension written without it.
+func normalizeExtension(ext string) string {
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		return "." + ext
+	}
+
+	return ext
+}
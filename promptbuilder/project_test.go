@@ -0,0 +1,205 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+const projectFile = `# Settings for prompts built in this project
+extensions:
+  - .log
+  - txt
+task: review
+output: json # machine readable
+presets:
+  review: "You are a careful reviewer. # not a comment"
+  triage: 'Sort issues by severity, it''s urgent'
+`
+
+func TestLoadProjectConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    *promptbuilder.ProjectConfig
+		wantErr bool
+	}{
+		{
+			name:    "block list and quoted presets",
+			content: projectFile,
+			want: &promptbuilder.ProjectConfig{
+				Extensions: []string{".log", ".txt"},
+				Presets: map[string]string{
+					"review": "You are a careful reviewer. # not a comment",
+					"triage": "Sort issues by severity, it's urgent",
+				},
+				Task:   "review",
+				Output: "json",
+			},
+		},
+		{
+			name:    "inline list",
+			content: "extensions: [.go, md]\n",
+			want:    &promptbuilder.ProjectConfig{Extensions: []string{".go", ".md"}},
+		},
+		{
+			name: "block scalars",
+			content: "presets:\n  review: |\n    Be careful.\n\n    # Check the tests.\n" +
+				"  triage: >-\n    Sort issues\n    by severity.\ntask: review\n",
+			want: &promptbuilder.ProjectConfig{
+				Presets: map[string]string{
+					"review": "Be careful.\n\n# Check the tests.\n",
+					"triage": "Sort issues by severity.",
+				},
+				Task: "review",
+			},
+		},
+		{
+			name:    "empty map and list",
+			content: "presets: {}\nextensions: []\n",
+			want:    &promptbuilder.ProjectConfig{},
+		},
+		{
+			name:    "apostrophe before a comment",
+			content: "task: it's-review # the default\n",
+			want:    &promptbuilder.ProjectConfig{Task: "it's-review"},
+		},
+		{
+			name:    "anchors and multi-line scalars",
+			content: "task: &task review\npresets:\n  review: \"Be\n    careful.\"\n  \"the default\": *task\n",
+			want: &promptbuilder.ProjectConfig{
+				Presets: map[string]string{"review": "Be careful.", "the default": "review"},
+				Task:    "review",
+			},
+		},
+		{name: "empty file", content: "# nothing set\n", want: &promptbuilder.ProjectConfig{}},
+		{name: "unknown key", content: "model: gpt\n", wantErr: true},
+		{name: "list as a string", content: "extensions: .go\n", wantErr: true},
+		{name: "malformed", content: "task: [review\n", wantErr: true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), promptbuilder.ProjectFileName)
+
+			err := os.WriteFile(path, []byte(testCase.content), 0o600)
+			if err != nil {
+				t.Fatalf("Failed to write project file: %v", err)
+			}
+
+			config, err := promptbuilder.LoadProjectConfig(path)
+			if testCase.wantErr {
+				if !errors.Is(err, promptbuilder.ErrInvalidProjectFile) {
+					t.Errorf("Expected ErrInvalidProjectFile, got %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("LoadProjectConfig() unexpected error = %v", err)
+			}
+
+			if !slices.Equal(config.Extensions, testCase.want.Extensions) || config.Task != testCase.want.Task ||
+				config.Output != testCase.want.Output || len(config.Presets) != len(testCase.want.Presets) {
+				t.Fatalf("LoadProjectConfig() = %+v, want %+v", config, testCase.want)
+			}
+
+			for name, message := range testCase.want.Presets {
+				if config.Presets[name] != message {
+					t.Errorf("Preset %s = %q, want %q", name, config.Presets[name], message)
+				}
+			}
+		})
+	}
+}
+
+//nolint:paralleltest // t.Chdir cannot be used in parallel tests.
+func TestRunCLI_ProjectFileFromSubdirectory(t *testing.T) {
+	// TestMain turns discovery off for the other tests.
+	t.Setenv("PROMPT_BUILDER_NO_PROJECT", "false")
+
+	root := t.TempDir()
+	subdir := filepath.Join(root, "service", "logs")
+
+	err := os.MkdirAll(subdir, 0o750)
+	if err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(root, promptbuilder.ProjectFileName), []byte(projectFile), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write project file: %v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(subdir, "app.log"), []byte("panic: nil map"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write log file: %v", err)
+	}
+
+	t.Chdir(subdir)
+
+	var buf bytes.Buffer
+
+	err = promptbuilder.RunCLI([]string{"-p", "Why did it crash?", "-f", "app.log"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	var output map[string]any
+
+	err = json.Unmarshal(buf.Bytes(), &output)
+	if err != nil {
+		t.Fatalf("Expected json output from the project file, got %q: %v", buf.String(), err)
+	}
+
+	if output["system_message"] != "You are a careful reviewer. # not a comment" {
+		t.Errorf("Expected the project's review preset, got %v", output["system_message"])
+	}
+
+	if !strings.Contains(output["file_content"].(string), "panic: nil map") {
+		t.Errorf("Expected the .log file allowed by the project file, got %v", output["file_content"])
+	}
+
+	buf.Reset()
+
+	err = promptbuilder.RunCLI([]string{"-p", "Why did it crash?", "-o", "text", "-t", "triage"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	if buf.String() != "Sort issues by severity, it's urgent\n\nWhy did it crash?\n" {
+		t.Errorf("Expected flags to override the project defaults, got %q", buf.String())
+	}
+
+	buf.Reset()
+
+	err = promptbuilder.RunCLI([]string{"-p", "Why did it crash?", "-o", "text", "--no-project"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	if buf.String() != "Why did it crash?\n" {
+		t.Errorf("Expected --no-project to ignore the project file, got %q", buf.String())
+	}
+
+	flags, err := promptbuilder.ParseFlags([]string{"-p", "Why did it crash?"})
+	if err != nil {
+		t.Fatalf("ParseFlags() unexpected error = %v", err)
+	}
+
+	if flags.ProjectFile != "" || flags.OutputFormat != "" {
+		t.Errorf("Expected ParseFlags not to read the project file, got %+v", flags)
+	}
+}
@@ -0,0 +1,192 @@
+package promptbuilder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	defaultNATSURL = "nats://127.0.0.1:4222"
+	natsTimeout    = 5 * time.Second
+	natsClientName = "prompt-builder"
+)
+
+// natsSchemes are the URL schemes the NATS client can connect with.
+var natsSchemes = []string{"nats", "tls", "ws", "wss"}
+
+// Publishing errors.
+var (
+	ErrSubjectRequired    = errors.New("publish subject is required")
+	ErrInvalidSubject     = errors.New("publish subject is not a valid NATS subject")
+	ErrNATSProtocol       = errors.New("NATS server reported an error")
+	ErrUnsupportedNATSURL = errors.New("unsupported NATS URL")
+)
+
+// ValidateSubject checks that subject is a NATS subject that can be published
+// to: dot-separated tokens of printable ASCII characters without whitespace
+// and without the * and > wildcards, which are only valid in subscriptions.
+func ValidateSubject(subject string) error {
+	if strings.TrimSpace(subject) == "" {
+		return ErrSubjectRequired
+	}
+
+	for token := range strings.SplitSeq(subject, ".") {
+		if token == "" {
+			return fmt.Errorf("%w: %q has an empty token", ErrInvalidSubject, subject)
+		}
+
+		for _, char := range token {
+			if char <= ' ' || char > '~' || char == '*' || char == '>' {
+				return fmt.Errorf("%w: %q contains %q", ErrInvalidSubject, subject, char)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Publisher sends a payload to a message queue subject.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// PublishPrompt serializes the prompt as JSON and publishes it to subject.
+func PublishPrompt(publisher Publisher, subject string, prompt *Prompt) error {
+	err := ValidateSubject(subject)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(prompt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal prompt: %w", err)
+	}
+
+	err = publisher.Publish(subject, data)
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", subject, err)
+	}
+
+	return nil
+}
+
+// NATSPublisher publishes messages to a NATS server with the nats.go client.
+// Each Publish call connects, publishes one message, and flushes it so that
+// errors the server reports, such as a permissions violation, are returned.
+// URL may list several servers separated by commas, use tls:// to require
+// TLS, and carry a user and password or a token; CredsFile names a
+// credentials file for servers using decentralized authentication.
+type NATSPublisher struct {
+	URL       string
+	CredsFile string
+}
+
+// NewNATSPublisher creates a publisher for the server at rawURL, such as
+// "nats://localhost:4222". An empty URL selects the local default server.
+func NewNATSPublisher(rawURL string) *NATSPublisher {
+	if rawURL == "" {
+		rawURL = defaultNATSURL
+	}
+
+	return &NATSPublisher{URL: rawURL, CredsFile: ""}
+}
+
+// Publish sends data to subject.
+func (p *NATSPublisher) Publish(subject string, data []byte) error {
+	err := ValidateSubject(subject)
+	if err != nil {
+		return err
+	}
+
+	err = validateNATSURL(p.URL)
+	if err != nil {
+		return err
+	}
+
+	options := []nats.Option{
+		nats.Name(natsClientName), nats.Timeout(natsTimeout), nats.NoReconnect(),
+		// Server errors are returned below rather than printed by the client.
+		nats.ErrorHandler(func(*nats.Conn, *nats.Subscription, error) {}),
+	}
+	if p.CredsFile != "" {
+		options = append(options, nats.UserCredentials(p.CredsFile))
+	}
+
+	conn, err := nats.Connect(p.URL, options...)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS server %s: %w", redactNATSURL(p.URL), err)
+	}
+
+	defer conn.Close()
+
+	err = conn.Publish(subject, data)
+	if err != nil {
+		return fmt.Errorf("failed to publish NATS message: %w", err)
+	}
+
+	err = conn.FlushTimeout(natsTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to flush NATS message: %w", err)
+	}
+
+	// Errors the server sends for the message arrive before the reply to the
+	// flush, so they are recorded by now.
+	err = conn.LastError()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNATSProtocol, err)
+	}
+
+	return nil
+}
+
+// validateNATSURL checks that each comma-separated server of rawURL has a
+// scheme the client supports and a host.
+func validateNATSURL(rawURL string) error {
+	for server := range strings.SplitSeq(rawURL, ",") {
+		parsed, err := url.Parse(strings.TrimSpace(server))
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrUnsupportedNATSURL, redactNATSURL(server))
+		}
+
+		if !slices.Contains(natsSchemes, parsed.Scheme) {
+			return fmt.Errorf("%w: scheme %q (use one of %s)", ErrUnsupportedNATSURL, parsed.Scheme,
+				strings.Join(natsSchemes, ", "))
+		}
+
+		if parsed.Hostname() == "" {
+			return fmt.Errorf("%w: %s has no host", ErrUnsupportedNATSURL, redactNATSURL(server))
+		}
+	}
+
+	return nil
+}
+
+// redactNATSURL returns rawURL with any user, password, or token replaced,
+// for use in error messages.
+func redactNATSURL(rawURL string) string {
+	servers := strings.Split(rawURL, ",")
+
+	for index, server := range servers {
+		parsed, err := url.Parse(strings.TrimSpace(server))
+		if err != nil {
+			servers[index] = "invalid URL"
+
+			continue
+		}
+
+		if parsed.User != nil {
+			parsed.User = url.User("xxxxx")
+		}
+
+		servers[index] = parsed.String()
+	}
+
+	return strings.Join(servers, ",")
+}
@@ -0,0 +1,802 @@
+package promptbuilder
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	minFenceLength = 3 // CommonMark requires at least three backticks
+)
+
+// Built-in output formats. Markdown is the default when no format is given.
+const (
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+	FormatNDJSON   = "ndjson"
+	FormatText     = "text"
+	FormatCSV      = "csv"
+	FormatShell    = "shell"
+	FormatYAML     = "yaml"
+	FormatHeredoc  = "heredoc"
+	FormatOpenAI   = "openai"
+)
+
+// JSON key naming styles for BuildResult.JSONKeys.
+const (
+	JSONKeysSnake = "snake"
+	JSONKeysCamel = "camel"
+)
+
+var (
+	// ErrUnsupportedFormat is returned when an output format is not recognized.
+	ErrUnsupportedFormat = errors.New("unsupported output format")
+	// ErrUnknownJSONKeys is returned for a JSON key style other than snake or camel.
+	ErrUnknownJSONKeys = errors.New("unknown JSON key style")
+)
+
+// Formatter renders a prompt into a custom output format.
+type Formatter func(prompt *Prompt) ([]byte, error)
+
+// WriteFormat renders the prompt in the given output format and writes it to w
+// in a single call. Formatters registered on the builder that produced the
+// result take precedence. It returns the number of bytes written.
+func (r *BuildResult) WriteFormat(w io.Writer, format string) (int64, error) {
+	data, err := r.render(format)
+	if err != nil {
+		return 0, err
+	}
+
+	written, err := w.Write(data)
+	if err != nil {
+		return int64(written), fmt.Errorf("failed to write %s output: %w", formatName(format), err)
+	}
+
+	return int64(written), nil
+}
+
+// FileSummary describes one included file in JSON output.
+type FileSummary struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Language string `json:"language"`
+
+	FrontMatter map[string]string `json:"front_matter,omitempty"`
+}
+
+// FileEntry carries one included file's content in JSON output when
+// BuildResult.JSONFiles is set.
+type FileEntry struct {
+	Path     string `json:"path"`
+	Content  string `json:"content"`
+	Language string `json:"language"`
+}
+
+// render formats the result. The built-in JSON format additionally lists the
+// included files in a "files" array alongside the concatenated file content,
+// or, with JSONFiles, carries each file's content there and leaves
+// file_content empty. With a SignKey the output carries its own signature,
+// as checked by VerifyOutput. The openai format renders the result's chat
+// messages, with file content in FileRole and images at ImageDetail.
+func (r *BuildResult) render(format string) ([]byte, error) {
+	_, custom := r.formatters[format]
+
+	if format == FormatOpenAI && !custom {
+		messages, err := r.ToChatMessages()
+		if err != nil {
+			return nil, err
+		}
+
+		return renderChatMessages(messages)
+	}
+
+	jsonFormat := !custom && (format == FormatJSON || format == FormatNDJSON)
+	withFiles := format == FormatJSON && len(r.Files) > 0
+
+	if jsonFormat && (withFiles || len(r.SignKey) > 0 || r.HeadComment != "" || r.JSONKeys != "") {
+		return r.renderJSON(format)
+	}
+
+	data, err := renderWith(r.formatters, r.Prompt, format)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.HeadComment != "" && !custom {
+		data = append(headComment(format, r.HeadComment), data...)
+	}
+
+	return signed(data, r.SignKey), nil
+}
+
+// signed appends the "Signature: " line that VerifyOutput checks to data
+// when key is set.
+func signed(data, key []byte) []byte {
+	if len(key) == 0 {
+		return data
+	}
+
+	return fmt.Appendf(data, "%s%s\n", signatureLabel, SignOutput(key, data))
+}
+
+// headComment formats comment as a leading comment line for format: an HTML
+// comment in markdown and a # comment in yaml and heredoc output. Text and
+// CSV have no comment syntax, and shell output must stay a single line that
+// can be pasted as one argument, so nothing is added there.
+func headComment(format, comment string) []byte {
+	switch format {
+	case FormatMarkdown, "":
+		return []byte("<!-- " + comment + " -->\n")
+	case FormatYAML, FormatHeredoc:
+		return []byte("# " + comment + "\n")
+	default:
+		return nil
+	}
+}
+
+// renderJSON renders the built-in JSON or NDJSON format with the result's
+// files, signature, and head comment, using the JSONKeys naming style.
+func (r *BuildResult) renderJSON(format string) ([]byte, error) {
+	fields := promptJSONFields(r.Prompt)
+	output := fields
+
+	if format == FormatJSON && len(r.Files) > 0 {
+		if r.JSONFiles {
+			fields["file_content"] = ""
+			fields["files"] = fileEntries(r.Files, r.language)
+		} else {
+			fields["files"] = fileSummaries(r.Files, r.language)
+		}
+	}
+
+	if r.HeadComment != "" {
+		fields["generated_by"] = r.HeadComment
+	}
+
+	switch r.JSONKeys {
+	case "", JSONKeysSnake:
+		// The fields are built with snake_case keys.
+	case JSONKeysCamel:
+		camel, err := camelCaseKeys(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		output = camel
+	default:
+		return nil, fmt.Errorf("%w: %s (valid: %s, %s)", ErrUnknownJSONKeys, r.JSONKeys, JSONKeysSnake, JSONKeysCamel)
+	}
+
+	if len(r.SignKey) > 0 {
+		signed, err := signJSON(output, r.SignKey)
+		if err != nil {
+			return nil, err
+		}
+
+		output = signed
+	}
+
+	if format == FormatNDJSON {
+		jsonBytes, err := json.Marshal(output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal NDJSON line: %w", err)
+		}
+
+		return append(jsonBytes, '\n'), nil
+	}
+
+	jsonBytes, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	return append(jsonBytes, '\n'), nil
+}
+
+// camelCaseKeys returns the JSON fields with every snake_case key, including
+// those of the file objects, renamed to camelCase to match the struct tags.
+// Front matter keys come from the files themselves and are kept as they are.
+func camelCaseKeys(fields map[string]any) (map[string]any, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var generic any
+
+	err = decoder.Decode(&generic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JSON: %w", err)
+	}
+
+	renamed, _ := renameKeys(generic).(map[string]any)
+
+	return renamed, nil
+}
+
+// renameKeys converts the object keys within value from snake_case to
+// camelCase, leaving front matter objects untouched.
+func renameKeys(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		renamed := make(map[string]any, len(typed))
+
+		for key, item := range typed {
+			if key == "front_matter" {
+				renamed[snakeToCamel(key)] = item
+
+				continue
+			}
+
+			renamed[snakeToCamel(key)] = renameKeys(item)
+		}
+
+		return renamed
+	case []any:
+		for index, item := range typed {
+			typed[index] = renameKeys(item)
+		}
+
+		return typed
+	default:
+		return value
+	}
+}
+
+// snakeToCamel converts a snake_case name such as "system_message" to
+// camelCase ("systemMessage").
+func snakeToCamel(name string) string {
+	parts := strings.Split(name, "_")
+	for index := 1; index < len(parts); index++ {
+		if parts[index] != "" {
+			parts[index] = strings.ToUpper(parts[index][:1]) + parts[index][1:]
+		}
+	}
+
+	return strings.Join(parts, "")
+}
+
+// language returns the fence language of path as the builder that produced the
+// result fences it, falling back to the language of the extension for results
+// not produced by a builder.
+func (r *BuildResult) language(path string) string {
+	if r.fenceLanguage == nil {
+		return getLanguageFromExt(filepath.Ext(path))
+	}
+
+	return r.fenceLanguage(path)
+}
+
+// fileSummaries returns the path, size, and fence language of each file.
+func fileSummaries(files []*FileContent, language func(path string) string) []FileSummary {
+	summaries := make([]FileSummary, 0, len(files))
+
+	for _, file := range files {
+		summaries = append(summaries, FileSummary{
+			Path:        file.Path,
+			Size:        file.Size,
+			Language:    language(file.Path),
+			FrontMatter: file.FrontMatter,
+		})
+	}
+
+	return summaries
+}
+
+// fileEntries returns the path, raw content, and fence language of each file.
+func fileEntries(files []*FileContent, language func(path string) string) []FileEntry {
+	entries := make([]FileEntry, 0, len(files))
+
+	for _, file := range files {
+		entries = append(entries, FileEntry{
+			Path:     file.Path,
+			Content:  string(file.Content),
+			Language: language(file.Path),
+		})
+	}
+
+	return entries
+}
+
+// WriteNDJSON writes each prompt as a compact JSON object followed by a newline.
+// The newline-delimited output is suitable for streaming many prompts into other
+// tools, one result per line.
+func WriteNDJSON(output io.Writer, prompts ...*Prompt) error {
+	for _, prompt := range prompts {
+		data, err := renderPrompt(prompt, FormatNDJSON)
+		if err != nil {
+			return err
+		}
+
+		_, err = output.Write(data)
+		if err != nil {
+			return fmt.Errorf("failed to write NDJSON output: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// renderWith formats the prompt with a matching custom formatter, falling back
+// to the built-in formats.
+func renderWith(formatters map[string]Formatter, prompt *Prompt, format string) ([]byte, error) {
+	if formatter, ok := formatters[format]; ok {
+		data, err := formatter(prompt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s output: %w", format, err)
+		}
+
+		return data, nil
+	}
+
+	return renderPrompt(prompt, format)
+}
+
+// renderPrompt formats the prompt according to the specified format. This
+// function is responsible for all the output formatting logic. An empty format
+// selects markdown.
+func renderPrompt(prompt *Prompt, format string) ([]byte, error) {
+	switch format {
+	case FormatMarkdown, "":
+		return []byte(prompt.ToMarkdown()), nil
+	case FormatJSON:
+		jsonBytes, err := json.MarshalIndent(promptJSONFields(prompt), "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+
+		return append(jsonBytes, '\n'), nil
+	case FormatNDJSON:
+		jsonBytes, err := json.Marshal(promptJSONFields(prompt))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal NDJSON line: %w", err)
+		}
+
+		return append(jsonBytes, '\n'), nil
+	case FormatText:
+		return []byte(prompt.String() + "\n"), nil
+	case FormatCSV:
+		return renderCSV(prompt)
+	case FormatShell:
+		return []byte(ShellQuote(prompt.String()) + "\n"), nil
+	case FormatYAML:
+		return renderYAML(prompt), nil
+	case FormatHeredoc:
+		return renderHeredoc(prompt.String()), nil
+	case FormatOpenAI:
+		messages, err := prompt.ToChatMessages("")
+		if err != nil {
+			return nil, err
+		}
+
+		return renderChatMessages(messages)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+}
+
+// ToMarkdown renders the prompt as the markdown output format: a
+// "# Generated Prompt" heading followed by the prompt text in a code block.
+// The fence is longer than any backtick run in the text, so fences inside
+// the prompt cannot close the block early.
+func (p *Prompt) ToMarkdown() string {
+	return "# Generated Prompt\n\n" + markdownBlock(p.String())
+}
+
+// RenderFileSections renders the result as markdown with each attached file
+// under its own "## path" heading in a separate fenced code block, so the
+// output is navigable when viewed. The remaining prompt sections are rendered
+// as in the markdown format and keep their order around the files: the
+// sections before the file content go in a block under the title, and those
+// after it, such as the user prompt, under a "## Request" heading following
+// the files. File sections are omitted when the prompt's file content has
+// been filtered out.
+//
+// Files go through the same steps as fenced file content: blank lines are
+// collapsed and lines numbered as the file processor is set up to, the note
+// follows the path in the heading, the last commit precedes the code block,
+// and a width above zero wraps the text as Prompt.Wrapped does. The result's
+// head comment and SignKey apply as in WriteFormat.
+func (b *Builder) RenderFileSections(result *BuildResult, width int) []byte {
+	prompt := result.Prompt
+
+	var buf bytes.Buffer
+
+	if result.HeadComment != "" {
+		buf.Write(headComment(FormatMarkdown, result.HeadComment))
+	}
+
+	if prompt.FileContent == "" {
+		buf.WriteString(prompt.ToMarkdown())
+
+		return signed(buf.Bytes(), result.SignKey)
+	}
+
+	var before, after []string
+
+	filesSeen := false
+
+	for _, section := range prompt.Sections() {
+		switch {
+		case section.Name == SectionFiles:
+			filesSeen = true
+		case filesSeen:
+			after = append(after, section.text())
+		default:
+			before = append(before, section.text())
+		}
+	}
+
+	buf.WriteString("# Generated Prompt\n")
+
+	if len(before) > 0 {
+		buf.WriteString("\n" + markdownBlock(strings.Join(before, prompt.separator())))
+	}
+
+	for _, file := range result.Files {
+		heading := b.fileProcessor.DisplayPath(file.Path)
+		if note := strings.TrimSpace(file.Note); note != "" {
+			heading += fenceNoteSeparator + strings.ReplaceAll(note, "\n", " ")
+		}
+
+		fmt.Fprintf(&buf, "\n## %s\n\n", heading)
+
+		for _, line := range fileHeaderLines(file) {
+			fmt.Fprintf(&buf, "%s\n\n", line)
+		}
+
+		fileText := strings.TrimSuffix(string(b.fileProcessor.fileText(file)), "\n")
+		if width > 0 {
+			fileText = WrapText(fileText, width)
+		}
+
+		fileFence := markdownFence(fileText)
+
+		language := b.fileProcessor.FenceLanguage(file.Path)
+		fmt.Fprintf(&buf, "%s%s\n%s\n%s\n", fileFence, language, fileText, fileFence)
+	}
+
+	fmt.Fprintf(&buf, "\n## Request\n\n%s", markdownBlock(strings.Join(after, prompt.separator())))
+
+	return signed(buf.Bytes(), result.SignKey)
+}
+
+// markdownBlock returns content in a fenced code block long enough to hold
+// it, followed by a newline.
+func markdownBlock(content string) string {
+	fence := markdownFence(content)
+
+	return fence + "\n" + content + "\n" + fence + "\n"
+}
+
+// ValidateFormat checks that format names one of the built-in output formats.
+// An empty format is valid and selects markdown.
+func ValidateFormat(format string) error {
+	if format == "" || slices.Contains(SupportedFormats(), format) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s (supported: %s)",
+		ErrUnsupportedFormat, format, strings.Join(SupportedFormats(), ", "))
+}
+
+// formatExtensions maps output file extensions to the formats they imply.
+var formatExtensions = map[string]string{
+	".md":       FormatMarkdown,
+	".markdown": FormatMarkdown,
+	".json":     FormatJSON,
+	".ndjson":   FormatNDJSON,
+	".jsonl":    FormatNDJSON,
+	".txt":      FormatText,
+	".csv":      FormatCSV,
+	".sh":       FormatShell,
+	".yaml":     FormatYAML,
+	".yml":      FormatYAML,
+}
+
+// FormatForPath returns the output format implied by the extension of path,
+// or an empty string, which selects markdown, when the extension is not
+// recognized.
+func FormatForPath(path string) string {
+	return formatExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// SupportedFormats returns the names of the built-in output formats.
+func SupportedFormats() []string {
+	return []string{
+		FormatMarkdown, FormatJSON, FormatNDJSON, FormatText, FormatCSV, FormatShell, FormatYAML, FormatHeredoc,
+		FormatOpenAI,
+	}
+}
+
+// renderCSV writes a header row and a single data row holding the prompt
+// components. Fields containing commas, quotes, or newlines are quoted.
+func renderCSV(prompt *Prompt) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writer := csv.NewWriter(&buf)
+
+	records := [][]string{
+		{"system_message", "user_prompt", "file_content", "guidelines"},
+		{prompt.SystemMessage, prompt.UserPrompt, prompt.FileContent, prompt.Guidelines},
+	}
+
+	err := writer.WriteAll(records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// renderYAML writes the JSON fields as a YAML mapping with sorted keys.
+// Multi-line values become literal block scalars so they stay readable.
+func renderYAML(prompt *Prompt) []byte {
+	fields := promptJSONFields(prompt)
+
+	var buf bytes.Buffer
+
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		value, _ := fields[key].(string)
+		buf.WriteString(key + ": " + yamlScalar(value) + "\n")
+	}
+
+	return buf.Bytes()
+}
+
+// yamlScalar formats value as a YAML scalar. Text with line breaks becomes a
+// literal block indented by two spaces; the explicit indentation indicator
+// keeps leading spaces, and the chomping indicator keeps or strips the final
+// line break. Everything else is double quoted, which JSON string escaping
+// satisfies.
+func yamlScalar(value string) string {
+	printable := strings.IndexFunc(value, func(char rune) bool {
+		return char != '\n' && char != '\t' && !unicode.IsPrint(char)
+	}) < 0
+
+	if !strings.Contains(value, "\n") || !printable {
+		// Marshalling a string cannot fail.
+		quoted, _ := json.Marshal(value)
+
+		return string(quoted)
+	}
+
+	chomping := "-"
+	if strings.HasSuffix(value, "\n") {
+		chomping = "+"
+	}
+
+	var builder strings.Builder
+
+	builder.WriteString("|2" + chomping)
+
+	for line := range strings.SplitSeq(strings.TrimSuffix(value, "\n"), "\n") {
+		builder.WriteString("\n")
+
+		if line != "" {
+			builder.WriteString("  " + line)
+		}
+	}
+
+	return builder.String()
+}
+
+// ShellQuote quotes text as a single bash argument using ANSI-C $'...'
+// quoting, so newlines and other control characters become escapes and the
+// result fits on one line.
+func ShellQuote(text string) string {
+	var builder strings.Builder
+
+	builder.WriteString("$'")
+
+	for _, char := range text {
+		switch char {
+		case '\\':
+			builder.WriteString(`\\`)
+		case '\'':
+			builder.WriteString(`\'`)
+		case '\n':
+			builder.WriteString(`\n`)
+		case '\r':
+			builder.WriteString(`\r`)
+		case '\t':
+			builder.WriteString(`\t`)
+		default:
+			if char < ' ' || char == 0x7f {
+				fmt.Fprintf(&builder, `\x%02x`, char)
+			} else {
+				builder.WriteRune(char)
+			}
+		}
+	}
+
+	builder.WriteString("'")
+
+	return builder.String()
+}
+
+// formatName returns a human readable name for an output format, used in error
+// messages.
+func formatName(format string) string {
+	if format == "" {
+		return FormatMarkdown
+	}
+
+	return format
+}
+
+// promptJSONFields returns the key/value layout shared by the JSON based output
+// formats.
+func promptJSONFields(prompt *Prompt) map[string]any {
+	fields := map[string]any{
+		"system_message": prompt.SystemMessage,
+		"user_prompt":    prompt.UserPrompt,
+		"file_content":   prompt.FileContent,
+		"guidelines":     prompt.Guidelines,
+	}
+
+	if prompt.SystemContext != "" {
+		fields["system_context"] = prompt.SystemContext
+	}
+
+	if prompt.ImagePath != "" {
+		fields["image_path"] = prompt.ImagePath
+	}
+
+	if prompt.Manifest != "" {
+		fields["manifest"] = prompt.Manifest
+	}
+
+	if prompt.TableOfContents != "" {
+		fields["table_of_contents"] = prompt.TableOfContents
+	}
+
+	return fields
+}
+
+// heredocDelimiter is the preferred here-document delimiter. A numbered
+// variant such as EOF1 is used when the content contains it.
+const heredocDelimiter = "EOF"
+
+// renderHeredoc wraps content in a quoted "cat <<'EOF'" here-document, so the
+// shell copies it verbatim without expansions. The delimiter is escalated to
+// EOF1, EOF2, and so on until the content does not contain it.
+func renderHeredoc(content string) []byte {
+	delimiter := heredocDelimiter
+	for suffix := 1; strings.Contains(content, delimiter); suffix++ {
+		delimiter = heredocDelimiter + strconv.Itoa(suffix)
+	}
+
+	return fmt.Appendf(nil, "cat <<'%s'\n%s\n%s\n", delimiter, content, delimiter)
+}
+
+// markdownFence returns a backtick fence that is one backtick longer than the
+// longest backtick run in the content, so embedded fences cannot terminate the
+// outer block early.
+func markdownFence(content string) string {
+	longest := 0
+	current := 0
+
+	for _, char := range content {
+		if char == '`' {
+			current++
+			longest = max(longest, current)
+
+			continue
+		}
+
+		current = 0
+	}
+
+	return strings.Repeat("`", max(minFenceLength, longest+1))
+}
+
+// WrapText hard-wraps text at width columns on word boundaries. Lines inside
+// fenced code blocks are left untouched, as are words longer than width. A
+// width of zero or less returns the text unchanged.
+func WrapText(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	wrapped := make([]string, 0, len(lines))
+	inFence := false
+
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			wrapped = append(wrapped, line)
+
+			continue
+		}
+
+		if inFence || utf8.RuneCountInString(line) <= width {
+			wrapped = append(wrapped, line)
+
+			continue
+		}
+
+		wrapped = append(wrapped, wrapLine(line, width)...)
+	}
+
+	return strings.Join(wrapped, "\n")
+}
+
+// wrapLine splits a single line into lines of at most width columns, keeping
+// the original indentation on every continuation line.
+func wrapLine(line string, width int) []string {
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+	var (
+		lines   []string
+		current strings.Builder
+	)
+
+	for _, word := range strings.Fields(line) {
+		if current.Len() > 0 &&
+			utf8.RuneCountInString(current.String())+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, current.String())
+			current.Reset()
+		}
+
+		if current.Len() == 0 {
+			current.WriteString(indent + word)
+
+			continue
+		}
+
+		current.WriteString(" " + word)
+	}
+
+	if current.Len() > 0 {
+		lines = append(lines, current.String())
+	}
+
+	return lines
+}
+
+// WriteSectionFiles writes each non-empty section of the prompt to its own file
+// in dir, named after the section (system.txt, files.txt, guidelines.txt,
+// user.txt, and so on). The directory is created if needed. It returns the
+// paths written in render order.
+func (p *Prompt) WriteSectionFiles(dir string) ([]string, error) {
+	err := os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output directory %s: %w", dir, err)
+	}
+
+	var written []string
+
+	for _, section := range p.Sections() {
+		if section.Content == "" {
+			continue
+		}
+
+		path := filepath.Join(dir, section.Name+".txt")
+
+		err = os.WriteFile(path, []byte(section.Content), 0o600)
+		if err != nil {
+			return written, fmt.Errorf("failed to write %s section: %w", section.Name, err)
+		}
+
+		written = append(written, path)
+	}
+
+	return written, nil
+}
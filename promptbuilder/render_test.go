@@ -0,0 +1,663 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestBuildResult_WriteFormatReportsBytesWritten(t *testing.T) {
+	t.Parallel()
+
+	result := &promptbuilder.BuildResult{
+		Prompt: &promptbuilder.Prompt{
+			SystemMessage: "You are a reviewer.",
+			UserPrompt:    "Review this code.",
+			FileContent:   "BEGIN main.go\n```go\npackage main\n```\nEND main.go",
+			Guidelines:    "Be brief.",
+		},
+		Error: nil,
+	}
+
+	for _, format := range []string{"json", "ndjson", "text", "markdown", "csv", ""} {
+		t.Run("format "+format, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			written, err := result.WriteFormat(&buf, format)
+			if err != nil {
+				t.Fatalf("WriteFormat() unexpected error = %v", err)
+			}
+
+			if written != int64(buf.Len()) {
+				t.Errorf("Expected %d bytes reported, got %d", buf.Len(), written)
+			}
+
+			if written == 0 {
+				t.Error("Expected output, got empty buffer")
+			}
+		})
+	}
+}
+
+func TestBuilder_RegisterFormatter(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+
+	err := builder.RegisterFormatter("csv", func(prompt *promptbuilder.Prompt) ([]byte, error) {
+		var buf bytes.Buffer
+
+		writer := csv.NewWriter(&buf)
+
+		err := writer.Write([]string{prompt.SystemMessage, prompt.UserPrompt})
+		if err != nil {
+			return nil, err
+		}
+
+		writer.Flush()
+
+		return buf.Bytes(), writer.Error()
+	})
+	if err != nil {
+		t.Fatalf("RegisterFormatter() unexpected error = %v", err)
+	}
+
+	result, err := builder.BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:        "Review, please",
+		SystemMessage: "You are a reviewer",
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	want := "You are a reviewer,\"Review, please\"\n"
+
+	rendered, err := builder.Render(result.Prompt, "csv")
+	if err != nil {
+		t.Fatalf("Render() unexpected error = %v", err)
+	}
+
+	if string(rendered) != want {
+		t.Errorf("Expected %q from Render, got %q", want, rendered)
+	}
+
+	var buf bytes.Buffer
+
+	_, err = result.WriteFormat(&buf, "csv")
+	if err != nil {
+		t.Fatalf("WriteFormat() unexpected error = %v", err)
+	}
+
+	if buf.String() != want {
+		t.Errorf("Expected %q from WriteFormat, got %q", want, buf.String())
+	}
+}
+
+func TestBuilder_RegisterFormatterRejectsInvalid(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+
+	err := builder.RegisterFormatter(" ", func(*promptbuilder.Prompt) ([]byte, error) { return nil, nil })
+	if err == nil {
+		t.Error("Expected error for empty formatter name, got nil")
+	}
+
+	err = builder.RegisterFormatter("custom", nil)
+	if err == nil {
+		t.Error("Expected error for nil formatter, got nil")
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	t.Parallel()
+
+	prompt := &promptbuilder.Prompt{
+		UserPrompt: "The quick brown fox jumps over the lazy dog and keeps running",
+		FileContent: "BEGIN main.go\n```go\n" +
+			"func main() { fmt.Println(\"a very long line of code that must not be wrapped at all\") }\n" +
+			"```\nEND main.go",
+	}
+
+	wrapped := promptbuilder.WrapText(prompt.String(), 20)
+
+	codeLine := `func main() { fmt.Println("a very long line of code that must not be wrapped at all") }`
+	if !strings.Contains(wrapped, "\n"+codeLine+"\n") {
+		t.Errorf("Expected code fence content to be preserved, got %q", wrapped)
+	}
+
+	for line := range strings.Lines(wrapped) {
+		line = strings.TrimSuffix(line, "\n")
+		if line == codeLine {
+			continue
+		}
+
+		if len(line) > 20 {
+			t.Errorf("Expected line of at most 20 columns, got %q", line)
+		}
+	}
+
+	if !strings.Contains(wrapped, "The quick brown fox\njumps over the lazy\ndog and keeps\nrunning") {
+		t.Errorf("Expected prose to wrap on word boundaries, got %q", wrapped)
+	}
+}
+
+func TestBuildResult_WriteFormatCSVRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	prompt := &promptbuilder.Prompt{
+		SystemMessage: "You are a reviewer, be \"strict\".",
+		UserPrompt:    "Review this code.",
+		FileContent:   "BEGIN main.go\npackage main\n\nfunc main() {}\nEND main.go",
+		Guidelines:    "Be brief.",
+	}
+	result := &promptbuilder.BuildResult{Prompt: prompt}
+
+	var buf bytes.Buffer
+
+	_, err := result.WriteFormat(&buf, "csv")
+	if err != nil {
+		t.Fatalf("WriteFormat() unexpected error = %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV output: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected header and data rows, got %d rows", len(records))
+	}
+
+	want := []string{prompt.SystemMessage, prompt.UserPrompt, prompt.FileContent, prompt.Guidelines}
+	for index, field := range want {
+		if records[1][index] != field {
+			t.Errorf("Column %s: expected %q, got %q", records[0][index], field, records[1][index])
+		}
+	}
+}
+
+func TestRunCLI_JSONListsDirectoryFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	sizes := map[string]int{"a.go": 13, "b.txt": 5}
+
+	err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package main\n"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write a.go: %v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "b.txt"), []byte("notes"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write b.txt: %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	err = promptbuilder.RunCLI([]string{"-p", "Review", "--ext", ".go", "--ext", ".txt", "-f", dir, "-o", "json"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	var output struct {
+		FileContent string                      `json:"file_content"`
+		Files       []promptbuilder.FileSummary `json:"files"`
+	}
+
+	err = json.Unmarshal(buf.Bytes(), &output)
+	if err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+
+	if len(output.Files) != len(sizes) {
+		t.Fatalf("Expected %d files, got %+v", len(sizes), output.Files)
+	}
+
+	for _, file := range output.Files {
+		if want := sizes[filepath.Base(file.Path)]; int64(want) != file.Size {
+			t.Errorf("Expected %s to be %d bytes, got %d", file.Path, want, file.Size)
+		}
+	}
+
+	if output.Files[0].Language != "go" || output.FileContent == "" {
+		t.Errorf("Expected go language and file content, got %+v", output)
+	}
+}
+
+func TestRunCLI_SplitOutput(t *testing.T) {
+	t.Parallel()
+
+	tmpFileName, _, cleanup := setupFileProcessorTest(t)
+	t.Cleanup(cleanup)
+
+	dir := filepath.Join(t.TempDir(), "sections")
+
+	var buf bytes.Buffer
+
+	args := []string{"-p", "Review", "-sys", "You are a reviewer.", "--ext", ".go", "-f", tmpFileName, "--split-output", dir}
+
+	err := promptbuilder.RunCLI(args, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	want := map[string]string{
+		"system.txt": "You are a reviewer.",
+		"user.txt":   "Review",
+	}
+
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Expected %s to be written: %v", name, err)
+		}
+
+		if string(data) != content {
+			t.Errorf("Expected %s to hold %q, got %q", name, content, data)
+		}
+	}
+
+	files, err := os.ReadFile(filepath.Join(dir, "files.txt"))
+	if err != nil || !strings.HasPrefix(string(files), "BEGIN "+tmpFileName) {
+		t.Errorf("Expected fenced file content in files.txt, got %q (%v)", files, err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "guidelines.txt"))
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected no guidelines.txt for an empty section, got %v", err)
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("Expected nothing on stdout, got %q", buf.String())
+	}
+}
+
+func TestPrompt_WriteSectionFilesKeepsOtherFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "context.txt")
+
+	err := os.WriteFile(existing, []byte("not ours"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write %s: %v", existing, err)
+	}
+
+	_, err = (&promptbuilder.Prompt{UserPrompt: "Review"}).WriteSectionFiles(dir)
+	if err != nil {
+		t.Fatalf("WriteSectionFiles() unexpected error = %v", err)
+	}
+
+	data, err := os.ReadFile(existing)
+	if err != nil || string(data) != "not ours" {
+		t.Errorf("Expected %s to be left alone, got %q, %v", existing, data, err)
+	}
+}
+
+func TestRunCLI_ShellFormatRoundTrips(t *testing.T) {
+	t.Parallel()
+
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not available")
+	}
+
+	var buf bytes.Buffer
+
+	prompt := "Don't break\n\tthis \"prompt\" with $HOME, `ticks` and \\backslashes"
+
+	err = promptbuilder.RunCLI([]string{"-p", prompt, "-g", "Line one\nLine two", "-o", "shell"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	quoted := strings.TrimSuffix(buf.String(), "\n")
+	if strings.ContainsAny(quoted, "\n\r") {
+		t.Fatalf("Expected a single line, got %q", quoted)
+	}
+
+	// #nosec G204 -- The command runs the test's own quoted output.
+	output, err := exec.CommandContext(t.Context(), bash, "-c", "printf %s "+quoted).Output()
+	if err != nil {
+		t.Fatalf("bash failed to parse %q: %v", quoted, err)
+	}
+
+	want := "Guidelines:\n\nLine one\nLine two\n\n" + prompt
+	if string(output) != want {
+		t.Errorf("Expected round trip %q, got %q", want, output)
+	}
+}
+
+func TestRunCLI_JSONFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	content := "package main\n\nvar s = \"quoted\\tvalue\"\n"
+
+	err := os.WriteFile(filepath.Join(dir, "a.go"), []byte(content), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write a.go: %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	err = promptbuilder.RunCLI([]string{"-p", "Review", "--ext", ".go", "-f", dir, "-o", "json", "--json-files"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	var output struct {
+		FileContent *string                   `json:"file_content"`
+		Files       []promptbuilder.FileEntry `json:"files"`
+	}
+
+	err = json.Unmarshal(buf.Bytes(), &output)
+	if err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+
+	if output.FileContent == nil || *output.FileContent != "" {
+		t.Errorf("Expected empty file_content, got %v", output.FileContent)
+	}
+
+	if len(output.Files) != 1 {
+		t.Fatalf("Expected one file, got %+v", output.Files)
+	}
+
+	file := output.Files[0]
+	if filepath.Base(file.Path) != "a.go" || file.Content != content || file.Language != "go" {
+		t.Errorf("Unexpected file entry: %+v", file)
+	}
+}
+
+func TestRunCLI_JSONFilesUseFenceLanguage(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "page.tmpl")
+
+	err := os.WriteFile(path, []byte("<p>{{.Title}}</p>\n"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write page.tmpl: %v", err)
+	}
+
+	for _, extra := range [][]string{nil, {"--json-files"}} {
+		var buf bytes.Buffer
+
+		args := append([]string{"-p", "Review", "--ext", ".tmpl", "--lang", "tmpl=html", "-f", path, "-o", "json"}, extra...)
+
+		err = promptbuilder.RunCLI(args, nil, &buf)
+		if err != nil {
+			t.Fatalf("RunCLI(%v) unexpected error = %v", extra, err)
+		}
+
+		var output struct {
+			Files []promptbuilder.FileSummary `json:"files"`
+		}
+
+		err = json.Unmarshal(buf.Bytes(), &output)
+		if err != nil {
+			t.Fatalf("Output is not valid JSON: %v", err)
+		}
+
+		if len(output.Files) != 1 || output.Files[0].Language != "html" {
+			t.Errorf("Expected the --lang language in files with %v, got %+v", extra, output.Files)
+		}
+	}
+}
+
+func TestRunCLI_OutputFileInfersFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		file  string
+		extra []string
+		want  string
+	}{
+		{
+			name: "json",
+			file: "result.json",
+			want: "{\n  \"file_content\": \"\",\n  \"guidelines\": \"\",\n" +
+				"  \"system_message\": \"Be terse.\",\n  \"user_prompt\": \"Review\"\n}\n",
+		},
+		{
+			name: "yaml",
+			file: "result.yaml",
+			want: "file_content: \"\"\nguidelines: \"\"\nsystem_message: \"Be terse.\"\nuser_prompt: \"Review\"\n",
+		},
+		{
+			name:  "explicit format wins",
+			file:  "result.yaml",
+			extra: []string{"-o", "text"},
+			want:  "Be terse.\n\nReview\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), test.file)
+			args := append([]string{"-p", "Review", "--sys", "Be terse.", "--output-file", path}, test.extra...)
+
+			var buf bytes.Buffer
+
+			err := promptbuilder.RunCLI(args, nil, &buf)
+			if err != nil {
+				t.Fatalf("RunCLI() unexpected error = %v", err)
+			}
+
+			if buf.Len() != 0 {
+				t.Errorf("Expected nothing on the output writer, got %q", buf.String())
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+
+			if string(got) != test.want {
+				t.Errorf("Output file = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBuilder_RenderYAMLBlockScalars(t *testing.T) {
+	t.Parallel()
+
+	prompt := &promptbuilder.Prompt{UserPrompt: "  indented\n\nlast", Guidelines: "- one\n- two\n"}
+
+	got, err := newTestBuilder().Render(prompt, promptbuilder.FormatYAML)
+	if err != nil {
+		t.Fatalf("Render() unexpected error = %v", err)
+	}
+
+	want := "file_content: \"\"\n" +
+		"guidelines: |2+\n  - one\n  - two\n" +
+		"system_message: \"\"\n" +
+		"user_prompt: |2-\n    indented\n\n  last\n"
+	if string(got) != want {
+		t.Errorf("Render(yaml) = %q, want %q", got, want)
+	}
+}
+
+func TestRunCLI_HeadComment(t *testing.T) {
+	t.Parallel()
+
+	pattern := `generated by prompt-builder \S+ at \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z`
+
+	var buf bytes.Buffer
+
+	err := promptbuilder.RunCLI([]string{"-p", "Review", "--head-comment"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	markdown := regexp.MustCompile(`^<!-- ` + pattern + ` -->\n# Generated Prompt\n`)
+	if !markdown.MatchString(buf.String()) {
+		t.Errorf("Expected a leading HTML comment in markdown, got %q", buf.String())
+	}
+
+	buf.Reset()
+
+	err = promptbuilder.RunCLI([]string{"-p", "Review", "--head-comment", "-o", "json"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	var output struct {
+		GeneratedBy string `json:"generated_by"`
+		UserPrompt  string `json:"user_prompt"`
+	}
+
+	err = json.Unmarshal(buf.Bytes(), &output)
+	if err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+
+	if !regexp.MustCompile(`^`+pattern+`$`).MatchString(output.GeneratedBy) || output.UserPrompt != "Review" {
+		t.Errorf("Expected a generated_by field, got %+v", output)
+	}
+
+	buf.Reset()
+
+	err = promptbuilder.RunCLI([]string{"-p", "Review", "--head-comment", "-o", "shell"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	if got := buf.String(); got != "$'Review'\n" {
+		t.Errorf("Expected shell output to stay a single line without a comment, got %q", got)
+	}
+}
+
+func TestRunCLI_JSONKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		style   string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "snake",
+			style:   "snake",
+			want:    []string{"system_message", "user_prompt", "file_content"},
+			notWant: []string{"systemMessage", "userPrompt"},
+		},
+		{
+			name:    "camel",
+			style:   "camel",
+			want:    []string{"systemMessage", "userPrompt", "fileContent"},
+			notWant: []string{"system_message", "user_prompt"},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			args := []string{"-p", "Review", "-sys", "You are a reviewer.", "-o", "json", "--json-keys", testCase.style}
+
+			err := promptbuilder.RunCLI(args, nil, &buf)
+			if err != nil {
+				t.Fatalf("RunCLI() unexpected error = %v", err)
+			}
+
+			var output map[string]any
+
+			err = json.Unmarshal(buf.Bytes(), &output)
+			if err != nil {
+				t.Fatalf("Output is not valid JSON: %v", err)
+			}
+
+			for _, key := range testCase.want {
+				if _, ok := output[key]; !ok {
+					t.Errorf("Expected key %q in %v", key, output)
+				}
+			}
+
+			for _, key := range testCase.notWant {
+				if _, ok := output[key]; ok {
+					t.Errorf("Unexpected key %q in %v", key, output)
+				}
+			}
+		})
+	}
+}
+
+func TestRunCLI_HeredocFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		prompt    string
+		delimiter string
+	}{
+		{name: "plain prompt", prompt: "Explain this code", delimiter: "EOF"},
+		{name: "prompt containing EOF", prompt: "Explain\nEOF\nand EOF1 markers", delimiter: "EOF2"},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			err := promptbuilder.RunCLI([]string{"-p", testCase.prompt, "-o", "heredoc"}, nil, &buf)
+			if err != nil {
+				t.Fatalf("RunCLI() unexpected error = %v", err)
+			}
+
+			want := "cat <<'" + testCase.delimiter + "'\n" + testCase.prompt + "\n" + testCase.delimiter + "\n"
+			if buf.String() != want {
+				t.Errorf("RunCLI() output = %q, want %q", buf.String(), want)
+			}
+		})
+	}
+}
+
+func TestPrompt_ToMarkdownMatchesCLI(t *testing.T) {
+	t.Parallel()
+
+	tmpFileName, _, cleanup := setupFileProcessorTest(t)
+	t.Cleanup(cleanup)
+
+	prompt := "Explain:\n```go\nfmt.Println()\n```"
+
+	result, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{Prompt: prompt, File: tmpFileName})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	err = promptbuilder.RunCLI([]string{"-p", prompt, "--ext", ".go", "-f", tmpFileName, "-o", "markdown"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	markdown := result.Prompt.ToMarkdown()
+	if markdown != buf.String() {
+		t.Errorf("ToMarkdown() = %q, want CLI output %q", markdown, buf.String())
+	}
+
+	if !strings.HasPrefix(markdown, "# Generated Prompt\n\n````\n") {
+		t.Errorf("Expected a four-backtick fence around the prompt, got %q", markdown)
+	}
+}
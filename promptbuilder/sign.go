@@ -0,0 +1,140 @@
+package promptbuilder
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+)
+
+const (
+	// signatureLabel prefixes the signature line appended to non-JSON output.
+	signatureLabel = "Signature: "
+	// signatureField is the JSON field holding the signature.
+	signatureField = "signature"
+)
+
+// ErrEmptySignKey is returned when the signing key file or environment
+// variable is empty.
+var ErrEmptySignKey = errors.New("signing key is empty")
+
+// SignOutput returns the hex-encoded HMAC-SHA256 of output under key.
+func SignOutput(key, output []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(output)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyOutput reports whether output, as rendered with BuildResult.SignKey,
+// carries a valid signature under key. For a final "Signature: <hex>" line,
+// the signed bytes are the output before that line. For JSON, they are the
+// compact JSON encoding, with keys sorted as by encoding/json, of the object
+// without its "signature" field. The comparison takes constant time.
+func VerifyOutput(key, output []byte) bool {
+	if body, signature, ok := cutSignatureLine(output); ok {
+		return signatureMatches(key, body, signature)
+	}
+
+	var fields map[string]json.RawMessage
+
+	err := json.Unmarshal(output, &fields)
+	if err != nil {
+		return false
+	}
+
+	var signature string
+
+	err = json.Unmarshal(fields[signatureField], &signature)
+	if err != nil {
+		return false
+	}
+
+	delete(fields, signatureField)
+
+	signed, err := json.Marshal(fields)
+	if err != nil {
+		return false
+	}
+
+	return signatureMatches(key, signed, signature)
+}
+
+// cutSignatureLine splits output into the bytes before its final signature
+// line and the signature, reporting whether output ends with such a line.
+func cutSignatureLine(output []byte) ([]byte, string, bool) {
+	trimmed := bytes.TrimSuffix(output, []byte("\n"))
+
+	index := bytes.LastIndex(trimmed, []byte(signatureLabel))
+	if index < 0 || (index > 0 && trimmed[index-1] != '\n') {
+		return nil, "", false
+	}
+
+	signature := trimmed[index+len(signatureLabel):]
+	if bytes.ContainsAny(signature, "\n") {
+		return nil, "", false
+	}
+
+	return output[:index], string(signature), true
+}
+
+// signatureMatches reports whether signature is the hex-encoded HMAC of data
+// under key.
+func signatureMatches(key, data []byte, signature string) bool {
+	decoded, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	expected, _ := hex.DecodeString(SignOutput(key, data))
+
+	return hmac.Equal(decoded, expected)
+}
+
+// signJSON returns a copy of fields with a "signature" field holding the
+// signature of their compact encoding, as checked by VerifyOutput.
+func signJSON(fields map[string]any, key []byte) (map[string]any, error) {
+	signed, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON for signing: %w", err)
+	}
+
+	withSignature := maps.Clone(fields)
+	withSignature[signatureField] = SignOutput(key, signed)
+
+	return withSignature, nil
+}
+
+// loadSignKey returns the signing key read from path or, when path is empty,
+// from the environment variable env. A trailing newline in the file is not
+// part of the key. It returns nil when neither is set. Keys are never taken
+// from the command line, where other users and shell history could see them.
+func loadSignKey(path, env string) ([]byte, error) {
+	var key []byte
+
+	switch {
+	case path != "":
+		// #nosec G304 -- The key file is explicitly supplied by the user.
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key: %w", err)
+		}
+
+		key = bytes.TrimRight(data, "\r\n")
+	case env != "":
+		key = []byte(os.Getenv(env))
+	default:
+		return nil, nil
+	}
+
+	if len(key) == 0 {
+		return nil, ErrEmptySignKey
+	}
+
+	return key, nil
+}
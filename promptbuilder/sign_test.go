@@ -0,0 +1,171 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+// knownSignature is the HMAC-SHA256 of "Be terse.\n\nReview\n" under "secret".
+const knownSignature = "c90724ec9849ff7420b0eb6d20e3635755a97c78c28c9254cd40e228da6afd85"
+
+func TestSignOutput(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("secret")
+	output := []byte("Be terse.\n\nReview\n")
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(output)
+
+	if got, want := promptbuilder.SignOutput(key, output), hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("SignOutput() = %s, want %s", got, want)
+	}
+
+	if got := promptbuilder.SignOutput(key, output); got != knownSignature {
+		t.Errorf("SignOutput() = %s, want %s", got, knownSignature)
+	}
+}
+
+// writeSignKey writes key to a file and returns its path.
+func writeSignKey(t *testing.T, key string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "sign.key")
+
+	err := os.WriteFile(path, []byte(key), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+
+	return path
+}
+
+func TestRunCLI_SignKeyFile(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("secret")
+	args := []string{"-p", "Review", "--sys", "Be terse.", "--sign-key-file", writeSignKey(t, "secret\n")}
+
+	tests := []struct {
+		name  string
+		extra []string
+	}{
+		{name: "text", extra: []string{"-o", "text"}},
+		{name: "markdown with head comment", extra: []string{"--head-comment"}},
+		{name: "json", extra: []string{"-o", "json"}},
+		{name: "ndjson with camel keys", extra: []string{"-o", "ndjson", "--json-keys", "camel"}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			err := promptbuilder.RunCLI(append(append([]string{}, args...), testCase.extra...), nil, &buf)
+			if err != nil {
+				t.Fatalf("RunCLI() unexpected error = %v", err)
+			}
+
+			if !promptbuilder.VerifyOutput(key, buf.Bytes()) {
+				t.Errorf("Expected the output to verify, got %q", buf.String())
+			}
+
+			if promptbuilder.VerifyOutput([]byte("other"), buf.Bytes()) {
+				t.Error("Expected a signature under another key to fail")
+			}
+
+			tampered := bytes.Replace(buf.Bytes(), []byte("Review"), []byte("Approve"), 1)
+			if promptbuilder.VerifyOutput(key, tampered) {
+				t.Errorf("Expected tampered output to fail verification: %q", tampered)
+			}
+
+			if strings.Contains(buf.String(), "secret") {
+				t.Error("The key must not appear in the output")
+			}
+		})
+	}
+}
+
+func TestRunCLI_SignKeyText(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	args := []string{"-p", "Review", "--sys", "Be terse.", "--sign-key-file", writeSignKey(t, "secret"), "-o", "text"}
+
+	err := promptbuilder.RunCLI(args, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	want := "Be terse.\n\nReview\nSignature: " + knownSignature + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Expected signature line, got %q", got)
+	}
+}
+
+func TestRunCLI_SignKeyJSONField(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	args := []string{"-p", "Review", "--sign-key-file", writeSignKey(t, "secret"), "-o", "json"}
+
+	err := promptbuilder.RunCLI(args, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	var output map[string]any
+
+	err = json.Unmarshal(buf.Bytes(), &output)
+	if err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+
+	signature, _ := output["signature"].(string)
+	delete(output, "signature")
+
+	signed, err := json.Marshal(output)
+	if err != nil {
+		t.Fatalf("Failed to re-encode output: %v", err)
+	}
+
+	if want := promptbuilder.SignOutput([]byte("secret"), signed); signature != want {
+		t.Errorf("Expected the signature of the compact object %s, got %q", want, signature)
+	}
+}
+
+//nolint:paralleltest // t.Setenv cannot be used in parallel tests.
+func TestRunCLI_SignKeyEnv(t *testing.T) {
+	t.Setenv("TEST_SIGN_KEY", "secret")
+
+	var buf bytes.Buffer
+
+	err := promptbuilder.RunCLI([]string{"-p", "Review", "--sign-key-env", "TEST_SIGN_KEY"}, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	if !promptbuilder.VerifyOutput([]byte("secret"), buf.Bytes()) {
+		t.Errorf("Expected the output to verify, got %q", buf.String())
+	}
+
+	t.Setenv("TEST_SIGN_KEY", "")
+
+	err = promptbuilder.RunCLI([]string{"-p", "Review", "--sign-key-env", "TEST_SIGN_KEY"}, nil, &buf)
+	if !errors.Is(err, promptbuilder.ErrEmptySignKey) {
+		t.Errorf("Expected ErrEmptySignKey for an empty variable, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package promptbuilder
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+)
+
+// goSignatures reduces Go source to its package clause and package-level
+// declarations with function bodies removed. Doc comments on declarations are
+// kept; comments inside bodies are dropped with the bodies.
+func goSignatures(filename string, src []byte) ([]byte, error) {
+	fileSet := token.NewFileSet()
+
+	file, err := parser.ParseFile(fileSet, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Go source: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	writeDoc(&buf, file.Doc)
+	fmt.Fprintf(&buf, "package %s\n", file.Name.Name)
+
+	for _, decl := range file.Decls {
+		buf.WriteString("\n")
+
+		switch typed := decl.(type) {
+		case *ast.FuncDecl:
+			writeDoc(&buf, typed.Doc)
+
+			signature := *typed
+			signature.Doc = nil
+			signature.Body = nil
+			decl = &signature
+		case *ast.GenDecl:
+			writeDoc(&buf, typed.Doc)
+		}
+
+		err = printer.Fprint(&buf, fileSet, decl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to print declaration: %w", err)
+		}
+
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeDoc writes a doc comment group as // comment lines.
+func writeDoc(buf *bytes.Buffer, doc *ast.CommentGroup) {
+	if doc == nil {
+		return
+	}
+
+	for _, comment := range doc.List {
+		buf.WriteString(comment.Text + "\n")
+	}
+}
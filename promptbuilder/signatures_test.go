@@ -0,0 +1,111 @@
+package promptbuilder_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestFileProcessor_SignaturesOnly(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	goFile := filepath.Join(dir, "calc.go")
+	textFile := filepath.Join(dir, "notes.txt")
+
+	source := `package calc
+
+// Answer is the answer.
+const Answer = 42
+
+// Add returns the sum of a and b.
+func Add(a, b int) int {
+	total := a + b // body detail
+
+	return total
+}
+
+type Pair struct {
+	Left, Right int
+}
+
+func (p Pair) Sum() int { return Add(p.Left, p.Right) }
+`
+
+	for path, content := range map[string]string{goFile: source, textFile: "func body stays { here }"} {
+		err := os.WriteFile(path, []byte(content), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024*1024, []string{".go", ".txt"})
+	fileProcessor.SignaturesOnly = true
+
+	goContent, err := fileProcessor.ProcessFile(goFile)
+	if err != nil {
+		t.Fatalf("ProcessFile(%s) unexpected error = %v", goFile, err)
+	}
+
+	got := string(goContent.Content)
+
+	for _, want := range []string{
+		"package calc",
+		"// Add returns the sum of a and b.\nfunc Add(a, b int) int\n",
+		"const Answer = 42",
+		"type Pair struct {",
+		"func (p Pair) Sum() int\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in signatures, got %q", want, got)
+		}
+	}
+
+	for _, unwanted := range []string{"total := a + b", "body detail", "return Add"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("Expected function bodies to be dropped, found %q in %q", unwanted, got)
+		}
+	}
+
+	textContent, err := fileProcessor.ProcessFile(textFile)
+	if err != nil {
+		t.Fatalf("ProcessFile(%s) unexpected error = %v", textFile, err)
+	}
+
+	if string(textContent.Content) != "func body stays { here }" {
+		t.Errorf("Expected non-Go file untouched, got %q", textContent.Content)
+	}
+}
+
+func TestBuildPrompt_SignaturesOnlyWarnsOnParseError(t *testing.T) {
+	t.Parallel()
+
+	goFile := filepath.Join(t.TempDir(), "broken.go")
+
+	err := os.WriteFile(goFile, []byte("package broken\n\nfunc Open( {\n"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write %s: %v", goFile, err)
+	}
+
+	fileProcessor := promptbuilder.NewFileProcessor(1024*1024, []string{".go"})
+	fileProcessor.SignaturesOnly = true
+
+	result, err := promptbuilder.New(fileProcessor).BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt: "Review",
+		File:   goFile,
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "including "+goFile+" in full") {
+		t.Errorf("Expected a warning that the file is included in full, got %q", result.Warnings)
+	}
+
+	if !strings.Contains(result.Prompt.FileContent, "func Open( {") {
+		t.Errorf("Expected the unparsable file in full, got %q", result.Prompt.FileContent)
+	}
+}
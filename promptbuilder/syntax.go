@@ -0,0 +1,45 @@
+package promptbuilder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidSyntax is returned by ProcessFile with ValidateSyntax when a file
+// of a supported language does not parse.
+var ErrInvalidSyntax = errors.New("file has invalid syntax")
+
+// syntaxCheckers maps file extensions to a check that their content parses.
+var syntaxCheckers = map[string]func(filename string, content []byte) error{
+	".go": func(filename string, content []byte) error {
+		_, err := parser.ParseFile(token.NewFileSet(), filename, content, parser.AllErrors)
+
+		return err
+	},
+	".json": func(_ string, content []byte) error {
+		var value any
+
+		return json.Unmarshal(content, &value)
+	},
+}
+
+// checkSyntax reports ErrInvalidSyntax when path's content does not parse as
+// its language. Files of languages without a checker always pass.
+func checkSyntax(path string, content []byte) error {
+	checker, ok := syntaxCheckers[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil
+	}
+
+	err := checker(path, content)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidSyntax, path, err)
+	}
+
+	return nil
+}
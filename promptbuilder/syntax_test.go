@@ -0,0 +1,54 @@
+package promptbuilder_test
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestFileProcessor_ValidateSyntax(t *testing.T) {
+	t.Parallel()
+
+	files := fstest.MapFS{
+		"valid.go":    {Data: []byte("package main\n\nfunc main() {}\n")},
+		"broken.go":   {Data: []byte("package main\n\nfunc main() {\n")},
+		"valid.json":  {Data: []byte(`{"name": "prompt"}`)},
+		"broken.json": {Data: []byte(`{"name": }`)},
+		"notes.txt":   {Data: []byte("func main() {")},
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		validate bool
+		wantErr  bool
+	}{
+		{name: "valid Go", path: "valid.go", validate: true, wantErr: false},
+		{name: "broken Go", path: "broken.go", validate: true, wantErr: true},
+		{name: "broken Go without validation", path: "broken.go", validate: false, wantErr: false},
+		{name: "valid JSON", path: "valid.json", validate: true, wantErr: false},
+		{name: "broken JSON", path: "broken.json", validate: true, wantErr: true},
+		{name: "unchecked language", path: "notes.txt", validate: true, wantErr: false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			processor := promptbuilder.NewFileProcessor(1024, []string{".go", ".json", ".txt"})
+			processor.FileSystem = files
+			processor.ValidateSyntax = testCase.validate
+
+			_, err := processor.ProcessFile(testCase.path)
+			if testCase.wantErr && !errors.Is(err, promptbuilder.ErrInvalidSyntax) {
+				t.Errorf("Expected ErrInvalidSyntax, got %v", err)
+			}
+
+			if !testCase.wantErr && err != nil {
+				t.Errorf("ProcessFile() unexpected error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package promptbuilder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templateExt is the extension of partial templates loaded by LoadTemplateDir.
+const templateExt = ".tmpl"
+
+// Template errors.
+var (
+	ErrInvalidVar        = errors.New("template variable must be in key=value form")
+	ErrPromptAndTemplate = errors.New("prompt and template file cannot be combined")
+	ErrNoTemplates       = errors.New("no .tmpl files found")
+)
+
+// renderTemplate renders text as a text/template using the given data. Missing
+// keys are reported as errors instead of silently rendering "<no value>". When
+// partials is non-nil, the text may include its templates with
+// {{template "name" .}}.
+func renderTemplate(name, text string, data map[string]any, partials *template.Template) (string, error) {
+	root := template.New(name)
+
+	if partials != nil {
+		cloned, err := partials.Clone()
+		if err != nil {
+			return "", fmt.Errorf("failed to prepare %s template: %w", name, err)
+		}
+
+		root = cloned.New(name)
+	}
+
+	tmpl, err := root.Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+
+	var builder strings.Builder
+
+	err = tmpl.Execute(&builder, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+
+	return builder.String(), nil
+}
+
+// loadTemplateData reads a JSON object from path for use as template data.
+// Nested objects remain addressable with dot notation, e.g. {{.user.name}}.
+func loadTemplateData(path string) (map[string]any, error) {
+	// #nosec G304 -- The data file is explicitly supplied by the user and is
+	// only parsed as JSON, never included in the prompt verbatim.
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file %s: %w", path, err)
+	}
+
+	var data map[string]any
+
+	err = json.Unmarshal(content, &data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse data file %s as a JSON object: %w", path, err)
+	}
+
+	return data, nil
+}
+
+// loadTemplateFile reads a prompt template from path and checks that it parses.
+// The template is named after the file so parse errors report the file and
+// line, e.g. "template: prompt.tmpl:3: unexpected EOF".
+func loadTemplateFile(path string) (string, error) {
+	// #nosec G304 -- The template file is explicitly supplied by the user.
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template file %s: %w", path, err)
+	}
+
+	_, err = template.New(path).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template file: %w", err)
+	}
+
+	return string(content), nil
+}
+
+// LoadTemplateDir parses every .tmpl file in dir into a template set that
+// prompts and guidelines can include with {{template "name" .}}, where name is
+// the file name without its .tmpl extension. Partials may include each other
+// and may also declare further templates with {{define}}. Loading replaces any
+// previously loaded set.
+func (b *Builder) LoadTemplateDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+templateExt))
+	if err != nil {
+		return fmt.Errorf("failed to list templates in %s: %w", dir, err)
+	}
+
+	if len(paths) == 0 {
+		return fmt.Errorf("%w: %s", ErrNoTemplates, dir)
+	}
+
+	set := template.New("").Option("missingkey=error")
+
+	for _, path := range paths {
+		// #nosec G304 -- Template files come from a directory chosen by the user.
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read template %s: %w", path, err)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), templateExt)
+
+		_, err = set.New(name).Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("failed to parse template %s: %w", path, err)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.templates = set
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package promptbuilder_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+// writeDataFile writes a JSON data file into a temporary directory and returns its path.
+func writeDataFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write data file: %v", err)
+	}
+
+	return path
+}
+
+func TestBuildPrompt_RendersNestedTemplateData(t *testing.T) {
+	t.Parallel()
+
+	dataFile := writeDataFile(t, `{"user": {"name": "Ada"}, "style": "concise"}`)
+
+	flags := promptbuilder.CLIFlags{
+		Prompt:     "Write a greeting for {{.user.name}}",
+		Guidelines: "Be {{.style}}",
+		DataFile:   dataFile,
+	}
+
+	req, err := flags.ToBuildRequest()
+	if err != nil {
+		t.Fatalf("ToBuildRequest() unexpected error = %v", err)
+	}
+
+	result, err := newTestBuilder().BuildPrompt(req)
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if result.Prompt.UserPrompt != "Write a greeting for Ada" {
+		t.Errorf("Expected rendered prompt, got %q", result.Prompt.UserPrompt)
+	}
+
+	if result.Prompt.Guidelines != "Be concise" {
+		t.Errorf("Expected rendered guidelines, got %q", result.Prompt.Guidelines)
+	}
+}
+
+func TestBuildPrompt_TemplateMissingKeyFails(t *testing.T) {
+	t.Parallel()
+
+	dataFile := writeDataFile(t, `{"user": {"name": "Ada"}}`)
+
+	flags := promptbuilder.CLIFlags{
+		Prompt:   "Write a greeting for {{.user.email}}",
+		DataFile: dataFile,
+	}
+
+	req, err := flags.ToBuildRequest()
+	if err != nil {
+		t.Fatalf("ToBuildRequest() unexpected error = %v", err)
+	}
+
+	_, err = newTestBuilder().BuildPrompt(req)
+	if err == nil {
+		t.Error("Expected error for missing template key, got nil")
+	}
+}
+
+func TestRunCLI_TemplateFileWithVar(t *testing.T) {
+	t.Parallel()
+
+	templateFile := filepath.Join(t.TempDir(), "review.tmpl")
+
+	err := os.WriteFile(templateFile, []byte("Review the {{.lang}} code for {{.focus}}"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write template file: %v", err)
+	}
+
+	dataFile := writeDataFile(t, `{"lang": "Go", "focus": "style"}`)
+
+	var buf bytes.Buffer
+
+	args := []string{"--template-file", templateFile, "--data", dataFile, "--var", "focus=races", "-o", "text"}
+
+	err = promptbuilder.RunCLI(args, nil, &buf)
+	if err != nil {
+		t.Fatalf("RunCLI() unexpected error = %v", err)
+	}
+
+	if got := buf.String(); got != "Review the Go code for races\n" {
+		t.Errorf("Unexpected output %q", got)
+	}
+}
+
+func TestCLIFlags_TemplateFileParseErrorReportsLine(t *testing.T) {
+	t.Parallel()
+
+	templateFile := filepath.Join(t.TempDir(), "broken.tmpl")
+
+	err := os.WriteFile(templateFile, []byte("line one\nline two {{.name"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write template file: %v", err)
+	}
+
+	flags := promptbuilder.CLIFlags{TemplateFile: templateFile}
+
+	_, err = flags.ToBuildRequest()
+	if err == nil || !strings.Contains(err.Error(), templateFile+":2:") {
+		t.Errorf("Expected parse error naming %s line 2, got %v", templateFile, err)
+	}
+}
+
+func TestBuilder_LoadTemplateDirIncludesPartials(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	partials := map[string]string{
+		"header.tmpl": "You are helping {{.team}}.",
+		"review.tmpl": "{{template \"header\" .}} Focus on {{template \"focus\"}}.",
+		"focus.tmpl":  "correctness",
+	}
+
+	for name, content := range partials {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	builder := newTestBuilder()
+
+	err := builder.LoadTemplateDir(dir)
+	if err != nil {
+		t.Fatalf("LoadTemplateDir() unexpected error = %v", err)
+	}
+
+	result, err := builder.BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:       "{{template \"review\" .}} Then summarize.",
+		TemplateData: map[string]any{"team": "the platform team"},
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	want := "You are helping the platform team. Focus on correctness. Then summarize."
+	if result.Prompt.UserPrompt != want {
+		t.Errorf("Expected %q, got %q", want, result.Prompt.UserPrompt)
+	}
+
+	err = builder.LoadTemplateDir(t.TempDir())
+	if !errors.Is(err, promptbuilder.ErrNoTemplates) {
+		t.Errorf("Expected ErrNoTemplates for an empty directory, got %v", err)
+	}
+}
+
+func TestBuilder_ConcurrentTemplateLoadsAndBuilds(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "focus.tmpl"), []byte("correctness"), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	builder := newTestBuilder()
+
+	var wg sync.WaitGroup
+
+	for range 8 {
+		wg.Go(func() {
+			loadErr := builder.LoadTemplateDir(dir)
+			if loadErr != nil {
+				t.Errorf("LoadTemplateDir() unexpected error = %v", loadErr)
+			}
+		})
+
+		wg.Go(builder.Reset)
+
+		wg.Go(func() {
+			result, buildErr := builder.BuildPrompt(&promptbuilder.BuildRequest{Prompt: "Review"})
+			if buildErr != nil {
+				t.Errorf("BuildPrompt() unexpected error = %v", buildErr)
+			} else if result.Prompt.UserPrompt != "Review" {
+				t.Errorf("Expected the prompt unchanged, got %q", result.Prompt.UserPrompt)
+			}
+		})
+	}
+
+	wg.Wait()
+}
+
+func TestBuildPrompt_RendersPresetWithVars(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+
+	err := builder.AddSystemPreset("expert", "You are an expert {{.Language}} developer")
+	if err != nil {
+		t.Fatalf("AddSystemPreset() unexpected error = %v", err)
+	}
+
+	flags := promptbuilder.CLIFlags{
+		Prompt: "Review this",
+		Task:   "expert",
+		Vars:   map[string]string{"Language": "Go"},
+	}
+
+	req, err := flags.ToBuildRequest()
+	if err != nil {
+		t.Fatalf("ToBuildRequest() unexpected error = %v", err)
+	}
+
+	result, err := builder.BuildPrompt(req)
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if result.Prompt.SystemMessage != "You are an expert Go developer" {
+		t.Errorf("Expected rendered preset, got %q", result.Prompt.SystemMessage)
+	}
+
+	_, err = builder.BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:       "Review this",
+		Task:         "expert",
+		TemplateData: map[string]any{},
+	})
+	if err == nil || !strings.Contains(err.Error(), "Language") {
+		t.Errorf("Expected a missing Language variable error, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package promptbuilder
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	charsPerToken  = 4    // rough average for English text with BPE tokenizers
+	tokensPerPrice = 1000 // prices are quoted per 1K input tokens
+)
+
+// ErrUnknownModel is returned when no price is known for a model.
+var ErrUnknownModel = errors.New("unknown model")
+
+// DefaultModelPrices holds input prices in USD per 1K tokens for common models.
+// Prices change over time; pass overrides to EstimateCost to keep them current.
+var DefaultModelPrices = map[string]float64{
+	"gpt-4o":            0.0025,
+	"gpt-4o-mini":       0.00015,
+	"gpt-4-turbo":       0.01,
+	"claude-3-5-sonnet": 0.003,
+	"claude-3-haiku":    0.00025,
+	"gemini-1.5-pro":    0.00125,
+	"gemini-1.5-flash":  0.000075,
+}
+
+// Tokenizer counts the tokens a model would see in text. Plug in a real
+// tokenizer with Builder.SetTokenizer for exact counts.
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// HeuristicTokenizer is the default Tokenizer. It estimates tokens with
+// EstimateTokens.
+type HeuristicTokenizer struct{}
+
+// CountTokens returns EstimateTokens(text).
+func (HeuristicTokenizer) CountTokens(text string) int {
+	return EstimateTokens(text)
+}
+
+// EstimateTokens returns a heuristic token count for text, assuming roughly
+// four characters per token.
+func EstimateTokens(text string) int {
+	chars := utf8.RuneCountInString(text)
+
+	return (chars + charsPerToken - 1) / charsPerToken
+}
+
+// TokenEstimate returns the estimated number of input tokens in the prompt,
+// counted by the tokenizer of the builder that produced the result.
+func (r *BuildResult) TokenEstimate() int {
+	return r.countTokens(r.Prompt.String())
+}
+
+// countTokens counts tokens with the result's tokenizer, falling back to the
+// heuristic for results not produced by a builder.
+func (r *BuildResult) countTokens(text string) int {
+	if r.tokenizer == nil {
+		return EstimateTokens(text)
+	}
+
+	return r.tokenizer.CountTokens(text)
+}
+
+// TokenBreakdown returns the estimated tokens of each section of the prompt,
+// keyed by section name (see AllSections). Empty sections other than the user
+// prompt are omitted. A section's label and the separator before it count
+// toward the section, so the counts sum to TokenEstimate.
+func (r *BuildResult) TokenBreakdown() map[string]int {
+	breakdown := make(map[string]int)
+
+	var rendered strings.Builder
+
+	previous := 0
+
+	for index, section := range r.Prompt.Sections() {
+		if index > 0 {
+			rendered.WriteString(r.Prompt.separator())
+		}
+
+		rendered.WriteString(section.text())
+
+		total := r.countTokens(rendered.String())
+		breakdown[section.Name] = total - previous
+		previous = total
+	}
+
+	return breakdown
+}
+
+// EstimateCost returns the estimated input cost of the prompt for a model. The
+// price per 1K tokens is looked up in prices first and then in
+// DefaultModelPrices.
+func (r *BuildResult) EstimateCost(model string, prices map[string]float64) (float64, error) {
+	table := maps.Clone(DefaultModelPrices)
+	maps.Copy(table, prices)
+
+	price, ok := table[model]
+	if !ok {
+		return 0, fmt.Errorf("%w: no price for %s", ErrUnknownModel, model)
+	}
+
+	return float64(r.TokenEstimate()) / tokensPerPrice * price, nil
+}
@@ -0,0 +1,142 @@
+package promptbuilder_test
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]int{
+		"":          0,
+		"abc":       1,
+		"abcd":      1,
+		"abcde":     2,
+		"héllo wör": 3,
+	}
+
+	for text, want := range tests {
+		if got := promptbuilder.EstimateTokens(text); got != want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestBuildResult_EstimateCost(t *testing.T) {
+	t.Parallel()
+
+	result := &promptbuilder.BuildResult{
+		Prompt: &promptbuilder.Prompt{UserPrompt: strings.Repeat("a", 4000)},
+		Error:  nil,
+	}
+
+	if tokens := result.TokenEstimate(); tokens != 1000 {
+		t.Fatalf("Expected 1000 tokens, got %d", tokens)
+	}
+
+	cost, err := result.EstimateCost("custom-model", map[string]float64{"custom-model": 0.02})
+	if err != nil {
+		t.Fatalf("EstimateCost() unexpected error = %v", err)
+	}
+
+	if math.Abs(cost-0.02) > 1e-9 {
+		t.Errorf("Expected cost 0.02, got %f", cost)
+	}
+
+	cost, err = result.EstimateCost("gpt-4-turbo", nil)
+	if err != nil {
+		t.Fatalf("EstimateCost() unexpected error = %v", err)
+	}
+
+	if math.Abs(cost-0.01) > 1e-9 {
+		t.Errorf("Expected built-in cost 0.01, got %f", cost)
+	}
+
+	_, err = result.EstimateCost("no-such-model", nil)
+	if !errors.Is(err, promptbuilder.ErrUnknownModel) {
+		t.Errorf("Expected ErrUnknownModel, got %v", err)
+	}
+}
+
+func TestBuildResult_TokenBreakdown(t *testing.T) {
+	t.Parallel()
+
+	prompts := []*promptbuilder.Prompt{
+		{UserPrompt: "Review"},
+		{
+			SystemMessage: "You are a careful reviewer.",
+			Guidelines:    "Be brief.",
+			FileContent:   "BEGIN main.go\n" + strings.Repeat("x", 401) + "\nEND main.go",
+			UserPrompt:    "Find the bug.",
+		},
+		{SystemMessage: "abc", UserPrompt: "d", Separator: "\n---\n", LabelSystem: true},
+	}
+
+	for _, prompt := range prompts {
+		result := &promptbuilder.BuildResult{Prompt: prompt, Error: nil}
+		breakdown := result.TokenBreakdown()
+
+		sum := 0
+		for _, tokens := range breakdown {
+			sum += tokens
+		}
+
+		if total := result.TokenEstimate(); sum != total {
+			t.Errorf("Breakdown %v sums to %d, want total %d", breakdown, sum, total)
+		}
+
+		if len(breakdown) != len(prompt.Sections()) {
+			t.Errorf("Expected one entry per section, got %v", breakdown)
+		}
+	}
+
+	result := &promptbuilder.BuildResult{Prompt: prompts[1], Error: nil}
+	if breakdown := result.TokenBreakdown(); breakdown["files"] <= breakdown["user"] {
+		t.Errorf("Expected file content to dominate, got %v", breakdown)
+	}
+}
+
+// wordTokenizer counts whitespace separated words.
+type wordTokenizer struct{}
+
+func (wordTokenizer) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestBuilder_SetTokenizer(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+	builder.SetTokenizer(wordTokenizer{})
+
+	req := &promptbuilder.BuildRequest{Prompt: "one two three four five six seven eight", SystemMessage: "Be terse."}
+
+	result, err := builder.BuildPrompt(req)
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if got := result.TokenEstimate(); got != 10 {
+		t.Errorf("Expected the injected tokenizer to count 10 words, got %d", got)
+	}
+
+	if breakdown := result.TokenBreakdown(); breakdown["system"] != 2 || breakdown["user"] != 8 {
+		t.Errorf("Expected the breakdown to use the tokenizer, got %v", breakdown)
+	}
+
+	builder.SetTokenizer(nil)
+
+	result, err = builder.BuildPrompt(req)
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	if got, want := result.TokenEstimate(), promptbuilder.EstimateTokens(result.Prompt.String()); got != want {
+		t.Errorf("Expected the default heuristic after SetTokenizer(nil), got %d want %d", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+package promptbuilder
+
+import (
+	"sort"
+	"strings"
+)
+
+// truncationMarker ends every section shortened by TruncateTo.
+const truncationMarker = "[truncated]"
+
+// DefaultTruncationOrder is the order in which TruncateTo shortens sections:
+// file content first, then guidelines, the manifest, and the system context.
+// The system message, image, and user prompt are never shortened.
+var DefaultTruncationOrder = []string{SectionFiles, SectionGuidelines, SectionManifest, SectionContext}
+
+// TruncateTo returns a copy of the prompt that fits in maxTokens, shortening
+// sections in DefaultTruncationOrder. See TruncateToOrder.
+func (p *Prompt) TruncateTo(maxTokens int, tokenizer Tokenizer) *Prompt {
+	return p.TruncateToOrder(maxTokens, tokenizer, DefaultTruncationOrder)
+}
+
+// TruncateTo returns a copy of the result's prompt that fits in maxTokens as
+// counted by the tokenizer of the builder that produced the result.
+func (r *BuildResult) TruncateTo(maxTokens int) *Prompt {
+	return r.Prompt.TruncateTo(maxTokens, r.tokenizer)
+}
+
+// TruncateToOrder returns a copy of the prompt that fits in maxTokens by
+// shortening the named sections in order, moving on to the next section only
+// when the previous one has been cut to nothing. Sections are cut at a line
+// boundary and end with a "[truncated]" line; an open BEGIN/END block in the
+// file content is closed so the result still validates. Sections missing from
+// order are kept whole, so the result can still exceed maxTokens.
+//
+// Tokens are counted with tokenizer; a nil tokenizer uses HeuristicTokenizer.
+func (p *Prompt) TruncateToOrder(maxTokens int, tokenizer Tokenizer, order []string) *Prompt {
+	if tokenizer == nil {
+		tokenizer = HeuristicTokenizer{}
+	}
+
+	truncated := *p
+	fields := truncated.sectionFields()
+
+	for _, name := range order {
+		if tokenizer.CountTokens(truncated.String()) <= maxTokens {
+			break
+		}
+
+		canonical, err := canonicalSection(name)
+		if err != nil || fields[canonical] == nil || *fields[canonical] == "" {
+			continue
+		}
+
+		field := fields[canonical]
+		original := []rune(*field)
+
+		// Find the longest prefix that fits. If even an empty prefix does
+		// not, keep only the marker and move on to the next section.
+		fits := func(keep int) bool {
+			*field = truncateSection(canonical, original, keep)
+
+			return tokenizer.CountTokens(truncated.String()) <= maxTokens
+		}
+
+		keep := sort.Search(len(original), func(keep int) bool { return !fits(keep) }) - 1
+		*field = truncateSection(canonical, original, max(keep, 0))
+	}
+
+	return &truncated
+}
+
+// truncateSection keeps at most keep runes of content, cut back to the last
+// complete line, and appends the truncation marker.
+func truncateSection(name string, content []rune, keep int) string {
+	if keep >= len(content) {
+		return string(content)
+	}
+
+	kept := string(content[:keep])
+
+	end := strings.LastIndexByte(kept, '\n')
+	if end < 0 {
+		end = 0
+	}
+
+	kept = kept[:end]
+
+	var builder strings.Builder
+
+	if kept != "" {
+		builder.WriteString(kept + "\n")
+	}
+
+	builder.WriteString(truncationMarker)
+
+	if name == SectionFiles {
+		builder.WriteString(closeFences(kept))
+	}
+
+	return builder.String()
+}
+
+// closeFences returns the lines that close a BEGIN/END block, and any code
+// fence inside it, left open at the end of content.
+func closeFences(content string) string {
+	open := ""
+	codeFence := false
+
+	for line := range strings.Lines(content) {
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case open != "":
+			if line == "END "+open {
+				open = ""
+				codeFence = false
+			} else if strings.HasPrefix(line, "```") {
+				codeFence = !codeFence
+			}
+		case strings.HasPrefix(line, "BEGIN "):
+			open = fenceName(line)
+		}
+	}
+
+	if open == "" {
+		return ""
+	}
+
+	var closing strings.Builder
+
+	if codeFence {
+		closing.WriteString("\n```")
+	}
+
+	closing.WriteString("\nEND " + open)
+
+	return closing.String()
+}
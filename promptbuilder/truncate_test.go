@@ -0,0 +1,127 @@
+package promptbuilder_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/book-expert/prompt-builder/promptbuilder"
+)
+
+func TestPrompt_TruncateToKeepsUserPrompt(t *testing.T) {
+	t.Parallel()
+
+	content := strings.Repeat("line of source code\n", 500)
+	prompt := &promptbuilder.Prompt{
+		SystemMessage: "You are a reviewer.",
+		Guidelines:    "Be brief.",
+		UserPrompt:    "Review this file.",
+		FileContent:   "BEGIN main.go\n```go\n" + content + "```\nEND main.go",
+	}
+
+	const budget = 200
+
+	truncated := prompt.TruncateTo(budget, nil)
+
+	if got := promptbuilder.EstimateTokens(truncated.String()); got > budget {
+		t.Errorf("Expected at most %d tokens, got %d", budget, got)
+	}
+
+	if truncated.UserPrompt != prompt.UserPrompt || truncated.SystemMessage != prompt.SystemMessage {
+		t.Errorf("Expected user prompt and system message to be preserved, got %+v", truncated)
+	}
+
+	if truncated.Guidelines != prompt.Guidelines {
+		t.Errorf("Expected guidelines to survive when trimming files is enough, got %q", truncated.Guidelines)
+	}
+
+	if !strings.Contains(truncated.FileContent, "[truncated]") ||
+		!strings.HasPrefix(truncated.FileContent, "BEGIN main.go\n```go\nline of source code\n") {
+		t.Errorf("Expected marked, partially kept file content, got %q", truncated.FileContent)
+	}
+
+	err := truncated.Validate()
+	if err != nil {
+		t.Errorf("Expected truncated prompt to validate, got %v", err)
+	}
+
+	if !strings.HasSuffix(prompt.FileContent, "END main.go") {
+		t.Error("Expected the original prompt to be unchanged")
+	}
+}
+
+func TestPrompt_TruncateToOrder(t *testing.T) {
+	t.Parallel()
+
+	prompt := &promptbuilder.Prompt{
+		Guidelines:  strings.Repeat("- a guideline\n", 100),
+		UserPrompt:  "Question?",
+		FileContent: "BEGIN notes.txt\n" + strings.Repeat("data\n", 100) + "END notes.txt",
+	}
+
+	tests := []struct {
+		name           string
+		order          []string
+		wantGuidelines bool // whether guidelines stay whole
+		wantFiles      bool // whether file content stays whole
+		wantFits       bool
+	}{
+		{name: "guidelines first", order: []string{"guidelines", "files"}, wantGuidelines: false, wantFiles: true, wantFits: true},
+		{name: "both needed", order: []string{"files", "guidelines"}, wantGuidelines: false, wantFiles: false, wantFits: true},
+		{name: "nothing truncatable", order: nil, wantGuidelines: true, wantFiles: true, wantFits: false},
+	}
+
+	const budget = 200
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			truncated := prompt.TruncateToOrder(budget, promptbuilder.HeuristicTokenizer{}, test.order)
+
+			if got := truncated.Guidelines == prompt.Guidelines; got != test.wantGuidelines {
+				t.Errorf("Guidelines whole = %v, want %v", got, test.wantGuidelines)
+			}
+
+			if got := truncated.FileContent == prompt.FileContent; got != test.wantFiles {
+				t.Errorf("File content whole = %v, want %v", got, test.wantFiles)
+			}
+
+			if got := promptbuilder.EstimateTokens(truncated.String()) <= budget; got != test.wantFits {
+				t.Errorf("Fits = %v, want %v", got, test.wantFits)
+			}
+
+			if truncated.UserPrompt != prompt.UserPrompt {
+				t.Errorf("Expected user prompt preserved, got %q", truncated.UserPrompt)
+			}
+		})
+	}
+}
+
+func TestBuildResult_TruncateToUsesTokenizer(t *testing.T) {
+	t.Parallel()
+
+	builder := newTestBuilder()
+	builder.SetTokenizer(wordTokenizer{})
+
+	result, err := builder.BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:     "Review this.",
+		Guidelines: strings.Repeat("guideline ", 100),
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	// The guidelines are about 250 heuristic tokens but only 100 words, so a word
+	// budget of 150 keeps them whole.
+	const budget = 150
+
+	truncated := result.TruncateTo(budget)
+	if truncated.Guidelines != result.Prompt.Guidelines {
+		t.Errorf("Expected guidelines to fit the word budget, got %q", truncated.Guidelines)
+	}
+
+	truncated = result.Prompt.TruncateTo(budget, nil)
+	if truncated.Guidelines == result.Prompt.Guidelines {
+		t.Error("Expected the heuristic tokenizer to truncate the guidelines")
+	}
+}
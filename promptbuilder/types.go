@@ -1,10 +1,18 @@
 package promptbuilder
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Static errors for validation.
@@ -12,18 +20,131 @@ var (
 	ErrPromptRequired      = errors.New("prompt is required")
 	ErrFilePathRequired    = errors.New("file path is required")
 	ErrFileContentRequired = errors.New("file content is required")
+	ErrNoImageInput        = errors.New("no input available to read image from")
+	ErrUnknownSection      = errors.New("unknown prompt section")
+	ErrUnbalancedFences    = errors.New("file content has unbalanced BEGIN/END fences")
+	ErrImageAndImageHex    = errors.New("image and hex image cannot be combined")
+	ErrNoPromptInput       = errors.New("no input available to read prompts from")
+	ErrPromptAndSplitOn    = errors.New("prompt and split-on input cannot be combined")
+	ErrInvalidNote         = errors.New("file note must be in path=text form")
+	ErrEditAndStdinImage   = errors.New("edit and an image from standard input cannot be combined")
+	ErrNegativeMaxIncluded = errors.New("max included files cannot be negative")
+	ErrImageRefConflict    = errors.New("image reference cannot be combined with image data or files")
 )
 
+// stdinImage is the -img value that reads raw image bytes from standard input.
+const stdinImage = "-"
+
 // BuildRequest represents a request to build a prompt. This struct is the main
 // data structure that is passed to the prompt builder to construct a prompt.
 type BuildRequest struct {
-	Prompt        string `json:"prompt"`
-	File          string `json:"file,omitempty"`
-	Task          string `json:"task,omitempty"`
-	SystemMessage string `json:"systemMessage,omitempty"`
-	Guidelines    string `json:"guidelines,omitempty"`
-	Image         []byte `json:"image,omitempty"`
-	OutputFormat  string `json:"outputFormat,omitempty"`
+	Prompt        string   `json:"prompt"`
+	File          string   `json:"file,omitempty"`
+	Files         []string `json:"files,omitempty"`
+	Task          string   `json:"task,omitempty"`
+	SystemMessage string   `json:"systemMessage,omitempty"`
+	Guidelines    string   `json:"guidelines,omitempty"`
+	GuidelineList []string `json:"guidelineList,omitempty"`
+	Image         []byte   `json:"image,omitempty"`
+	ImagePath     string   `json:"imagePath,omitempty"`
+	Manifest      bool     `json:"manifest,omitempty"`
+	OutputFormat  string   `json:"outputFormat,omitempty"`
+	WithContext   bool     `json:"withContext,omitempty"`
+	PromptPrefix  string   `json:"promptPrefix,omitempty"`
+	PromptSuffix  string   `json:"promptSuffix,omitempty"`
+	Normalize     bool     `json:"normalize,omitempty"`
+	SortFilesBy   string   `json:"sortFilesBy,omitempty"`
+	SortReverse   bool     `json:"sortReverse,omitempty"`
+
+	// MaxIncluded keeps only the first MaxIncluded files after sorting, with a
+	// warning naming the rest. Paths are sorted before any file is read, so
+	// omitted files are never read. The expansion is still limited by
+	// MaxFiles. Zero includes every file.
+	MaxIncluded int `json:"maxIncluded,omitempty"`
+
+	// LabelSystem labels the system message "System:" in rendered output.
+	LabelSystem bool `json:"labelSystem,omitempty"`
+
+	// TableOfContents lists the included files with their line counts before
+	// the file content when there is more than one file.
+	TableOfContents bool `json:"tableOfContents,omitempty"`
+
+	// ImageDetail is the OpenAI vision detail level of the attached image in
+	// ToChatMessages: ImageDetailLow, ImageDetailHigh, or ImageDetailAuto.
+	ImageDetail string `json:"imageDetail,omitempty"`
+
+	// NumberedGuidelines renders several guidelines as a numbered list of
+	// steps instead of bullets.
+	NumberedGuidelines bool `json:"numberedGuidelines,omitempty"`
+
+	// Separator replaces the blank line between sections in rendered output.
+	Separator string `json:"separator,omitempty"`
+
+	// KeepWhitespace disables trimming trailing whitespace from the system
+	// message, guidelines, and user prompt.
+	KeepWhitespace bool `json:"keepWhitespace,omitempty"`
+
+	// FileRole selects the chat message that receives file content in
+	// ToChatMessages: RoleUser (the default) or RoleSystem.
+	FileRole string `json:"fileRole,omitempty"`
+
+	// JSONFiles makes JSON output list each file's content in the "files"
+	// array instead of concatenating it into file_content.
+	JSONFiles bool `json:"jsonFiles,omitempty"`
+
+	// TemplateData, when set, renders Prompt, Guidelines, and the selected
+	// preset as text/template templates with this data before the prompt is
+	// assembled.
+	TemplateData map[string]any `json:"templateData,omitempty"`
+
+	// Functions includes only the named functions of Go files, each given as
+	// "path.go:Name" or "path.go:Type.Method", after the files.
+	Functions []string `json:"functions,omitempty"`
+
+	// FileNotes maps file paths to one-line notes for the model, shown in
+	// the file's fence header as "BEGIN path — note".
+	FileNotes map[string]string `json:"fileNotes,omitempty"`
+
+	// Commands are run and their standard output is included as fenced text
+	// blocks after the files. Without AllowShell they run without a shell
+	// and may not contain shell metacharacters, and the program of each must
+	// be in AllowedCommands; with it each whole command line must be. None of
+	// these fields is read from JSON, so batch files cannot run commands.
+	Commands        []string `json:"-"`
+	AllowedCommands []string `json:"-"`
+	AllowShell      bool     `json:"-"`
+}
+
+// promptOnly reports whether the request sets nothing that affects the built
+// prompt except Prompt. OutputFormat and the fields that only apply to files
+// are ignored because they have no effect without files. Every other field
+// counts, so new fields take the general path until they are listed here.
+func (r *BuildRequest) promptOnly() bool {
+	rest := *r
+	rest.Prompt = ""
+	rest.OutputFormat = ""
+	rest.Manifest = false
+	rest.SortFilesBy = ""
+	rest.SortReverse = false
+	rest.MaxIncluded = 0
+	rest.TableOfContents = false
+
+	return reflect.ValueOf(&rest).Elem().IsZero()
+}
+
+// fileNote returns the note for path from FileNotes, comparing cleaned paths.
+func (r *BuildRequest) fileNote(path string) string {
+	if note, ok := r.FileNotes[path]; ok {
+		return note
+	}
+
+	for notePath, note := range r.FileNotes {
+		if filepath.Clean(notePath) == filepath.Clean(path) {
+			return note
+		}
+	}
+
+	return ""
 }
 
 // Validate checks if the build request is valid.
@@ -32,45 +153,368 @@ func (r *BuildRequest) Validate() error {
 		return ErrPromptRequired
 	}
 
-	return nil
+	if r.FileRole != "" && r.FileRole != RoleUser && r.FileRole != RoleSystem {
+		return fmt.Errorf("%w: %s", ErrUnknownFileRole, r.FileRole)
+	}
+
+	if r.MaxIncluded < 0 {
+		return fmt.Errorf("%w: %d", ErrNegativeMaxIncluded, r.MaxIncluded)
+	}
+
+	// Only one of files, an image reference, and image data is included.
+	if r.ImagePath != "" && (len(r.Image) > 0 || len(r.FilePaths()) > 0 || len(r.Functions) > 0) {
+		return ErrImageRefConflict
+	}
+
+	return ValidateImageDetail(r.ImageDetail)
+}
+
+// FilePaths returns every file referenced by the request, starting with File
+// followed by any additional Files.
+func (r *BuildRequest) FilePaths() []string {
+	paths := make([]string, 0, len(r.Files)+1)
+
+	if r.File != "" {
+		paths = append(paths, r.File)
+	}
+
+	for _, path := range r.Files {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// AllGuidelines returns every guideline in the request, starting with
+// Guidelines followed by any entries of GuidelineList.
+func (r *BuildRequest) AllGuidelines() []string {
+	guidelines := make([]string, 0, len(r.GuidelineList)+1)
+
+	if r.Guidelines != "" {
+		guidelines = append(guidelines, r.Guidelines)
+	}
+
+	for _, guideline := range r.GuidelineList {
+		if guideline != "" {
+			guidelines = append(guidelines, guideline)
+		}
+	}
+
+	return guidelines
 }
 
 // Prompt represents the assembled prompt. This struct is the output of the prompt
 // builder and contains all the components of the prompt.
 type Prompt struct {
+	SystemContext string `json:"systemContext,omitempty"`
 	SystemMessage string `json:"systemMessage,omitempty"`
 	UserPrompt    string `json:"userPrompt"`
 	FileContent   string `json:"fileContent,omitempty"`
 	Guidelines    string `json:"guidelines,omitempty"`
+
+	// ImagePath references an image by path instead of inlining it as base64.
+	ImagePath string `json:"imagePath,omitempty"`
+
+	// Manifest lists the SHA-256 digest and size of each included file. It is
+	// rendered after the user prompt.
+	Manifest string `json:"manifest,omitempty"`
+
+	// TableOfContents numbers the included files with their line counts. It
+	// is rendered before the file content.
+	TableOfContents string `json:"tableOfContents,omitempty"`
+
+	// LabelSystem renders the system message under a "System:" label like the
+	// other sections. It affects rendering only and is not serialized.
+	LabelSystem bool `json:"-"`
+
+	// Separator replaces the blank line between sections in String. It
+	// affects rendering only and is not serialized.
+	Separator string `json:"-"`
+
+	// ImageURL is the attached image as a data URI. ToChatMessages adds it
+	// as an image_url part, which replaces its fenced copy in FileContent,
+	// imageFence, when the messages are marshaled. It is not serialized, as
+	// FileContent already carries it.
+	ImageURL   string `json:"-"`
+	imageFence string
 }
 
-// String returns the formatted prompt as a string.
+// defaultSeparator is the blank line String places between sections.
+const defaultSeparator = "\n\n"
+
+// String returns the formatted prompt as a string, with sections separated by
+// Separator or, when it is empty, a blank line.
 func (p *Prompt) String() string {
+	return p.StringWithSeparator(p.separator())
+}
+
+// separator returns the text String places between sections.
+func (p *Prompt) separator() string {
+	if p.Separator == "" {
+		return defaultSeparator
+	}
+
+	return p.Separator
+}
+
+// StringWithSeparator returns the formatted prompt with sections joined by
+// sep. A section's label stays separated from its content by a blank line.
+func (p *Prompt) StringWithSeparator(sep string) string {
 	var parts []string
 
-	if p.SystemMessage != "" {
-		parts = append(parts, p.SystemMessage)
+	for _, section := range p.Sections() {
+		parts = append(parts, section.text())
 	}
 
-	if p.Guidelines != "" {
-		parts = append(parts, "Guidelines:", p.Guidelines)
+	return strings.Join(parts, sep)
+}
+
+// Validate checks that the assembled prompt has a user prompt and that its file
+// content consists of complete BEGIN/END fenced blocks.
+func (p *Prompt) Validate() error {
+	if strings.TrimSpace(p.UserPrompt) == "" {
+		return ErrPromptRequired
 	}
 
-	if p.FileContent != "" {
-		parts = append(parts, "File content:", p.FileContent)
+	return validateFences(p.FileContent)
+}
+
+// validateFences checks that every line outside a fenced block opens one and
+// that every opened block is closed by the END marker of the same name. Lines
+// inside a block are file content and are not interpreted. The marker left by
+// TruncateTo is allowed between blocks.
+func validateFences(content string) error {
+	open := ""
+
+	for line := range strings.Lines(content) {
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case open != "":
+			if line == "END "+open {
+				open = ""
+			}
+		case strings.HasPrefix(line, "BEGIN "):
+			open = fenceName(line)
+		case line == truncationMarker:
+			// Left by TruncateTo when it cut between blocks.
+		case strings.TrimSpace(line) != "":
+			return fmt.Errorf("%w: unexpected line outside a fence: %q", ErrUnbalancedFences, line)
+		}
 	}
 
-	parts = append(parts, p.UserPrompt)
+	if open != "" {
+		return fmt.Errorf("%w: missing END %s", ErrUnbalancedFences, open)
+	}
 
-	return strings.Join(parts, "\n\n")
+	return nil
 }
 
+// Sections returns the non-empty sections of the prompt in render order. The
+// user prompt section is always present; only the manifest follows it.
+func (p *Prompt) Sections() []Section {
+	candidates := []Section{
+		{Name: SectionContext, Label: "System context:", Content: p.SystemContext},
+		{Name: SectionSystem, Label: p.systemLabel(), Content: p.SystemMessage},
+		{Name: SectionGuidelines, Label: "Guidelines:", Content: p.Guidelines},
+		{Name: SectionContents, Label: "Contents:", Content: p.TableOfContents},
+		{Name: SectionFiles, Label: "File content:", Content: p.FileContent},
+		{Name: SectionImage, Label: "Image:", Content: p.ImagePath},
+	}
+
+	sections := make([]Section, 0, len(candidates)+1)
+
+	for _, section := range candidates {
+		if section.Content != "" {
+			sections = append(sections, section)
+		}
+	}
+
+	sections = append(sections, Section{Name: SectionUser, Label: "", Content: p.UserPrompt})
+
+	if p.Manifest != "" {
+		sections = append(sections, Section{Name: SectionManifest, Label: "Manifest:", Content: p.Manifest})
+	}
+
+	return sections
+}
+
+// systemLabel returns the label of the system section.
+func (p *Prompt) systemLabel() string {
+	if p.LabelSystem {
+		return "System:"
+	}
+
+	return ""
+}
+
+// FilterSections returns a copy of the prompt that keeps only the sections named
+// in only (when non-empty) and drops the sections named in exclude. Section
+// names are those of the Section* constants; "file" is accepted for "files".
+// Dropping the image section also drops an attached image's ImageURL and its
+// fenced copy in the file content.
+func (p *Prompt) FilterSections(only, exclude []string) (*Prompt, error) {
+	keep := make(map[string]bool)
+
+	for _, name := range AllSections() {
+		keep[name] = len(only) == 0
+	}
+
+	for _, name := range only {
+		canonical, err := canonicalSection(name)
+		if err != nil {
+			return nil, err
+		}
+
+		keep[canonical] = true
+	}
+
+	for _, name := range exclude {
+		canonical, err := canonicalSection(name)
+		if err != nil {
+			return nil, err
+		}
+
+		keep[canonical] = false
+	}
+
+	filtered := *p
+
+	for name, field := range filtered.sectionFields() {
+		if !keep[name] {
+			*field = ""
+		}
+	}
+
+	// An attached image is also carried as ImageURL and fenced in the file
+	// content; both go with the image section.
+	if !keep[SectionImage] {
+		if filtered.imageFence != "" {
+			filtered.FileContent = strings.Trim(strings.Replace(filtered.FileContent, filtered.imageFence, "", 1), "\n")
+		}
+
+		filtered.ImageURL = ""
+		filtered.imageFence = ""
+	}
+
+	return &filtered, nil
+}
+
+// sectionFields maps each section name to the prompt field holding it.
+func (p *Prompt) sectionFields() map[string]*string {
+	return map[string]*string{
+		SectionContext:    &p.SystemContext,
+		SectionSystem:     &p.SystemMessage,
+		SectionGuidelines: &p.Guidelines,
+		SectionContents:   &p.TableOfContents,
+		SectionFiles:      &p.FileContent,
+		SectionImage:      &p.ImagePath,
+		SectionUser:       &p.UserPrompt,
+		SectionManifest:   &p.Manifest,
+	}
+}
+
+// AllSections returns the names of every prompt section in render order.
+func AllSections() []string {
+	return []string{
+		SectionContext, SectionSystem, SectionGuidelines, SectionContents, SectionFiles,
+		SectionImage, SectionUser, SectionManifest,
+	}
+}
+
+// canonicalSection resolves a user supplied section name.
+func canonicalSection(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "file" {
+		return SectionFiles, nil
+	}
+
+	for _, section := range AllSections() {
+		if name == section {
+			return section, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s (valid: %s)", ErrUnknownSection, name, strings.Join(AllSections(), ", "))
+}
+
+// Wrapped returns a copy of the prompt with every text section hard-wrapped at
+// width columns using WrapText. The image path and manifest are left intact so
+// paths and digests stay on one line.
+func (p *Prompt) Wrapped(width int) *Prompt {
+	wrapped := *p
+	wrapped.SystemContext = WrapText(p.SystemContext, width)
+	wrapped.SystemMessage = WrapText(p.SystemMessage, width)
+	wrapped.UserPrompt = WrapText(p.UserPrompt, width)
+	wrapped.FileContent = WrapText(p.FileContent, width)
+	wrapped.Guidelines = WrapText(p.Guidelines, width)
+
+	return &wrapped
+}
+
+// Hash returns a stable SHA-256 digest of the prompt. The digest is computed
+// over the canonical JSON serialization of every field, so prompts with the
+// same fields hash equally no matter how they were constructed.
+func (p *Prompt) Hash() string {
+	// Marshalling cannot fail because Prompt only holds plain values.
+	data, _ := json.Marshal(p)
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Section is one labelled part of an assembled prompt.
+type Section struct {
+	Name    string `json:"name"`
+	Label   string `json:"label,omitempty"`
+	Content string `json:"content"`
+}
+
+// text returns the section as rendered by String: its label, if any, a blank
+// line, and its content.
+func (s Section) text() string {
+	if s.Label == "" {
+		return s.Content
+	}
+
+	return s.Label + defaultSeparator + s.Content
+}
+
+// Names of the prompt sections returned by Prompt.Sections.
+const (
+	SectionContext    = "context"
+	SectionSystem     = "system"
+	SectionGuidelines = "guidelines"
+	SectionContents   = "toc"
+	SectionFiles      = "files"
+	SectionImage      = "image"
+	SectionUser       = "user"
+	SectionManifest   = "manifest"
+)
+
 // FileContent represents file content with metadata. This struct is used to pass
 // file content and metadata between the file processor and the prompt builder.
 type FileContent struct {
-	Path    string `json:"path"`
-	Content []byte `json:"content"`
-	Size    int64  `json:"size"`
+	Path       string    `json:"path"`
+	Content    []byte    `json:"content"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"modTime,omitzero"`
+	LastCommit string    `json:"lastCommit,omitempty"`
+	Note       string    `json:"note,omitempty"`
+
+	// FrontMatter holds the top-level keys of Markdown front matter removed
+	// with FileProcessor.StripFrontMatter.
+	FrontMatter map[string]string `json:"frontMatter,omitempty"`
+
+	// sourceDigest is the SHA-256 digest of the bytes read from disk or the
+	// network, before any conversion of Content. It is zero for files not
+	// read by a FileProcessor.
+	sourceDigest [sha256.Size]byte
+
+	// warnings describe non-fatal problems met while processing the file.
+	warnings []string
 }
 
 // Validate checks if the file content is valid.
@@ -91,6 +535,9 @@ func (fc *FileContent) Validate() error {
 type SystemPreset struct {
 	Name    string `json:"name"`
 	Message string `json:"message"`
+
+	// Guidelines are used when a request selecting the preset gives none.
+	Guidelines string `json:"guidelines,omitempty"`
 }
 
 // BuildResult represents the result of building a prompt. This struct is the
@@ -98,35 +545,301 @@ type SystemPreset struct {
 type BuildResult struct {
 	Prompt *Prompt `json:"prompt"`
 	Error  error   `json:"error,omitempty"`
+
+	// Task is the preset requested for the system message, if any.
+	Task string `json:"task,omitempty"`
+	// SystemSource records where the system message came from.
+	SystemSource SystemSource `json:"systemSource,omitempty"`
+	// Files lists the files included in the prompt, in order.
+	Files []*FileContent `json:"files,omitempty"`
+	// ImageSize is the size in bytes of the attached image, if any.
+	ImageSize int `json:"imageSize,omitempty"`
+	// TotalLines is the number of file lines included in the prompt, and
+	// FileLines breaks it down by file path.
+	TotalLines int            `json:"totalLines,omitempty"`
+	FileLines  map[string]int `json:"fileLines,omitempty"`
+	// FileRole is the chat role that receives file content in ToChatMessages.
+	FileRole string `json:"fileRole,omitempty"`
+	// ImageDetail is the detail level of images in ToChatMessages.
+	ImageDetail string `json:"imageDetail,omitempty"`
+	// JSONFiles makes JSON output carry file content per file. See
+	// BuildRequest.JSONFiles.
+	JSONFiles bool `json:"jsonFiles,omitempty"`
+	// Warnings describes non-fatal problems met while building, such as
+	// skipped files.
+	Warnings []string `json:"warnings,omitempty"`
+	// SignKey, when set, signs the rendered output with SignOutput: JSON and
+	// NDJSON gain a "signature" field and other formats a final
+	// "Signature: <hex>" line. VerifyOutput checks either form.
+	SignKey []byte `json:"-"`
+	// HeadComment, when set, is rendered as a leading comment, such as
+	// "<!-- comment -->" in markdown, or as a "generated_by" field in JSON.
+	HeadComment string `json:"-"`
+	// JSONKeys selects the key naming style of JSON and NDJSON output:
+	// JSONKeysSnake (the default) or JSONKeysCamel, which matches the
+	// struct tags.
+	JSONKeys string `json:"-"`
+
+	formatters map[string]Formatter
+	tokenizer  Tokenizer
+
+	// fenceLanguage is the FenceLanguage of the builder's file processor,
+	// used for the languages of files in JSON output.
+	fenceLanguage func(path string) string
 }
 
+// Components returns the prompt's parts keyed by "system", "user", "file",
+// and "guidelines" for callers that serialize prompts themselves. Empty parts
+// are present with empty values.
+func (r *BuildResult) Components() map[string]string {
+	return map[string]string{
+		"system":     r.Prompt.SystemMessage,
+		"user":       r.Prompt.UserPrompt,
+		"file":       r.Prompt.FileContent,
+		"guidelines": r.Prompt.Guidelines,
+	}
+}
+
+// SystemSource describes where a prompt's system message came from.
+type SystemSource string
+
+// Possible system message sources.
+const (
+	SystemSourceNone   SystemSource = "none"
+	SystemSourcePreset SystemSource = "preset"
+	SystemSourceCustom SystemSource = "custom"
+)
+
 // CLIFlags represents command line interface flags for the prompt builder. This
 // struct is used to parse the command line arguments and convert them into a
 // BuildRequest.
 type CLIFlags struct {
-	Prompt        string `json:"prompt"`
-	File          string `json:"file,omitempty"`
-	Task          string `json:"task,omitempty"`
-	SystemMessage string `json:"systemMessage,omitempty"`
-	Guidelines    string `json:"guidelines,omitempty"`
-	Image         string `json:"image,omitempty"`
-	OutputFormat  string `json:"outputFormat,omitempty"`
+	Prompt             string        `json:"prompt"`
+	File               string        `json:"file,omitempty"`
+	Files              []string      `json:"files,omitempty"`
+	FilesFrom          string        `json:"filesFrom,omitempty"`
+	Task               string        `json:"task,omitempty"`
+	SystemMessage      string        `json:"systemMessage,omitempty"`
+	Guidelines         string        `json:"guidelines,omitempty"`
+	GuidelineList      []string      `json:"guidelineList,omitempty"`
+	Image              string        `json:"image,omitempty"`
+	ImageHex           string        `json:"imageHex,omitempty"`
+	ImageByRef         string        `json:"imageByRef,omitempty"`
+	Manifest           bool          `json:"manifest,omitempty"`
+	OutputFormat       string        `json:"outputFormat,omitempty"`
+	OutputFile         string        `json:"outputFile,omitempty"`
+	WithContext        bool          `json:"withContext,omitempty"`
+	PromptPrefix       string        `json:"promptPrefix,omitempty"`
+	PromptSuffix       string        `json:"promptSuffix,omitempty"`
+	DataFile           string        `json:"dataFile,omitempty"`
+	Wrap               int           `json:"wrap,omitempty"`
+	WithGit            bool          `json:"withGit,omitempty"`
+	Normalize          bool          `json:"normalize,omitempty"`
+	Explain            bool          `json:"explain,omitempty"`
+	Only               string        `json:"only,omitempty"`
+	Exclude            string        `json:"exclude,omitempty"`
+	Batch              string        `json:"batch,omitempty"`
+	AllowURLs          bool          `json:"allowUrls,omitempty"`
+	SortFilesBy        string        `json:"sortFilesBy,omitempty"`
+	SortReverse        bool          `json:"sortReverse,omitempty"`
+	MaxIncluded        int           `json:"maxIncluded,omitempty"`
+	FileSections       bool          `json:"fileSections,omitempty"`
+	TemplateFile       string        `json:"templateFile,omitempty"`
+	Publish            string        `json:"publish,omitempty"`
+	Signatures         bool          `json:"signatures,omitempty"`
+	LineNumbers        bool          `json:"lineNumbers,omitempty"`
+	CollapseBlanks     bool          `json:"collapseBlanks,omitempty"`
+	StripFrontMatter   bool          `json:"stripFrontMatter,omitempty"`
+	Timeout            time.Duration `json:"timeout,omitempty"`
+	SplitOutput        string        `json:"splitOutput,omitempty"`
+	TemplatesDir       string        `json:"templatesDir,omitempty"`
+	LabelSystem        bool          `json:"labelSystem,omitempty"`
+	Separator          string        `json:"separator,omitempty"`
+	JSONFiles          bool          `json:"jsonFiles,omitempty"`
+	SignKeyFile        string        `json:"signKeyFile,omitempty"`
+	SignKeyEnv         string        `json:"signKeyEnv,omitempty"`
+	ListLanguages      bool          `json:"listLanguages,omitempty"`
+	Breakdown          bool          `json:"tokenBreakdown,omitempty"`
+	HeadComment        bool          `json:"headComment,omitempty"`
+	WildcardTask       bool          `json:"wildcardTasks,omitempty"`
+	SplitOn            string        `json:"splitOn,omitempty"`
+	ImageDetail        string        `json:"imageDetail,omitempty"`
+	OnUnreadable       string        `json:"onUnreadable,omitempty"`
+	ChatTemplate       string        `json:"chatTemplate,omitempty"`
+	JSONKeys           string        `json:"jsonKeys,omitempty"`
+	AllowShell         bool          `json:"allowShell,omitempty"`
+	PostProcess        string        `json:"postProcess,omitempty"`
+	ShowConfig         bool          `json:"showConfig,omitempty"`
+	JSONErrors         bool          `json:"jsonErrors,omitempty"`
+	ValidateSyntax     bool          `json:"validateSyntax,omitempty"`
+	NumberedGuidelines bool          `json:"numberedGuidelines,omitempty"`
+	Clipboard          bool          `json:"clipboard,omitempty"`
+	Edit               bool          `json:"edit,omitempty"`
+	ImagesAsBase64     bool          `json:"includeBinaryAsBase64,omitempty"`
+	TOC                bool          `json:"toc,omitempty"`
+	KeepWhitespace     bool          `json:"keepWhitespace,omitempty"`
+	Encoding           string        `json:"encoding,omitempty"`
+	NATSURL            string        `json:"natsUrl,omitempty"`
+	NATSCreds          string        `json:"natsCreds,omitempty"`
+
+	// ExtraExts holds --ext extensions accepted in addition to the default
+	// allowed extensions or those of the project file.
+	ExtraExts []string `json:"extraExtensions,omitempty"`
+
+	// ExcludeGlobs holds --exclude-glob patterns applied while expanding
+	// directories and globs.
+	ExcludeGlobs []string `json:"excludeGlobs,omitempty"`
+
+	// Commands holds --cmd commands whose output is included as context.
+	Commands []string `json:"commands,omitempty"`
+
+	// AllowedCommands holds the --allow-cmd programs, or with --allow-shell
+	// command lines, that --cmd may run.
+	AllowedCommands []string `json:"allowedCommands,omitempty"`
+
+	// Functions holds --func path.go:Name references.
+	Functions []string `json:"functions,omitempty"`
+
+	// Languages maps --lang patterns to forced code fence languages.
+	Languages map[string]string `json:"languages,omitempty"`
+
+	// Vars holds --var key=value template variables. They take precedence over
+	// values loaded from DataFile.
+	Vars map[string]string `json:"vars,omitempty"`
+
+	// Notes maps --note file paths to the notes shown in their fence headers.
+	Notes map[string]string `json:"notes,omitempty"`
+
+	// NoProject skips project file discovery in RunCLI.
+	NoProject bool `json:"noProject,omitempty"`
+
+	// ProjectFile is the project file the defaults below were loaded from.
+	// Extensions replaces the default allowed extensions, and Presets adds
+	// system presets.
+	ProjectFile string            `json:"projectFile,omitempty"`
+	Extensions  []string          `json:"extensions,omitempty"`
+	Presets     map[string]string `json:"presets,omitempty"`
 }
 
 // Validate checks if the CLI flags are valid.
 func (f *CLIFlags) Validate() error {
-	if strings.TrimSpace(f.Prompt) == "" {
+	if _, ok := chatTemplates[f.ChatTemplate]; f.ChatTemplate != "" && !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownChatTemplate, f.ChatTemplate)
+	}
+
+	if f.JSONKeys != "" && f.JSONKeys != JSONKeysSnake && f.JSONKeys != JSONKeysCamel {
+		return fmt.Errorf("%w: %s", ErrUnknownJSONKeys, f.JSONKeys)
+	}
+
+	err := ValidateImageDetail(f.ImageDetail)
+	if err != nil {
+		return err
+	}
+
+	_, err = ParseUnreadablePolicy(f.OnUnreadable)
+	if err != nil {
+		return err
+	}
+
+	if f.SplitOn != "" {
+		if f.Prompt != "" || f.Edit || f.TemplateFile != "" || f.Image == stdinImage {
+			return ErrPromptAndSplitOn
+		}
+	} else if strings.TrimSpace(f.Prompt) == "" && !f.Edit && f.Batch == "" && f.TemplateFile == "" &&
+		!f.ListLanguages && !f.ShowConfig {
 		return ErrPromptRequired
 	}
 
-	return nil
+	if (f.Prompt != "" || f.Edit) && f.TemplateFile != "" {
+		return ErrPromptAndTemplate
+	}
+
+	if f.MaxIncluded < 0 {
+		return fmt.Errorf("%w: %d", ErrNegativeMaxIncluded, f.MaxIncluded)
+	}
+
+	// The editor runs on the terminal, so it would read the image bytes.
+	if f.Edit && f.Image == stdinImage {
+		return ErrEditAndStdinImage
+	}
+
+	if f.Image != "" && f.ImageHex != "" {
+		return ErrImageAndImageHex
+	}
+
+	if f.ImageByRef != "" && (f.Image != "" || f.ImageHex != "") {
+		return ErrImageRefConflict
+	}
+
+	_, err = outputEncoding(f.Encoding)
+	if err != nil {
+		return err
+	}
+
+	if f.Publish != "" {
+		err = ValidateSubject(f.Publish)
+		if err != nil {
+			return err
+		}
+
+		err = validateNATSURL(f.NATSURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, command := range f.Commands {
+		err = checkCommandAllowed(command, f.AllowedCommands, f.AllowShell)
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.PostProcess != "" {
+		err = validateCommand(f.PostProcess, f.AllowShell)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ValidateFormat(f.OutputFormat)
+}
+
+// unescapeSeparator interprets Go backslash escapes such as \n in a separator
+// given on the command line. Separators that are not valid escaped strings are
+// used as given.
+func unescapeSeparator(sep string) string {
+	unquoted, err := strconv.Unquote(`"` + sep + `"`)
+	if err != nil {
+		return sep
+	}
+
+	return unquoted
 }
 
 // ToBuildRequest converts CLI flags to a BuildRequest.
 func (f *CLIFlags) ToBuildRequest() (*BuildRequest, error) {
+	return f.ToBuildRequestWithInput(nil)
+}
+
+// ToBuildRequestWithInput converts CLI flags to a BuildRequest, reading raw
+// image bytes from input when the image flag is "-". A hex-encoded image is
+// decoded from ImageHex instead.
+func (f *CLIFlags) ToBuildRequestWithInput(input io.Reader) (*BuildRequest, error) {
 	var imageData []byte
 
-	if f.Image != "" {
+	if f.Image == stdinImage {
+		if input == nil {
+			return nil, ErrNoImageInput
+		}
+
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image from input: %w", err)
+		}
+
+		imageData = data
+	} else if f.Image != "" {
 		decoded, err := base64.StdEncoding.DecodeString(f.Image)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode image: %w", err)
@@ -135,13 +848,95 @@ func (f *CLIFlags) ToBuildRequest() (*BuildRequest, error) {
 		imageData = decoded
 	}
 
+	if f.ImageHex != "" {
+		if imageData != nil {
+			return nil, ErrImageAndImageHex
+		}
+
+		decoded, err := hex.DecodeString(strings.TrimSpace(f.ImageHex))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode hex image: %w", err)
+		}
+
+		imageData = decoded
+	}
+
+	files := f.Files
+
+	if f.FilesFrom != "" {
+		listed, err := readFileList(f.FilesFrom)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file list: %w", err)
+		}
+
+		files = append(append([]string{}, files...), listed...)
+	}
+
+	var templateData map[string]any
+
+	if f.DataFile != "" {
+		data, err := loadTemplateData(f.DataFile)
+		if err != nil {
+			return nil, err
+		}
+
+		templateData = data
+	}
+
+	if len(f.Vars) > 0 && templateData == nil {
+		templateData = make(map[string]any, len(f.Vars))
+	}
+
+	for key, value := range f.Vars {
+		templateData[key] = value
+	}
+
+	prompt := f.Prompt
+
+	if f.TemplateFile != "" {
+		text, err := loadTemplateFile(f.TemplateFile)
+		if err != nil {
+			return nil, err
+		}
+
+		prompt = text
+
+		if templateData == nil {
+			templateData = make(map[string]any)
+		}
+	}
+
 	return &BuildRequest{
-		Prompt:        f.Prompt,
-		File:          f.File,
-		Task:          f.Task,
-		SystemMessage: f.SystemMessage,
-		Guidelines:    f.Guidelines,
-		Image:         imageData,
-		OutputFormat:  f.OutputFormat,
+		Prompt:             prompt,
+		File:               f.File,
+		Files:              files,
+		Task:               f.Task,
+		SystemMessage:      f.SystemMessage,
+		Guidelines:         f.Guidelines,
+		GuidelineList:      f.GuidelineList,
+		Image:              imageData,
+		ImagePath:          f.ImageByRef,
+		Manifest:           f.Manifest,
+		OutputFormat:       f.OutputFormat,
+		WithContext:        f.WithContext,
+		PromptPrefix:       f.PromptPrefix,
+		PromptSuffix:       f.PromptSuffix,
+		Normalize:          f.Normalize,
+		SortFilesBy:        f.SortFilesBy,
+		SortReverse:        f.SortReverse,
+		MaxIncluded:        f.MaxIncluded,
+		KeepWhitespace:     f.KeepWhitespace,
+		LabelSystem:        f.LabelSystem,
+		NumberedGuidelines: f.NumberedGuidelines,
+		TableOfContents:    f.TOC,
+		ImageDetail:        f.ImageDetail,
+		Separator:          unescapeSeparator(f.Separator),
+		JSONFiles:          f.JSONFiles,
+		TemplateData:       templateData,
+		FileNotes:          f.Notes,
+		Functions:          f.Functions,
+		Commands:           f.Commands,
+		AllowedCommands:    f.AllowedCommands,
+		AllowShell:         f.AllowShell,
 	}, nil
 }
@@ -1,6 +1,8 @@
 package promptbuilder_test
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/book-expert/prompt-builder/promptbuilder"
@@ -114,6 +116,7 @@ func TestBuildRequestValidateWithComponents(t *testing.T) {
 		})
 	}
 }
+
 // TestPromptString tests the String method of the Prompt struct.
 func TestPromptString(t *testing.T) {
 	t.Parallel()
@@ -145,6 +148,48 @@ func TestPromptString(t *testing.T) {
 	}
 }
 
+func TestPromptHash(t *testing.T) {
+	t.Parallel()
+
+	base := func() promptbuilder.Prompt {
+		return promptbuilder.Prompt{
+			SystemContext: "OS: linux",
+			SystemMessage: "System message.",
+			UserPrompt:    "User prompt.",
+			FileContent:   "File content.",
+			Guidelines:    "Guidelines.",
+		}
+	}
+
+	first := base()
+	second := base()
+
+	if first.Hash() != second.Hash() {
+		t.Fatalf("Expected identical prompts to hash equally, got %s and %s", first.Hash(), second.Hash())
+	}
+
+	mutations := map[string]func(*promptbuilder.Prompt){
+		"system context": func(p *promptbuilder.Prompt) { p.SystemContext = "OS: darwin" },
+		"system message": func(p *promptbuilder.Prompt) { p.SystemMessage = "Other system." },
+		"user prompt":    func(p *promptbuilder.Prompt) { p.UserPrompt = "Other prompt." },
+		"file content":   func(p *promptbuilder.Prompt) { p.FileContent = "Other content." },
+		"guidelines":     func(p *promptbuilder.Prompt) { p.Guidelines = "Other guidelines." },
+	}
+
+	for name, mutate := range mutations {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			changed := base()
+			mutate(&changed)
+
+			if changed.Hash() == first.Hash() {
+				t.Errorf("Expected hash to change when %s changes", name)
+			}
+		})
+	}
+}
+
 func TestFileContentValidate(t *testing.T) {
 	t.Parallel()
 
@@ -208,4 +253,206 @@ func contains(s, substr string) bool {
 
 				return false
 			}()))
-}
\ No newline at end of file
+}
+
+func TestPromptSections(t *testing.T) {
+	t.Parallel()
+
+	prompt := promptbuilder.Prompt{
+		SystemMessage: "System message.",
+		UserPrompt:    "User prompt.",
+		FileContent:   "File content.",
+	}
+
+	sections := prompt.Sections()
+
+	want := []string{promptbuilder.SectionSystem, promptbuilder.SectionFiles, promptbuilder.SectionUser}
+	if len(sections) != len(want) {
+		t.Fatalf("Expected %d sections, got %d", len(want), len(sections))
+	}
+
+	for index, name := range want {
+		if sections[index].Name != name {
+			t.Errorf("Expected section %d to be %s, got %s", index, name, sections[index].Name)
+		}
+	}
+}
+
+func TestPromptFilterSections(t *testing.T) {
+	t.Parallel()
+
+	prompt := promptbuilder.Prompt{
+		SystemMessage: "System message.",
+		UserPrompt:    "User prompt.",
+		FileContent:   "File content.",
+		Guidelines:    "Guidelines.",
+	}
+
+	onlyUser, err := prompt.FilterSections([]string{"user"}, nil)
+	if err != nil {
+		t.Fatalf("FilterSections() unexpected error = %v", err)
+	}
+
+	if onlyUser.String() != "User prompt." {
+		t.Errorf("Expected only the user prompt, got %q", onlyUser.String())
+	}
+
+	withoutFile, err := prompt.FilterSections(nil, []string{"file"})
+	if err != nil {
+		t.Fatalf("FilterSections() unexpected error = %v", err)
+	}
+
+	want := "System message.\n\nGuidelines:\n\nGuidelines.\n\nUser prompt."
+	if withoutFile.String() != want {
+		t.Errorf("Expected %q, got %q", want, withoutFile.String())
+	}
+
+	_, err = prompt.FilterSections([]string{"footer"}, nil)
+	if !errors.Is(err, promptbuilder.ErrUnknownSection) {
+		t.Errorf("Expected ErrUnknownSection, got %v", err)
+	}
+
+	result, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt: "Describe this",
+		Image:  []byte("\x89PNG\r\n\x1a\n"),
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	withoutImage, err := result.Prompt.FilterSections(nil, []string{"image"})
+	if err != nil {
+		t.Fatalf("FilterSections() unexpected error = %v", err)
+	}
+
+	if withoutImage.ImageURL != "" || withoutImage.FileContent != "" {
+		t.Errorf("Expected the image to be dropped, got ImageURL %q and FileContent %q",
+			withoutImage.ImageURL, withoutImage.FileContent)
+	}
+
+	messages, err := withoutImage.ToChatMessages(promptbuilder.RoleUser)
+	if err != nil {
+		t.Fatalf("ToChatMessages() unexpected error = %v", err)
+	}
+
+	if images := messages[len(messages)-1].Images; len(images) != 0 {
+		t.Errorf("Expected no image_url part without the image section, got %v", images)
+	}
+}
+
+func TestPromptValidate(t *testing.T) {
+	t.Parallel()
+
+	fenced := "BEGIN main.go\n```go\nEND main.go is just text here\n```\nEND main.go\n\nBEGIN notes.txt\nhello\nEND notes.txt"
+
+	tests := []struct {
+		name    string
+		prompt  promptbuilder.Prompt
+		wantErr error
+	}{
+		{
+			name:    "valid prompt with files",
+			prompt:  promptbuilder.Prompt{UserPrompt: "Review", FileContent: fenced},
+			wantErr: nil,
+		},
+		{
+			name:    "empty user prompt",
+			prompt:  promptbuilder.Prompt{UserPrompt: "  ", FileContent: fenced},
+			wantErr: promptbuilder.ErrPromptRequired,
+		},
+		{
+			name:    "missing END",
+			prompt:  promptbuilder.Prompt{UserPrompt: "Review", FileContent: "BEGIN main.go\npackage main"},
+			wantErr: promptbuilder.ErrUnbalancedFences,
+		},
+		{
+			name:    "content outside a fence",
+			prompt:  promptbuilder.Prompt{UserPrompt: "Review", FileContent: "package main"},
+			wantErr: promptbuilder.ErrUnbalancedFences,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := testCase.prompt.Validate()
+			if !errors.Is(err, testCase.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, testCase.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildResultComponents(t *testing.T) {
+	t.Parallel()
+
+	tmpFileName, _, cleanup := setupFileProcessorTest(t)
+	t.Cleanup(cleanup)
+
+	result, err := newTestBuilder().BuildPrompt(&promptbuilder.BuildRequest{
+		Prompt:        "Explain this code",
+		File:          tmpFileName,
+		SystemMessage: "You are a reviewer.",
+		Guidelines:    "Be brief",
+	})
+	if err != nil {
+		t.Fatalf("BuildPrompt() unexpected error = %v", err)
+	}
+
+	components := result.Components()
+
+	want := map[string]string{
+		"system":     "You are a reviewer.",
+		"user":       "Explain this code",
+		"file":       result.Prompt.FileContent,
+		"guidelines": "Be brief",
+	}
+
+	if len(components) != len(want) {
+		t.Fatalf("Expected %d components, got %v", len(want), components)
+	}
+
+	for key, value := range want {
+		if components[key] != value {
+			t.Errorf("Expected %s to be %q, got %q", key, value, components[key])
+		}
+	}
+
+	if !strings.Contains(components["file"], "BEGIN "+tmpFileName) {
+		t.Errorf("Expected fenced file content, got %q", components["file"])
+	}
+}
+
+func TestPromptLabelSystem(t *testing.T) {
+	t.Parallel()
+
+	prompt := promptbuilder.Prompt{SystemMessage: "You are a reviewer.", UserPrompt: "Review"}
+
+	if got := prompt.String(); got != "You are a reviewer.\n\nReview" {
+		t.Errorf("Expected unlabeled system message by default, got %q", got)
+	}
+
+	prompt.LabelSystem = true
+
+	if got := prompt.String(); got != "System:\n\nYou are a reviewer.\n\nReview" {
+		t.Errorf("Expected labeled system message, got %q", got)
+	}
+}
+
+func TestPromptStringWithSeparator(t *testing.T) {
+	t.Parallel()
+
+	prompt := promptbuilder.Prompt{SystemMessage: "Be terse.", Guidelines: "No jargon.", UserPrompt: "Review"}
+
+	want := "Be terse.\n---\nGuidelines:\n\nNo jargon.\n---\nReview"
+	if got := prompt.StringWithSeparator("\n---\n"); got != want {
+		t.Errorf("StringWithSeparator() = %q, want %q", got, want)
+	}
+
+	prompt.Separator = "\n---\n"
+
+	if got := prompt.String(); got != want {
+		t.Errorf("String() with Separator = %q, want %q", got, want)
+	}
+}